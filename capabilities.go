@@ -0,0 +1,29 @@
+package dalkeeth
+
+// UpsertSyntax is how a dialect inserts or updates a record (see Dialect.UpsertSql)
+type UpsertSyntax int
+
+const (
+	NoUpsert       UpsertSyntax = iota
+	OnConflict                  // INSERT ... ON CONFLICT (key) DO UPDATE: SQLite, Postgres
+	OnDuplicateKey              // INSERT ... ON DUPLICATE KEY UPDATE: MySQL, MariaDB
+)
+
+func (u UpsertSyntax) String() string {
+	return [...]string{"none", "ON CONFLICT", "ON DUPLICATE KEY"}[u]
+}
+
+// Capabilities describes the features of a dialect and its database that vary across databases,
+// for callers and for the conformance suite (package conformance) to adapt to
+type Capabilities struct {
+	Upsert                UpsertSyntax // Syntax of UpsertSql
+	WindowFunctions       bool         // OVER (PARTITION BY ... ORDER BY ...)
+	PartialIndexes        bool         // CREATE INDEX ... WHERE
+	IsTrue                bool         // IS TRUE and IS NOT TRUE operators
+	TransactionalDDL      bool         // Table changes are rolled back with the transaction (see ApplyMigration)
+	DeferrableForeignKeys bool         // Foreign keys checked at commit (see Deferrable)
+	AutoIncrement         bool         // A single integer primary key may be left unset on save
+	Returning             bool         // Save returns the primary key (RETURNING) and sets it in the record
+	MaxParams             int          // Maximum number of placeholders in one statement
+	MaxIdentifierLength   int          // Maximum length of table and field names; 0 if no limit
+}
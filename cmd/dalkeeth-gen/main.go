@@ -0,0 +1,95 @@
+// dalkeeth-gen generates Go source for a dalkeeth model: table and field name constants, a struct for each
+// table, field accessors, Repo constructors and a NewModel func.
+//
+// Usage:
+//
+//	dalkeeth-gen -model model.json -pkg models -o models_gen.go
+//	dalkeeth-gen -sqlite data.db -pkg models -o models_gen.go
+//
+// The model description file format is documented by dalkeeth.ModelJSON.
+//
+// Built with cgo, SQLite databases are read with github.com/mattn/go-sqlite3; with CGO_ENABLED=0, with the
+// pure Go modernc.org/sqlite.
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/gnewton/dalkeeth"
+)
+
+func main() {
+	modelFile := flag.String("model", "", "JSON model description file")
+	sqliteFile := flag.String("sqlite", "", "SQLite database file to read the tables from")
+	pkg := flag.String("pkg", "models", "Package name of the generated source")
+	out := flag.String("o", "", "Output file; stdout if empty")
+	verbose := flag.Bool("v", false, "Log dalkeeth diagnostics")
+	flag.Parse()
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	if err := run(*modelFile, *sqliteFile, *pkg, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "dalkeeth-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(modelFile, sqliteFile, pkg, out string) error {
+	if (modelFile == "") == (sqliteFile == "") {
+		return errors.New("need exactly one of -model or -sqlite")
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if modelFile != "" {
+		err = generateFromModelFile(&buf, modelFile, pkg)
+	} else {
+		err = generateFromSqlite(&buf, sqliteFile, pkg)
+	}
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(out, buf.Bytes(), 0644)
+}
+
+func generateFromModelFile(w io.Writer, modelFile, pkg string) error {
+	f, err := os.Open(modelFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	m, err := dalkeeth.ReadModelJSON(f)
+	if err != nil {
+		return err
+	}
+	return dalkeeth.Generate(w, m, pkg)
+}
+
+func generateFromSqlite(w io.Writer, sqliteFile, pkg string) error {
+	// Do not create an empty database
+	if _, err := os.Stat(sqliteFile); err != nil {
+		return err
+	}
+	db, err := sql.Open(sqliteDriver, "file:"+sqliteFile+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return dalkeeth.GenerateFromDB(w, db, new(dalkeeth.DialectSqlite3), pkg)
+}
@@ -0,0 +1,10 @@
+//go:build cgo
+
+package main
+
+import (
+	"github.com/gnewton/dalkeeth"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteDriver = dalkeeth.SqliteCgoDriver
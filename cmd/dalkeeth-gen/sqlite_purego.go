@@ -0,0 +1,10 @@
+//go:build !cgo
+
+package main
+
+import (
+	"github.com/gnewton/dalkeeth"
+	_ "modernc.org/sqlite"
+)
+
+const sqliteDriver = dalkeeth.SqlitePureGoDriver
@@ -0,0 +1,182 @@
+package dalkeeth
+
+import (
+	"strings"
+	"testing"
+)
+
+const compositeKeyTestModelJSON = `{"tables": [
+ {"name": "persons",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "name", "type": "string"}]},
+ {"name": "memberships",
+  "fields": [{"name": "person_id", "type": "int", "pk": true},
+             {"name": "club", "type": "string", "pk": true},
+             {"name": "role", "type": "string"}],
+  "foreignKeys": [{"field": "person_id", "table": "persons", "foreignKey": "id"}]},
+ {"name": "fees",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "person_id", "type": "int"},
+             {"name": "club", "type": "string"}],
+  "foreignKeys": [{"fields": ["person_id", "club"], "table": "memberships", "foreignKeys": ["person_id", "club"]}]}
+]}`
+
+func compositeKeyTestSession() (*Session, error) {
+	m, err := ReadModelJSON(strings.NewReader(compositeKeyTestModelJSON))
+	if err != nil {
+		return nil, err
+	}
+	db, err := openTestDB()
+	if err != nil {
+		return nil, err
+	}
+	sess, err := OpenDB(m, db, new(DialectSqlite3))
+	if err != nil {
+		return nil, err
+	}
+	if err = sess.WriteModelTableSchemaToDB(); err != nil {
+		return nil, err
+	}
+	_, err = sess.db.Exec("INSERT INTO persons VALUES (1, 'Fred')")
+	return sess, err
+}
+
+func TestDialectSqlite3_CompositePrimaryKeySql(t *testing.T) {
+	m, err := ReadModelJSON(strings.NewReader(compositeKeyTestModelJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := new(DialectSqlite3)
+	memberships := m.TableByKey("memberships")
+
+	s, err := d.CreateTableSql(memberships)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE TABLE IF NOT EXISTS "memberships" ("person_id" INT, "club" TEXT, "role" TEXT` +
+		`, PRIMARY KEY("person_id", "club"), FOREIGN KEY("person_id") REFERENCES "persons"("id"))`
+	if s != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+
+	s, args, err := d.DeleteSql(memberships, []any{int64(1), "chess"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `DELETE FROM "memberships" WHERE "person_id"=? AND "club"=?` || len(args) != 2 {
+		t.Fatal(s, args)
+	}
+
+	if _, _, err = d.DeleteSql(memberships, []any{int64(1)}); err == nil {
+		t.Fatal("Too few key values", ShouldHaveFailed)
+	}
+	if _, _, err = d.ExistsSql(memberships, []any{"1", "chess"}); err == nil {
+		t.Fatal("Wrong key value type", ShouldHaveFailed)
+	}
+}
+
+func TestSession_CompositePrimaryKey(t *testing.T) {
+	sess, err := compositeKeyTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	memberships := sess.TableByKey("memberships")
+
+	rec := memberships.NewRecord()
+	for name, v := range map[string]any{"person_id": 1, "club": "chess", "role": "member"} {
+		if err = rec.SetValue(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = sess.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := sess.ExistsKey(memberships, int64(1), "chess")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("Membership should exist")
+	}
+	exists, err = sess.ExistsKey(memberships, int64(1), "golf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("Membership should not exist")
+	}
+
+	if err = rec.SetValue("role", "captain"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := sess.Update(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("Should have updated 1 record; updated", n)
+	}
+
+	got, err := sess.GetKey(memberships, int64(1), "chess")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("Membership not found")
+	}
+	role, err := got.Get("role")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != "captain" {
+		t.Fatal("Expected role captain; got", role)
+	}
+
+	if _, err = sess.GetKey(memberships, int64(1)); err == nil {
+		t.Fatal("Too few key values", ShouldHaveFailed)
+	}
+	if _, err = sess.Get(memberships, 1); err == nil {
+		t.Fatal("Get by id on composite primary key", ShouldHaveFailed)
+	}
+
+	n, err = sess.DeleteKey(memberships, int64(1), "chess")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("Should have deleted 1 record; deleted", n)
+	}
+	got, err = sess.GetKey(memberships, int64(1), "chess")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Fatal("Membership should be deleted")
+	}
+}
+
+func TestSession_CompositePrimaryKeyRoundTrip(t *testing.T) {
+	sess, err := compositeKeyTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	diff, err := sess.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Fatal("Extracted composite primary key should match the model:", diff)
+	}
+
+	m, err := ExtractModel(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(fieldNames(m.TableByKey("memberships").PrimaryKey()), ","); got != "person_id,club" {
+		t.Fatal("Expected primary key person_id,club; got", got)
+	}
+}
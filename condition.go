@@ -3,8 +3,7 @@ package dalkeeth
 import (
 	"errors"
 	"fmt"
-	"log"
-	"strconv"
+	"strings"
 )
 
 // support
@@ -88,10 +87,10 @@ func (op Operator) String() string {
 		return " IS TRUE "
 
 	case LE:
-		return " < "
+		return " <= "
 
 	case LT:
-		return " > "
+		return " < "
 
 	case Like:
 		return " LIKE "
@@ -114,7 +113,7 @@ const MaxInLength = 100
 func (op Operator) ArgStart() string {
 	switch op {
 	case In, NotIn:
-		return "["
+		return "("
 	}
 	return ""
 }
@@ -122,11 +121,20 @@ func (op Operator) ArgStart() string {
 func (op Operator) ArgEnd() string {
 	switch op {
 	case In, NotIn:
-		return "]"
+		return ")"
 	}
 	return ""
 }
 
+// Separator between values
+func (op Operator) ArgSeparator() string {
+	switch op {
+	case Between, NotBetween:
+		return LAnd.String()
+	}
+	return COMMA_SPACE
+}
+
 func (op Operator) MinMaxArgs() (int, int) {
 	switch op {
 	case Between, NotBetween:
@@ -147,8 +155,34 @@ func (op Operator) StringValueOperator() bool {
 	return true
 }
 
+// Args collects the values bound to the placeholders of generated SQL, in order
+type Args struct {
+	dialect Dialect
+	values  []any
+}
+
+// NewArgs uses the placeholders of the dialect; "?" if the dialect is nil
+func NewArgs(d Dialect) *Args {
+	return &Args{
+		dialect: d,
+	}
+}
+
+// Add appends the value and returns its placeholder
+func (a *Args) Add(v any) string {
+	a.values = append(a.values, v)
+	if a.dialect == nil {
+		return "?"
+	}
+	return a.dialect.Placeholder(len(a.values))
+}
+
+func (a *Args) Values() []any {
+	return a.values
+}
+
 type Condition interface {
-	Evaluate(depth int) (string, error)
+	Evaluate(args *Args, depth int) (string, error)
 	Validate() error
 	String() string // Not sql; just for debugging
 }
@@ -172,6 +206,8 @@ func WN[L LHS](left L, op Operator) Condition {
 	return W(left, op, None...)
 }
 
+// W is the condition left op values. A string left is a field name, quoted like fields, or else an SQL
+// expression such as COUNT(*), used as is.
 func W[L LHS, V Values](left L, op Operator, values ...V) Condition {
 	switch l := any(left).(type) {
 	case string:
@@ -266,7 +302,6 @@ func toInt64(v []int) []int64 {
 
 func (expr *SP_GenericCondition[Left, Values]) Validate() error {
 	expr.validated = true
-	log.Println("SP_GenericCondition[Left, Values]) Validate---->", expr.validated)
 	return nil
 }
 
@@ -274,16 +309,17 @@ func (expr *SP_GenericCondition[Left, Values]) String() string {
 	return "SP_GenericCondition: " + expr.op.String()
 }
 
-func (expr *SP_GenericCondition[Left, Values]) Evaluate(d int) (string, error) {
-	log.Println("SP_GenericCondition[Left, Values]) Evaluate---->", expr.validated)
+func (expr *SP_GenericCondition[Left, Values]) Evaluate(args *Args, d int) (string, error) {
 	if !expr.validated {
 		return "", errors.New("SP_GenericCondition.Evaluate: Cannot Evaluate without first being Validated:" + expr.String())
 	}
+	if args == nil {
+		return "", errors.New("SP_GenericCondition.Evaluate: args is nil")
+	}
 
 	var e string
 
 	err := validateOperationWithValuesCount(expr.op, len(expr.values))
-	log.Println(expr.left, expr.values)
 
 	if err != nil {
 		return "", err
@@ -300,59 +336,48 @@ func (expr *SP_GenericCondition[Left, Values]) Evaluate(d int) (string, error) {
 
 	switch l := any(expr.left).(type) {
 	case string:
-		e += l + expr.op.String() + expr.op.ArgStart()
-
-		rawValues, err := toValues(expr.values)
-		if err != nil {
-			return "", err
-		}
-		e += rawValues
-		e += expr.op.ArgEnd()
-		return e, nil
+		e += nameOrExpression(args.dialect, l)
 	case *Field:
-		e += l.String() + expr.op.String() + expr.op.ArgStart()
-		rawValues, err := toValues(expr.values)
-		if err != nil {
-			return "", err
+		if l == nil {
+			return "", errors.New("SP_GenericCondition.Evaluate: field is nil")
 		}
-		e += rawValues
-		e += expr.op.ArgEnd()
-		return e, nil
+		e += quoteField(args.dialect, l)
 	default:
-		return "Field not implemented", nil
+		return "", errors.New("SP_GenericCondition.Evaluate: unknown left type")
+	}
+
+	e += expr.op.String() + expr.op.ArgStart()
+	placeholders, err := toPlaceholders(args, expr.op, expr.values)
+	if err != nil {
+		return "", err
 	}
+	e += placeholders
+	e += expr.op.ArgEnd()
+	return e, nil
 }
 
-func toValues[V Values](values []V) (string, error) {
+// Values are bound to placeholders; fields are used by name
+func toPlaceholders[V Values](args *Args, op Operator, values []V) (string, error) {
 	var s string
 	for i := 0; i < len(values); i++ {
 		if i > 0 {
-			s += ", "
+			s += op.ArgSeparator()
 		}
-		v := values[i]
-		raw, err := valueToString(v)
-		if err != nil {
-			return "", err
+		switch v := any(values[i]).(type) {
+		case int64, float64, string:
+			s += args.Add(v)
+		case *Field:
+			if v == nil {
+				return "", errors.New("Value field is nil")
+			}
+			s += quoteField(args.dialect, v)
+		default:
+			return "", errors.New("Unknown type")
 		}
-		s += raw
 	}
 	return s, nil
 }
 
-func valueToString[V Values](value V) (string, error) {
-	switch v := any(value).(type) {
-	case int64:
-		return strconv.FormatInt(v, 10), nil
-	case float64:
-		return fmt.Sprintf("%f", v), nil
-	case string:
-		return "\"" + v + "\"", nil
-	case *Field:
-		return v.String(), nil
-	}
-	return "", errors.New("Unknown type")
-}
-
 func (f *Field) String() string {
 	return "<field>"
 }
@@ -364,8 +389,25 @@ func Not(e Condition) Condition {
 
 }
 
-func Evaluate(e Condition) (string, error) {
-	return e.Evaluate(0)
+// Evaluate returns the SQL of the condition with the placeholders of the dialect, and the values bound to them
+func Evaluate(e Condition, d Dialect) (string, []any, error) {
+	if e == nil {
+		return "", nil, errors.New("Condition is nil")
+	}
+	args := NewArgs(d)
+	s, err := e.Evaluate(args, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	return s, args.Values(), nil
+}
+
+// Validates then evaluates, adding the bound values to args
+func evaluateCondition(e Condition, args *Args) (string, error) {
+	if err := e.Validate(); err != nil {
+		return "", err
+	}
+	return e.Evaluate(args, 0)
 }
 
 type NotCondition struct {
@@ -382,12 +424,18 @@ func (expr NotCondition) String() string {
 	return "NotCondition"
 }
 
-func (n *NotCondition) Evaluate(d int) (string, error) {
-	eval, err := n.e.Evaluate(d + 1)
+func (n *NotCondition) Evaluate(args *Args, d int) (string, error) {
+	if n.e == nil {
+		return "", errors.New("Not expression is nil")
+	}
+	if err := n.e.Validate(); err != nil {
+		return "", err
+	}
+	eval, err := n.e.Evaluate(args, d+1)
 	if err != nil {
 		return "", err
 	}
-	return LNot.String() + eval, nil
+	return strings.TrimLeft(LNot.String(), SPACE) + "(" + eval + ")", nil
 }
 
 type LogicalCondition struct {
@@ -406,7 +454,7 @@ func (expr LogicalCondition) String() string {
 	return "LogicalCondition"
 }
 
-func (o LogicalCondition) Evaluate(d int) (string, error) {
+func (o LogicalCondition) Evaluate(args *Args, d int) (string, error) {
 	var s string
 
 	if o.exp1 == nil {
@@ -426,13 +474,13 @@ func (o LogicalCondition) Evaluate(d int) (string, error) {
 		return "", err
 	}
 
-	eval, err := o.exp1.Evaluate(d + 1)
+	eval, err := o.exp1.Evaluate(args, d+1)
 	if err != nil {
 		return "", err
 	}
 	s += eval + o.op.String()
 
-	eval, err = o.exp2.Evaluate(d + 1)
+	eval, err = o.exp2.Evaluate(args, d+1)
 	if err != nil {
 		return "", err
 	}
@@ -443,7 +491,7 @@ func (o LogicalCondition) Evaluate(d int) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		eval, err = o.exps[i].Evaluate(d + 1)
+		eval, err = o.exps[i].Evaluate(args, d+1)
 		if err != nil {
 			return "", err
 		}
@@ -475,7 +523,6 @@ func And(exp1, exp2 Condition, exps ...Condition) Condition {
 
 func validateOperationWithValuesCount(op Operator, l int) error {
 	min, max := op.MinMaxArgs()
-	log.Println(op.String(), min, max)
 	if l < min {
 		return fmt.Errorf("Too few arguments %d for operator %s: need > %d", l, op.String(), min)
 	}
@@ -0,0 +1,747 @@
+// Package conformance is a test suite for Dialect implementations. A dialect runs it, from its own tests, against
+// a database opened with its own driver to show that it creates tables and saves, gets, queries, updates and
+// deletes records the same way as the other dialects:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, new(MyDialect), func() (*sql.DB, error) {
+//			return sql.Open("mydriver", dsn)
+//		})
+//	}
+//
+// The suite creates, and drops, the tables conformance_owners, conformance_pets, conformance_memberships,
+// conformance_ddl and conformance_xxx (as long as MaxIdentifierLength), and the index conformance_partial. Each
+// feature claimed by the Capabilities of the dialect is run against the database; the parts which depend on a
+// feature are skipped when the dialect does not claim it.
+package conformance
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/gnewton/dalkeeth"
+)
+
+const (
+	Owners      = "conformance_owners"
+	Pets        = "conformance_pets"
+	Memberships = "conformance_memberships"
+)
+
+const modelJSON = `{"tables": [
+ {"name": "conformance_owners",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "name", "type": "string", "length": 64, "notNull": true},
+             {"name": "email", "type": "string", "length": 64, "unique": true},
+             {"name": "weight", "type": "float"},
+             {"name": "citizen", "type": "bool", "default": true},
+             {"name": "photo", "type": "bytes"}],
+  "indexes": [{"fields": ["name"]}]},
+ {"name": "conformance_pets",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "owner_id", "type": "int", "notNull": true},
+             {"name": "name", "type": "string", "length": 32}],
+  "foreignKeys": [{"field": "owner_id", "table": "conformance_owners", "foreignKey": "id", "onDelete": "cascade"}]},
+ {"name": "conformance_memberships",
+  "fields": [{"name": "owner_id", "type": "int", "pk": true},
+             {"name": "club", "type": "string", "length": 32, "pk": true},
+             {"name": "rank", "type": "int"}],
+  "foreignKeys": [{"field": "owner_id", "table": "conformance_owners", "foreignKey": "id", "onDelete": "cascade"}]}
+]}`
+
+// Model of the suite tables
+func Model() (*dalkeeth.Model, error) {
+	return dalkeeth.ReadModelJSON(strings.NewReader(modelJSON))
+}
+
+// Run runs the suite with the dialect. open is called once for each subtest, and must return a database in which
+// the suite tables can be dropped and created.
+func Run(t *testing.T, d dalkeeth.Dialect, open func() (*sql.DB, error)) {
+	if d == nil {
+		t.Fatal("conformance.Run: dialect is nil")
+	}
+	if open == nil {
+		t.Fatal("conformance.Run: open is nil")
+	}
+	c := &suite{dialect: d, open: open}
+
+	t.Run("Capabilities", c.capabilities)
+	t.Run("Schema", c.schema)
+	t.Run("SaveGet", c.saveGet)
+	t.Run("Update", c.update)
+	t.Run("ExistsDelete", c.existsDelete)
+	t.Run("CompositeKey", c.compositeKey)
+	t.Run("Query", c.query)
+	t.Run("Upsert", c.upsert)
+	t.Run("ForeignKeys", c.foreignKeys)
+	t.Run("Rollback", c.rollback)
+}
+
+type suite struct {
+	dialect dalkeeth.Dialect
+	open    func() (*sql.DB, error)
+}
+
+// Session with the suite tables, empty; closed when the test ends
+func (c *suite) session(t *testing.T) *dalkeeth.Session {
+	t.Helper()
+	return c.sessionOf(t, modelJSON)
+}
+
+// Session with the tables of the model, empty
+func (c *suite) sessionOf(t *testing.T, mj string) *dalkeeth.Session {
+	t.Helper()
+	m, err := dalkeeth.ReadModelJSON(strings.NewReader(mj))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := c.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := dalkeeth.OpenDB(m, db, c.dialect)
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sess.Close()
+	})
+	if err = sess.WriteModelTableSchemaToDB(dalkeeth.WithDropExisting()); err != nil {
+		t.Fatal(err)
+	}
+	return sess
+}
+
+func record(t *testing.T, tbl *dalkeeth.Table, values map[string]any) *dalkeeth.InRecord {
+	t.Helper()
+	rec := tbl.NewRecord()
+	for name, v := range values {
+		if err := rec.SetValue(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec
+}
+
+func save(t *testing.T, sess *dalkeeth.Session, table string, values map[string]any) {
+	t.Helper()
+	if err := sess.Save(record(t, sess.TableByKey(table), values)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expect fails the test if the record is missing or if its values are not the expected values
+func expect(t *testing.T, rec *dalkeeth.InRecord, expected map[string]any) {
+	t.Helper()
+	if rec == nil {
+		t.Fatal("Expected a record; got nil")
+	}
+	for name, e := range expected {
+		v, err := rec.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, ok := e.([]byte); ok {
+			if vb, ok := v.([]byte); !ok || !bytes.Equal(b, vb) {
+				t.Fatalf("%s: expected %v; got %T %v", name, e, v, v)
+			}
+			continue
+		}
+		if v != e {
+			t.Fatalf("%s: expected %T %v; got %T %v", name, e, e, v, v)
+		}
+	}
+}
+
+func (c *suite) capabilities(t *testing.T) {
+	caps := c.dialect.Capabilities()
+	if caps.MaxParams < 999 {
+		t.Fatal("Expected MaxParams of at least 999; got", caps.MaxParams)
+	}
+	if caps.MaxIdentifierLength < 0 {
+		t.Fatal("MaxIdentifierLength < 0:", caps.MaxIdentifierLength)
+	}
+	if c.dialect.DialectName() == "" {
+		t.Fatal("Dialect name is empty")
+	}
+
+	t.Run("MaxParams", c.maxParams)
+	t.Run("MaxIdentifierLength", c.maxIdentifierLength)
+	t.Run("IsTrue", c.isTrue)
+	t.Run("WindowFunctions", c.windowFunctions)
+	t.Run("PartialIndexes", c.partialIndexes)
+	t.Run("TransactionalDDL", c.transactionalDDL)
+	t.Run("DeferrableForeignKeys", c.deferrableForeignKeys)
+	t.Run("AutoIncrement", c.autoIncrement)
+	t.Run("Returning", c.returning)
+}
+
+// Ids of the records of the query
+func queryIds(t *testing.T, sess *dalkeeth.Session, q *dalkeeth.Query) []int64 {
+	t.Helper()
+	rows, err := sess.ExecuteQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func (c *suite) maxParams(t *testing.T) {
+	sess := c.session(t)
+	save(t, sess, Owners, map[string]any{"id": int64(1), "name": "Fred"})
+
+	q := c.dialect.QuoteIdentifier
+	args := make([]any, c.dialect.Capabilities().MaxParams)
+	placeholders := make([]string, len(args))
+	for i := 0; i < len(args); i++ {
+		args[i] = int64(i + 1)
+		placeholders[i] = c.dialect.Placeholder(i + 1)
+	}
+	var n int64
+	err := sess.DB().QueryRow("SELECT COUNT(*) FROM "+q(Owners)+" WHERE "+q("id")+" IN ("+strings.Join(placeholders, ", ")+")",
+		args...).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("Expected 1 record; got", n)
+	}
+}
+
+func (c *suite) maxIdentifierLength(t *testing.T) {
+	max := c.dialect.Capabilities().MaxIdentifierLength
+	if max == 0 {
+		t.Skip("Dialect has no identifier length limit")
+	}
+	name := "conformance_" + strings.Repeat("x", max-len("conformance_"))
+	if err := c.dialect.ValidTableName(name + "x"); err == nil {
+		t.Fatal("Validating a name longer than MaxIdentifierLength should have failed")
+	}
+
+	sess := c.session(t)
+	q := c.dialect.QuoteIdentifier
+	if _, err := sess.DB().Exec("CREATE TABLE " + q(name) + " (" + q("id") + " INT)"); err != nil {
+		t.Fatal(err)
+	}
+	defer sess.DB().Exec("DROP TABLE " + q(name))
+	names, err := c.dialect.TableNames(sess.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(names); i++ {
+		if names[i] == name {
+			return
+		}
+	}
+	t.Fatalf("Table %s not in %v", name, names)
+}
+
+func (c *suite) isTrue(t *testing.T) {
+	if !c.dialect.Capabilities().IsTrue {
+		t.Skip("Dialect has no IS TRUE")
+	}
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+	save(t, sess, Owners, map[string]any{"id": int64(1), "name": "Fred", "citizen": true})
+	save(t, sess, Owners, map[string]any{"id": int64(2), "name": "Wilma", "citizen": false})
+
+	q := dalkeeth.NewQuery().Select(owners.Field("id")).From(owners).Where(dalkeeth.WN(owners.Field("citizen"), dalkeeth.IsTrue))
+	if ids := queryIds(t, sess, q); len(ids) != 1 || ids[0] != 1 {
+		t.Fatal("Expected [1]; got", ids)
+	}
+	q = dalkeeth.NewQuery().Select(owners.Field("id")).From(owners).Where(dalkeeth.WN(owners.Field("citizen"), dalkeeth.IsNotTrue))
+	if ids := queryIds(t, sess, q); len(ids) != 1 || ids[0] != 2 {
+		t.Fatal("Expected [2]; got", ids)
+	}
+}
+
+func (c *suite) windowFunctions(t *testing.T) {
+	if !c.dialect.Capabilities().WindowFunctions {
+		t.Skip("Dialect has no window functions")
+	}
+	sess := c.session(t)
+	save(t, sess, Owners, map[string]any{"id": int64(1), "name": "Fred"})
+	save(t, sess, Owners, map[string]any{"id": int64(2), "name": "Fred"})
+	save(t, sess, Owners, map[string]any{"id": int64(3), "name": "Wilma"})
+
+	q := c.dialect.QuoteIdentifier
+	var n int64
+	err := sess.DB().QueryRow("SELECT " + q("n") + " FROM (SELECT " + q("id") + ", ROW_NUMBER() OVER (PARTITION BY " + q("name") +
+		" ORDER BY " + q("id") + ") AS " + q("n") + " FROM " + q(Owners) + ") AS " + q("w") + " WHERE " + q("id") + " = 2").Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatal("Expected row number 2; got", n)
+	}
+}
+
+func (c *suite) partialIndexes(t *testing.T) {
+	if !c.dialect.Capabilities().PartialIndexes {
+		t.Skip("Dialect has no partial indexes")
+	}
+	sess := c.session(t)
+	q := c.dialect.QuoteIdentifier
+	_, err := sess.DB().Exec("CREATE UNIQUE INDEX " + q("conformance_partial") + " ON " + q(Owners) + " (" + q("name") + ") WHERE " +
+		q("weight") + " > 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only unique for the indexed records
+	save(t, sess, Owners, map[string]any{"id": int64(1), "name": "Fred"})
+	save(t, sess, Owners, map[string]any{"id": int64(2), "name": "Fred"})
+	save(t, sess, Owners, map[string]any{"id": int64(3), "name": "Wilma", "weight": 60.0})
+	if err = sess.Save(record(t, sess.TableByKey(Owners), map[string]any{"id": int64(4), "name": "Wilma", "weight": 61.0})); err == nil {
+		t.Fatal("Saving a duplicate value of a partial unique index should have failed")
+	}
+}
+
+func (c *suite) transactionalDDL(t *testing.T) {
+	if !c.dialect.Capabilities().TransactionalDDL {
+		t.Skip("Dialect has no transactional DDL")
+	}
+	sess := c.session(t)
+	q := c.dialect.QuoteIdentifier
+
+	tx, err := sess.DB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec("CREATE TABLE " + q("conformance_ddl") + " (" + q("id") + " INT)"); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := c.dialect.TableNames(sess.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(names); i++ {
+		if names[i] == "conformance_ddl" {
+			t.Fatal("Expected the created table to be rolled back")
+		}
+	}
+}
+
+const deferrableModelJSON = `{"tables": [
+ {"name": "conformance_owners",
+  "fields": [{"name": "id", "type": "int", "pk": true}]},
+ {"name": "conformance_pets",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "owner_id", "type": "int", "notNull": true}],
+  "foreignKeys": [{"field": "owner_id", "table": "conformance_owners", "foreignKey": "id", "deferrable": true}]}
+]}`
+
+func (c *suite) deferrableForeignKeys(t *testing.T) {
+	if !c.dialect.Capabilities().DeferrableForeignKeys {
+		t.Skip("Dialect has no deferrable foreign keys")
+	}
+	sess := c.sessionOf(t, deferrableModelJSON)
+
+	// The owner is saved after its pet, before the commit
+	if err := sess.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	save(t, sess, Pets, map[string]any{"id": int64(1), "owner_id": int64(1)})
+	save(t, sess, Owners, map[string]any{"id": int64(1)})
+	if err := sess.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sess.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	save(t, sess, Pets, map[string]any{"id": int64(2), "owner_id": int64(9)})
+	if err := sess.Commit(); err == nil {
+		t.Fatal("Committing a record with a missing foreign record should have failed")
+	}
+}
+
+func (c *suite) autoIncrement(t *testing.T) {
+	if !c.dialect.Capabilities().AutoIncrement {
+		t.Skip("Dialect has no auto increment")
+	}
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+	save(t, sess, Owners, map[string]any{"name": "Fred"})
+	save(t, sess, Owners, map[string]any{"name": "Wilma"})
+
+	q := dalkeeth.NewQuery().Select(owners.Field("id")).From(owners)
+	if ids := queryIds(t, sess, q); len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatal("Expected 2 distinct ids; got", ids)
+	}
+}
+
+// The key returned by the insert is set in the saved record
+func (c *suite) returning(t *testing.T) {
+	caps := c.dialect.Capabilities()
+	if !caps.Returning {
+		t.Skip("Dialect does not return the key")
+	}
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+
+	values := map[string]any{"name": "Fred"}
+	if !caps.AutoIncrement {
+		values["id"] = int64(7)
+	}
+	rec := record(t, owners, values)
+	if err := sess.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+	var id int64
+	if err := rec.GetInt("id", &id); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := sess.Get(owners, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, saved, map[string]any{"id": id, "name": "Fred"})
+}
+
+func (c *suite) schema(t *testing.T) {
+	sess := c.session(t)
+
+	names, err := sess.Dialect().TableNames(sess.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for i := 0; i < len(names); i++ {
+		found[names[i]] = true
+	}
+	for _, name := range []string{Owners, Pets, Memberships} {
+		if !found[name] {
+			t.Fatalf("Table %s not in %v", name, names)
+		}
+	}
+
+	// The extracted tables are the model tables
+	diff, err := sess.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Fatal("Expected no differences; got\n", diff)
+	}
+
+	// Created again
+	if err = sess.WriteModelTableSchemaToDB(dalkeeth.WithDropExisting()); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.WriteModelTableSchemaToDB(); err == nil {
+		t.Fatal("Creating existing tables should have failed")
+	}
+}
+
+func (c *suite) saveGet(t *testing.T) {
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+
+	values := map[string]any{
+		"id":      int64(1),
+		"name":    "Fred",
+		"email":   "fred@example.com",
+		"weight":  81.5,
+		"citizen": false,
+		"photo":   []byte{0, 1, 2, 255},
+	}
+	save(t, sess, Owners, values)
+	rec, err := sess.Get(owners, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, rec, values)
+
+	// Unset fields: the default, or NULL
+	save(t, sess, Owners, map[string]any{"id": int64(2), "name": "Wilma"})
+	rec, err = sess.Get(owners, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, rec, map[string]any{"name": "Wilma", "citizen": true, "email": nil, "weight": nil, "photo": nil})
+
+	rec, err = sess.Get(owners, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec != nil {
+		t.Fatal("Expected nil for a missing record; got", rec)
+	}
+
+	// Unique and NOT NULL
+	if err = sess.Save(record(t, owners, map[string]any{"id": int64(3), "name": "Barney", "email": "fred@example.com"})); err == nil {
+		t.Fatal("Saving a duplicate unique value should have failed")
+	}
+	if err = sess.Save(record(t, owners, map[string]any{"id": int64(3)})); err == nil {
+		t.Fatal("Saving without a NOT NULL value should have failed")
+	}
+	if err = sess.Save(record(t, owners, map[string]any{"id": int64(1), "name": "Fred"})); err == nil {
+		t.Fatal("Saving a duplicate primary key should have failed")
+	}
+}
+
+func (c *suite) update(t *testing.T) {
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+	save(t, sess, Owners, map[string]any{"id": int64(1), "name": "Fred", "weight": 80.0})
+	save(t, sess, Owners, map[string]any{"id": int64(2), "name": "Wilma", "weight": 60.0})
+
+	n, err := sess.Update(record(t, owners, map[string]any{"id": int64(1), "name": "Frederick"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("Expected 1 record updated; got", n)
+	}
+	rec, err := sess.Get(owners, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, rec, map[string]any{"name": "Frederick", "weight": 80.0})
+
+	n, err = sess.UpdateWhere(owners, dalkeeth.W("weight", dalkeeth.LT, 70.0), dalkeeth.NewValue(owners.Field("citizen"), false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("Expected 1 record updated; got", n)
+	}
+	rec, err = sess.Get(owners, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, rec, map[string]any{"citizen": false})
+}
+
+func (c *suite) existsDelete(t *testing.T) {
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+	for i := int64(1); i <= 3; i++ {
+		save(t, sess, Owners, map[string]any{"id": i, "name": "Dino", "weight": float64(i)})
+	}
+
+	ok, err := sess.Exists(owners, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Expected record 1 to exist")
+	}
+	n, err := sess.Delete(owners, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("Expected 1 record deleted; got", n)
+	}
+	if ok, err = sess.Exists(owners, 1); err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("Expected record 1 to be deleted")
+	}
+	if n, err = sess.Delete(owners, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal("Expected no record deleted; got", n)
+	}
+
+	if n, err = sess.DeleteWhere(owners, dalkeeth.W("weight", dalkeeth.GT, 1.5)); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatal("Expected 2 records deleted; got", n)
+	}
+}
+
+func (c *suite) compositeKey(t *testing.T) {
+	sess := c.session(t)
+	memberships := sess.TableByKey(Memberships)
+	save(t, sess, Owners, map[string]any{"id": int64(1), "name": "Fred"})
+	save(t, sess, Memberships, map[string]any{"owner_id": int64(1), "club": "bowling", "rank": int64(2)})
+	save(t, sess, Memberships, map[string]any{"owner_id": int64(1), "club": "lodge", "rank": int64(5)})
+
+	rec, err := sess.GetKey(memberships, int64(1), "lodge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, rec, map[string]any{"owner_id": int64(1), "club": "lodge", "rank": int64(5)})
+
+	if _, err = sess.GetKey(memberships, int64(1)); err == nil {
+		t.Fatal("Getting with part of the key should have failed")
+	}
+	if err = sess.Save(record(t, memberships, map[string]any{"owner_id": int64(1), "club": "lodge"})); err == nil {
+		t.Fatal("Saving a duplicate primary key should have failed")
+	}
+
+	n, err := sess.DeleteKey(memberships, int64(1), "bowling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("Expected 1 record deleted; got", n)
+	}
+	ok, err := sess.ExistsKey(memberships, int64(1), "bowling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("Expected the record to be deleted")
+	}
+}
+
+func (c *suite) query(t *testing.T) {
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+	for i, name := range []string{"Fred", "Wilma", "Barney", "Betty", "Pebbles"} {
+		save(t, sess, Owners, map[string]any{"id": int64(i + 1), "name": name, "weight": float64(10 * (i + 1))})
+	}
+
+	q := dalkeeth.NewQuery().Select(owners.Field("id"), owners.Field("name")).From(owners).
+		Where(dalkeeth.W("weight", dalkeeth.GT, 15.0)).
+		OrderBy(dalkeeth.NewOrderBy(owners.Field("name"), dalkeeth.ASC)).
+		Limit(2).Offset(1)
+	rows, err := sess.ExecuteQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		rec, err := rows.Record()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var name string
+		if err = rec.GetString("name", &name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	// Barney, Betty, Pebbles, Wilma
+	if strings.Join(names, ",") != "Betty,Pebbles" {
+		t.Fatal("Expected Betty,Pebbles; got", names)
+	}
+
+	// Offset without limit
+	q = dalkeeth.NewQuery().Select(owners.Field("id")).From(owners).
+		OrderBy(dalkeeth.NewOrderBy(owners.Field("id"), dalkeeth.DESC)).
+		Offset(3)
+	rows2, err := sess.ExecuteQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows2.Close()
+	var ids []int64
+	for rows2.Next() {
+		var id int64
+		if err = rows2.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if err = rows2.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 1 {
+		t.Fatal("Expected [2 1]; got", ids)
+	}
+}
+
+func (c *suite) upsert(t *testing.T) {
+	if c.dialect.Capabilities().Upsert == dalkeeth.NoUpsert {
+		t.Skip("Dialect has no upsert")
+	}
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+
+	if err := sess.Upsert(record(t, owners, map[string]any{"id": int64(1), "name": "Fred", "weight": 80.0})); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Upsert(record(t, owners, map[string]any{"id": int64(1), "name": "Frederick"})); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := sess.Get(owners, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the set fields are updated
+	expect(t, rec, map[string]any{"name": "Frederick", "weight": 80.0})
+}
+
+func (c *suite) foreignKeys(t *testing.T) {
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+	pets := sess.TableByKey(Pets)
+	save(t, sess, Owners, map[string]any{"id": int64(1), "name": "Fred"})
+	save(t, sess, Pets, map[string]any{"id": int64(1), "owner_id": int64(1), "name": "Dino"})
+
+	if err := sess.Save(record(t, pets, map[string]any{"id": int64(2), "owner_id": int64(9)})); err == nil {
+		t.Fatal("Saving a record with a missing foreign record should have failed")
+	}
+
+	// ON DELETE CASCADE
+	if _, err := sess.Delete(owners, 1); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := sess.Exists(pets, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("Expected the pet to be deleted with its owner")
+	}
+}
+
+func (c *suite) rollback(t *testing.T) {
+	sess := c.session(t)
+	owners := sess.TableByKey(Owners)
+
+	if err := sess.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	save(t, sess, Owners, map[string]any{"id": int64(1), "name": "Fred"})
+	ok, err := sess.Exists(owners, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Expected the record to exist in the transaction")
+	}
+	if err = sess.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err = sess.Exists(owners, 1); err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("Expected the record to be rolled back")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build cgo
+
+package conformance
+
+import (
+	"testing"
+
+	"github.com/gnewton/dalkeeth"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSqlite3(t *testing.T) {
+	runSqlite(t, dalkeeth.SqliteCgoDriver)
+}
@@ -0,0 +1,25 @@
+package conformance
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/gnewton/dalkeeth"
+	_ "modernc.org/sqlite"
+)
+
+func runSqlite(t *testing.T, driverName string) {
+	Run(t, new(dalkeeth.DialectSqlite3), func() (*sql.DB, error) {
+		db, err := sql.Open(driverName, ":memory:")
+		if err != nil {
+			return nil, err
+		}
+		// Each connection to :memory: is a different database
+		db.SetMaxOpenConns(1)
+		return db, nil
+	})
+}
+
+func TestSqlitePureGo(t *testing.T) {
+	runSqlite(t, dalkeeth.SqlitePureGoDriver)
+}
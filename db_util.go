@@ -1,37 +1,42 @@
 package dalkeeth
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-	"log"
 )
 
+// Satisfied by *sql.DB and *sql.Tx
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
 // Assumed to work across all DBs ??
 
-func recordExists(db *sql.DB, tableName string, id int64) (bool, error) {
+func recordExists(ctx context.Context, db dbExecutor, d Dialect, tbl *Table, key ...any) (bool, error) {
 	if db == nil {
 		return false, errors.New("DB is nil")
 	}
-	if len(tableName) == 0 {
-		return false, errors.New("Table name is empty string")
+	if tbl == nil || len(tbl.name) == 0 {
+		return false, errors.New("Table is nil or its name is empty string")
 	}
 
-	if id < 0 {
-		return false, fmt.Errorf("Primary key id is < 0: %d", id)
+	q, args, err := d.ExistsSql(tbl, key)
+	if err != nil {
+		return false, err
 	}
-
-	q := "SELECT id from " + tableName + " where id=?"
 	var value int64
 
-	row := db.QueryRow(q, id)
-	err := row.Scan(&value)
+	row := db.QueryRowContext(ctx, q, args...)
+	err = row.Scan(&value)
 	if err != nil {
-		log.Println(err)
 		if err == sql.ErrNoRows {
 			return false, nil
 		}
 		return false, err
 	}
-	return value == id, nil
+	return true, nil
 }
@@ -1,26 +1,40 @@
 package dalkeeth
 
 import (
+	"context"
 	"database/sql"
 )
 
 type Dialect interface {
+	ApplyMigration(ctx context.Context, db *sql.DB, plan *MigrationPlan) error
 	ArbitraryFunc(string, []any) (string, error)
+	Capabilities() Capabilities
 	CreateTableIndexSql(*Index) (string, error)
 	CreateTableSql(*Table) (string, error)
-	DeleteSql(tbl *Table, id int64) (string, error)
+	DeleteSql(tbl *Table, key []any) (string, []any, error)
+	DeleteWhereSql(tbl *Table, cond Condition) (string, []any, error)
 	DialectName() string
+	DropTableSql(*Table) (string, error)
 	ExtractTable(db *sql.DB, tableName string) (*Table, error)
 	FieldAsSql(fa *FieldAs) (string, error)
 	FunctionFieldSql(FunctionField) (string, error)
-	GetSingleRecordSql(*InRecord, int64) (string, error)
+	ExistsSql(tbl *Table, key []any) (string, []any, error)
+	GetSingleRecordSql(rec *InRecord, key []any) (string, []any, error)
+	InitDB(db *sql.DB) error // Called by OpenDB
 	JoinSql(*Join, string, ...*Field) error
+	MigrationPlan(*SchemaDiff) (*MigrationPlan, error)
+	Placeholder(n int) string      // n starts at 1
+	QuoteIdentifier(string) string // Table, field, index and constraint names; see identifier.go
 	SaveSql(*InRecord) (string, error)
-	SelectQuerySql(*SelectQuery) (string, error)
-	SelectQuerySql2(*Query) (string, error)
+	SelectQuerySql(*SelectQuery) (string, []any, error)
+	SelectQuerySql2(*Query) (string, []any, error)
 	Table(*Table) (string, error)
-	ValidTableName(string) error
+	TableNames(db *sql.DB) ([]string, error)
+	UpdateSql(*InRecord) (string, []any, error)
+	UpdateWhereSql(tbl *Table, cond Condition, values []*Value) (string, []any, error)
+	UpsertSql(*InRecord) (string, error) // Same values as SaveSql
+	ValidIdentifier(string) error        // Field, index and constraint names
+	ValidTableName(string) error         // Also checks the table name is not reserved by the database
 
 	//FieldFunction(int, ...Field)
-	//DropTableSql(string)
 }
@@ -0,0 +1,690 @@
+package dalkeeth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DialectMySQL generates MySQL and MariaDB SQL. It does not import a driver: open the database with
+// go-sql-driver/mysql and pass it to OpenDB.
+// The zero value creates InnoDB utf8mb4 tables, with index prefixes of 191 characters.
+// Model names are quoted everywhere, including the fields of conditions (see identifier.go).
+type DialectMySQL struct {
+	Engine  string // Default InnoDB
+	Charset string // Default utf8mb4
+	Collate string // Default: the default collation of the charset
+
+	// Indexed string fields longer than this, and TEXT fields, are indexed on their first IndexPrefixLength
+	// characters. Default 191: 767 bytes of utf8mb4, the index key limit of older row formats
+	IndexPrefixLength int
+}
+
+const (
+	mysqlDefaultEngine            = "InnoDB"
+	mysqlDefaultCharset           = "utf8mb4"
+	mysqlDefaultIndexPrefixLength = 191
+)
+
+// MySQL needs a LIMIT for an OFFSET: the largest LIMIT is no limit
+const mysqlNoLimit = "18446744073709551615"
+
+const mysqlMaxIdentifierLength = 64
+
+func (d *DialectMySQL) DialectName() string {
+	return "MySQL"
+}
+
+// MySQL 8.0 or MariaDB 10.2 or later, for window functions
+func (d *DialectMySQL) Capabilities() Capabilities {
+	return Capabilities{
+		Upsert:              OnDuplicateKey,
+		WindowFunctions:     true,
+		IsTrue:              true,
+		AutoIncrement:       true,
+		MaxParams:           65535,
+		MaxIdentifierLength: mysqlMaxIdentifierLength,
+	}
+}
+
+func (d *DialectMySQL) Placeholder(n int) string {
+	return "?"
+}
+
+func (d *DialectMySQL) Table(t *Table) (string, error) {
+	if err := d.ValidTableName(t.name); err != nil {
+		return "", err
+	}
+	return mysqlQuote(t.name), nil
+}
+
+// `name`, with embedded backticks doubled
+func mysqlQuote(name string) string {
+	return quoteIdentifier(name, "`")
+}
+
+func (d *DialectMySQL) QuoteIdentifier(name string) string {
+	return mysqlQuote(name)
+}
+
+// MySQL does not allow names ending with a space
+func (d *DialectMySQL) ValidIdentifier(name string) error {
+	if err := validIdentifier(d, name, mysqlMaxIdentifierLength); err != nil {
+		return err
+	}
+	if strings.HasSuffix(name, " ") {
+		return fmt.Errorf("Invalid name [%s] for dialect %s: ends with a space", name, d.DialectName())
+	}
+	return nil
+}
+
+func (d *DialectMySQL) ValidTableName(name string) error {
+	return d.ValidIdentifier(name)
+}
+
+// InitDB has nothing to set: InnoDB enforces foreign keys unless foreign_key_checks is turned off
+func (d *DialectMySQL) InitDB(db *sql.DB) error {
+	if db == nil {
+		return errors.New("DB is nil")
+	}
+	return nil
+}
+
+func (d *DialectMySQL) indexPrefixLength() int {
+	if d.IndexPrefixLength > 0 {
+		return d.IndexPrefixLength
+	}
+	return mysqlDefaultIndexPrefixLength
+}
+
+// The field of an index or key, with a prefix length if the field is too long to be indexed whole
+func (d *DialectMySQL) keyPart(f *Field) string {
+	s := mysqlQuote(f.name)
+	if f.fieldType == StringType && (f.length == 0 || f.length > d.indexPrefixLength()) {
+		s += "(" + strconv.Itoa(d.indexPrefixLength()) + ")"
+	}
+	if f.fieldType == ByteArrayType {
+		s += "(" + strconv.Itoa(d.indexPrefixLength()) + ")"
+	}
+	return s
+}
+
+func (d *DialectMySQL) keyParts(fields []*Field) string {
+	parts := make([]string, len(fields))
+	for i := 0; i < len(fields); i++ {
+		parts[i] = d.keyPart(fields[i])
+	}
+	return strings.Join(parts, COMMA_SPACE)
+}
+
+func (d *DialectMySQL) CreateTableIndexSql(ind *Index) (string, error) {
+	if ind == nil {
+		return "", errors.New("Index is nil")
+	}
+	if err := d.ValidIdentifier(d.indexName(ind)); err != nil {
+		return "", err
+	}
+
+	s := "CREATE "
+	if ind.unique {
+		s += "UNIQUE "
+	}
+	s += "INDEX " + mysqlQuote(d.indexName(ind)) + " ON " + mysqlQuote(ind.table.name) + " (" + d.keyParts(ind.fields) + ")"
+
+	return s, nil
+}
+
+// idx_table_field0_field1... unless the index was extracted from a database
+func (d *DialectMySQL) indexName(ind *Index) string {
+	if ind.name != "" {
+		return ind.name
+	}
+	s := "idx_" + ind.table.name
+	for i := 0; i < len(ind.fields); i++ {
+		s += "_" + ind.fields[i].name
+	}
+	return s
+}
+
+func (d *DialectMySQL) DropTableSql(t *Table) (string, error) {
+	if t == nil {
+		return "", errors.New("Table is nil")
+	}
+	if err := d.ValidTableName(t.name); err != nil {
+		return "", err
+	}
+	return "DROP TABLE IF EXISTS " + mysqlQuote(t.name), nil
+}
+
+// The primary key and unique fields are keys after the fields, as they may need index prefix lengths.
+// A single integer primary key is AUTO_INCREMENT.
+func (d *DialectMySQL) CreateTableSql(t *Table) (string, error) {
+	if t == nil {
+		return "", errors.New("Table is nil")
+	}
+	if err := d.ValidTableName(t.name); err != nil {
+		return "", err
+	}
+	if len(t.fields) == 0 {
+		return "", errors.New("Num fields = zero")
+	}
+	if err := validFields(d, t); err != nil {
+		return "", err
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + mysqlQuote(t.name) + " ("
+
+	for i := 0; i < len(t.fields); i++ {
+		fsql, err := d.fieldSql(t.fields[i])
+		if err != nil {
+			return "", err
+		}
+		if i != 0 {
+			s += COMMA_SPACE
+		}
+		s += fsql
+	}
+	if len(t.pks) > 0 {
+		s += ", PRIMARY KEY (" + d.keyParts(t.pks) + ")"
+	}
+	for i := 0; i < len(t.fields); i++ {
+		if t.fields[i].unique {
+			s += ", " + d.uniqueKeySql(t.fields[i])
+		}
+	}
+	for i := 0; i < len(t.foreignKeys); i++ {
+		fk, err := d.foreignKeySql(t.foreignKeys[i])
+		if err != nil {
+			return "", err
+		}
+		s += COMMA_SPACE + fk
+	}
+	s += ") " + d.tableOptions()
+
+	return s, nil
+}
+
+func (d *DialectMySQL) tableOptions() string {
+	engine := d.Engine
+	if engine == "" {
+		engine = mysqlDefaultEngine
+	}
+	charset := d.Charset
+	if charset == "" {
+		charset = mysqlDefaultCharset
+	}
+	s := "ENGINE=" + engine + " DEFAULT CHARSET=" + charset
+	if d.Collate != "" {
+		s += " COLLATE=" + d.Collate
+	}
+	return s
+}
+
+// Named after the field, as MySQL names the key of a UNIQUE field
+func (d *DialectMySQL) uniqueKeySql(f *Field) string {
+	return "UNIQUE KEY " + mysqlQuote(f.name) + " (" + d.keyPart(f) + ")"
+}
+
+// MySQL has no deferrable foreign keys, and InnoDB rejects SET DEFAULT
+func (d *DialectMySQL) foreignKeySql(fk *ForeignKey) (string, error) {
+	if fk.deferrable {
+		return "", fmt.Errorf("Table %s: deferrable foreign keys are not supported by dialect MySQL", fk.tbl.name)
+	}
+	if fk.onDelete == SetDefault || fk.onUpdate == SetDefault {
+		return "", fmt.Errorf("Table %s: foreign key action SET DEFAULT is not supported by dialect MySQL", fk.tbl.name)
+	}
+	s := "FOREIGN KEY (" + quoteFields(d, fk.fields) + ") REFERENCES " + mysqlQuote(fk.foreignTable.name) +
+		" (" + quoteFields(d, fk.foreignFields) + ")"
+	if fk.onDelete != NoAction {
+		s += " ON DELETE " + fk.onDelete.String()
+	}
+	if fk.onUpdate != NoAction {
+		s += " ON UPDATE " + fk.onUpdate.String()
+	}
+	return s, nil
+}
+
+func (d *DialectMySQL) fieldSql(f *Field) (string, error) {
+	ftype, err := d.fieldType(f)
+	if err != nil {
+		return "", err
+	}
+	s := mysqlQuote(f.name) + SPACE + ftype
+
+	if f.notNull || f.pk {
+		s += " NOT NULL"
+	}
+	if autoIncrementKey(f) {
+		s += " AUTO_INCREMENT"
+	}
+	if f.defaultValue != "" {
+		def, err := mysqlDefault(f)
+		if err != nil {
+			return "", err
+		}
+		s += " DEFAULT " + def
+	}
+	return s, nil
+}
+
+func (d *DialectMySQL) fieldType(f *Field) (string, error) {
+	switch f.fieldType {
+	case IntType:
+		return "BIGINT", nil
+	case StringType:
+		if f.length == 0 {
+			return "TEXT", nil
+		}
+		return "VARCHAR(" + strconv.Itoa(f.length) + ")", nil
+	case FloatType:
+		return "DOUBLE", nil
+	case BoolType:
+		return "BOOLEAN", nil
+	case ByteArrayType:
+		return "LONGBLOB", nil
+	}
+	return "", fmt.Errorf("Field %s: type %s not supported by dialect MySQL", f.name, f.fieldType)
+}
+
+// Strings are quoted with single quotes; backslashes are escapes in MySQL strings.
+// MySQL does not allow a default for TEXT: strings with a default need a length (VARCHAR).
+func mysqlDefault(f *Field) (string, error) {
+	if err := defaultTypeMatchesFieldType(f); err != nil {
+		return "", err
+	}
+	switch f.fieldType {
+	case StringType:
+		if f.length == 0 {
+			return "", fmt.Errorf("Field %s: a string without a length is TEXT, which cannot have a default in dialect MySQL", f.name)
+		}
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(f.defaultValue, `\`, `\\`), "'", "''") + "'", nil
+	case BoolType:
+		if _, err := strconv.ParseBool(f.defaultValue); err != nil {
+			return "", fmt.Errorf("Field %s: invalid boolean default [%s]", f.name, f.defaultValue)
+		}
+	}
+	return f.defaultValue, nil
+}
+
+func (d *DialectMySQL) ExtractTable(db *sql.DB, tableName string) (*Table, error) {
+	return d.tableInfo(db, tableName)
+}
+
+// Tables of the current database, by name
+func (d *DialectMySQL) TableNames(db *sql.DB) ([]string, error) {
+	if db == nil {
+		return nil, errors.New("DB is nil")
+	}
+	rows, err := db.Query("SELECT TABLE_NAME FROM information_schema.TABLES" +
+		" WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME")
+	defer closeRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+const mysqlColumnsSql = "SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, CHARACTER_MAXIMUM_LENGTH" +
+	" FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION"
+
+// Indexes, including the primary key and unique keys, with their fields in order
+const mysqlIndexesSql = "SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME" +
+	" FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?" +
+	" ORDER BY INDEX_NAME, SEQ_IN_INDEX"
+
+const mysqlForeignKeysSql = "SELECT kcu.CONSTRAINT_NAME, kcu.COLUMN_NAME, kcu.REFERENCED_TABLE_NAME, kcu.REFERENCED_COLUMN_NAME," +
+	" rc.UPDATE_RULE, rc.DELETE_RULE" +
+	" FROM information_schema.KEY_COLUMN_USAGE kcu" +
+	" JOIN information_schema.REFERENTIAL_CONSTRAINTS rc" +
+	" ON rc.CONSTRAINT_SCHEMA = kcu.CONSTRAINT_SCHEMA AND rc.CONSTRAINT_NAME = kcu.CONSTRAINT_NAME" +
+	" WHERE kcu.TABLE_SCHEMA = DATABASE() AND kcu.TABLE_NAME = ? AND kcu.REFERENCED_TABLE_NAME IS NOT NULL" +
+	" ORDER BY kcu.CONSTRAINT_NAME, kcu.ORDINAL_POSITION"
+
+func (d *DialectMySQL) tableInfo(db *sql.DB, tableName string) (*Table, error) {
+	if db == nil {
+		return nil, errors.New("DB is nil")
+	}
+	if err := d.ValidTableName(tableName); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(mysqlColumnsSql, tableName)
+	defer closeRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	tbl, err := NewTable2(tableName)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, dataType, columnType, nullable string
+		var dflt sql.NullString
+		var length sql.NullInt64
+		if err := rows.Scan(&name, &dataType, &columnType, &nullable, &dflt, &length); err != nil {
+			return nil, err
+		}
+		f, err := mysqlField(name, dataType, columnType, length, nullable == "NO", dflt)
+		if err != nil {
+			return nil, fmt.Errorf("Table %s: %w", tableName, err)
+		}
+		if _, err = tbl.AddField(f); err != nil {
+			return nil, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(tbl.fields) == 0 {
+		return nil, fmt.Errorf("Table %s does not exist", tableName)
+	}
+	closeRows(rows)
+
+	// Before the indexes, as InnoDB makes an index named after each foreign key
+	if err = d.foreignKeyInfo(db, tbl); err != nil {
+		return nil, err
+	}
+	if err = d.indexInfo(db, tbl); err != nil {
+		return nil, err
+	}
+	return tbl, nil
+}
+
+// MySQL does not tell unique keys from unique indexes: single field unique indexes are unique fields,
+// unless named like the indexes created by the dialect (idx_...).
+// Primary key fields are NOT NULL in MySQL, but are not NOT NULL in the model.
+func (d *DialectMySQL) indexInfo(db *sql.DB, tbl *Table) error {
+	rows, err := db.Query(mysqlIndexesSql, tbl.name)
+	defer closeRows(rows)
+	if err != nil {
+		return err
+	}
+
+	type indexInfo struct {
+		name   string
+		unique bool
+		fields []string
+	}
+	var indexes []*indexInfo
+	for rows.Next() {
+		var name string
+		var nonUnique int64
+		var field sql.NullString
+		if err := rows.Scan(&name, &nonUnique, &field); err != nil {
+			return err
+		}
+		if !field.Valid {
+			return fmt.Errorf("Index %s: expression indexes are not supported", name)
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].name != name {
+			indexes = append(indexes, &indexInfo{name: name, unique: nonUnique == 0})
+		}
+		last := indexes[len(indexes)-1]
+		last.fields = append(last.fields, field.String)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	foreignKeys := make(map[string]struct{}, len(tbl.foreignKeys))
+	for i := 0; i < len(tbl.foreignKeys); i++ {
+		foreignKeys[tbl.foreignKeys[i].name] = struct{}{}
+	}
+
+	for i := 0; i < len(indexes); i++ {
+		ind := indexes[i]
+		if _, ok := foreignKeys[ind.name]; ok {
+			continue
+		}
+		if ind.name == "PRIMARY" {
+			for j := 0; j < len(ind.fields); j++ {
+				f := tbl.Field(ind.fields[j])
+				if f == nil {
+					return fmt.Errorf("Table %s: primary key field %s does not exist", tbl.name, ind.fields[j])
+				}
+				f.pk = true
+				f.notNull = false
+				tbl.pks = append(tbl.pks, f)
+			}
+			if len(tbl.pks) == 1 {
+				tbl.pk = tbl.pks[0]
+			}
+			continue
+		}
+		if ind.unique && len(ind.fields) == 1 && !strings.HasPrefix(ind.name, "idx_") {
+			tbl.Field(ind.fields[0]).unique = true
+			continue
+		}
+		if err = tbl.AddIndex(ind.unique, ind.fields...); err != nil {
+			return err
+		}
+		tbl.indexes[len(tbl.indexes)-1].name = ind.name
+	}
+	return nil
+}
+
+// The foreign tables and keys are placeholders with only a name, to be resolved in a model (see ExtractModel).
+// Foreign keys are ordered by constraint name.
+func (d *DialectMySQL) foreignKeyInfo(db *sql.DB, tbl *Table) error {
+	rows, err := db.Query(mysqlForeignKeysSql, tbl.name)
+	defer closeRows(rows)
+	if err != nil {
+		return err
+	}
+
+	var fks []*ForeignKey
+	for rows.Next() {
+		var name, column, foreignTable, foreignColumn, onUpdate, onDelete string
+		if err := rows.Scan(&name, &column, &foreignTable, &foreignColumn, &onUpdate, &onDelete); err != nil {
+			return err
+		}
+		field := tbl.Field(column)
+		if field == nil {
+			return fmt.Errorf("Table %s: foreign key field %s does not exist", tbl.name, column)
+		}
+
+		if len(fks) == 0 || fks[len(fks)-1].name != name {
+			foreignTbl, err := NewTable2(foreignTable)
+			if err != nil {
+				return err
+			}
+			fk := &ForeignKey{
+				name:         name,
+				tbl:          tbl,
+				foreignTable: foreignTbl,
+			}
+			if fk.onDelete, err = ParseForeignKeyAction(onDelete); err != nil {
+				return fmt.Errorf("Table %s: %w", tbl.name, err)
+			}
+			if fk.onUpdate, err = ParseForeignKeyAction(onUpdate); err != nil {
+				return fmt.Errorf("Table %s: %w", tbl.name, err)
+			}
+			fks = append(fks, fk)
+		}
+		fk := fks[len(fks)-1]
+		fk.fields = append(fk.fields, field)
+		fk.foreignFields = append(fk.foreignFields, &Field{name: foreignColumn})
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(fks); i++ {
+		if err = tbl.addForeignKey(fks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mysqlField(name, dataType, columnType string, length sql.NullInt64, notNull bool, dflt sql.NullString) (*Field, error) {
+	f := &Field{
+		name:    name,
+		notNull: notNull,
+	}
+	var err error
+	f.fieldType, err = mysqlFieldType(dataType, columnType)
+	if err != nil {
+		return nil, fmt.Errorf("Field %s: %w", name, err)
+	}
+	if f.fieldType == StringType && strings.Contains(strings.ToLower(dataType), "char") && length.Valid {
+		f.length = int(length.Int64)
+	}
+	if dflt.Valid {
+		f.defaultValue = mysqlDefaultValue(dflt.String, f.fieldType)
+	}
+	return f, nil
+}
+
+// Field type from the information_schema data type; BOOLEAN is TINYINT(1)
+func mysqlFieldType(dataType, columnType string) (FieldType, error) {
+	switch strings.ToLower(dataType) {
+	case "tinyint":
+		if strings.ToLower(columnType) == "tinyint(1)" {
+			return BoolType, nil
+		}
+		return IntType, nil
+	case "bigint", "int", "mediumint", "smallint":
+		return IntType, nil
+	case "varchar", "char", "text", "tinytext", "mediumtext", "longtext":
+		return StringType, nil
+	case "double", "float", "decimal":
+		return FloatType, nil
+	case "blob", "tinyblob", "mediumblob", "longblob", "binary", "varbinary":
+		return ByteArrayType, nil
+	}
+	return FunctionType, fmt.Errorf("unsupported column type [%s]", columnType)
+}
+
+// MySQL returns the default value as is; MariaDB returns it as SQL: 'x', NULL.
+// Boolean defaults are 1 and 0.
+func mysqlDefaultValue(s string, fieldType FieldType) string {
+	if s == "NULL" {
+		return ""
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		s = strings.ReplaceAll(strings.ReplaceAll(s[1:len(s)-1], "''", "'"), `\\`, `\`)
+	}
+	if fieldType == BoolType {
+		switch s {
+		case "1":
+			return "true"
+		case "0":
+			return "false"
+		}
+	}
+	return s
+}
+
+// An AUTO_INCREMENT primary key may be unset
+func (d *DialectMySQL) SaveSql(r *InRecord) (string, error) {
+	return insertSql(d, r)
+}
+
+// Inserts the record, or updates its set fields if there is a record with its primary key or a unique key.
+// VALUES() is deprecated by MySQL 8.0.20, but is the syntax of MariaDB.
+func (d *DialectMySQL) UpsertSql(r *InRecord) (string, error) {
+	s, err := d.SaveSql(r)
+	if err != nil {
+		return "", err
+	}
+	if len(r.table.pks) == 0 {
+		return "", fmt.Errorf("DialectMySQL.Upsert: table %s has no primary key", r.table.name)
+	}
+
+	var set string
+	for i := 0; i < len(r.values); i++ {
+		if r.values[i].isSet && !r.values[i].field.pk {
+			if set != "" {
+				set += COMMA_SPACE
+			}
+			name := mysqlQuote(r.values[i].field.name)
+			set += name + "=VALUES(" + name + ")"
+		}
+	}
+	if set == "" {
+		// Nothing to update: the duplicate is ignored
+		name := mysqlQuote(r.table.pks[0].name)
+		set = name + "=" + name
+	}
+	return s + " ON DUPLICATE KEY UPDATE " + set, nil
+}
+
+func (d *DialectMySQL) GetSingleRecordSql(rec *InRecord, key []any) (string, []any, error) {
+	return getSingleRecordSql(d, rec, key)
+}
+
+func (d *DialectMySQL) ExistsSql(tbl *Table, key []any) (string, []any, error) {
+	return existsSql(d, tbl, key)
+}
+
+// Updates the set fields of the record, by primary key
+func (d *DialectMySQL) UpdateSql(r *InRecord) (string, []any, error) {
+	return updateSql(d, r)
+}
+
+// Updates the values in all the records of the table matching the condition
+func (d *DialectMySQL) UpdateWhereSql(tbl *Table, cond Condition, values []*Value) (string, []any, error) {
+	return updateWhereSql(d, tbl, cond, values)
+}
+
+func (d *DialectMySQL) DeleteSql(tbl *Table, key []any) (string, []any, error) {
+	return deleteSql(d, tbl, key)
+}
+
+func (d *DialectMySQL) DeleteWhereSql(tbl *Table, cond Condition) (string, []any, error) {
+	return deleteWhereSql(d, tbl, cond)
+}
+
+func (d *DialectMySQL) SelectQuerySql(q *SelectQuery) (string, []any, error) {
+	return selectQuerySql(d, q, mysqlNoLimit)
+}
+
+func (d *DialectMySQL) SelectQuerySql2(q *Query) (string, []any, error) {
+	return querySql(d, q, mysqlNoLimit)
+}
+
+func (d *DialectMySQL) JoinSql(*Join, string, ...*Field) error {
+	return NotImplemented
+}
+
+func (d *DialectMySQL) ArbitraryFunc(string, []any) (string, error) {
+	return "", NotImplemented
+}
+
+// RANDOM() is RAND() in MySQL
+func (d *DialectMySQL) FunctionFieldSql(ff FunctionField) (string, error) {
+	s, err := functionFieldSql(d, ff)
+	if err != nil {
+		return "", err
+	}
+	if ff.sqlFunctionId == RANDOM {
+		return "RAND()", nil
+	}
+	return s, nil
+}
+
+func (d *DialectMySQL) FieldAsSql(fa *FieldAs) (string, error) {
+	if fa == nil {
+		return "", errors.New("FieldAs is nil")
+	}
+	if fa.field == nil {
+		return "", errors.New("FieldAs.field is nil")
+	}
+	if len(fa.field.name) == 0 {
+		return "", errors.New("FieldAs.field.name is empty string")
+	}
+	return d.QuoteIdentifier(fa.field.name) + " AS " + d.QuoteIdentifier(fa.alias), nil
+}
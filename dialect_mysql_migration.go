@@ -0,0 +1,157 @@
+package dalkeeth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MigrationPlan orders the changes: added tables (foreign tables first), changed tables, removed tables.
+// MySQL alters tables in place: fields are added, dropped and modified, and keys are dropped and added.
+// Foreign keys can only be dropped when extracted with their name.
+func (d *DialectMySQL) MigrationPlan(diff *SchemaDiff) (*MigrationPlan, error) {
+	if diff == nil {
+		return nil, errors.New("MigrationPlan: diff is nil")
+	}
+	tables, err := diffTables(diff)
+	if err != nil {
+		return nil, err
+	}
+	plan := new(MigrationPlan)
+
+	for i := 0; i < len(tables.added); i++ {
+		if err = d.planCreateTable(plan, tables.added[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < len(tables.changed); i++ {
+		if err = d.planAlterTable(plan, tables.changed[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < len(tables.removed); i++ {
+		plan.Statements = append(plan.Statements, "DROP TABLE "+mysqlQuote(tables.removed[i].name))
+	}
+
+	return plan, nil
+}
+
+// ApplyMigration runs the statements of the plan in order. MySQL commits each table change: if a statement
+// fails, the previous statements are not rolled back.
+func (d *DialectMySQL) ApplyMigration(ctx context.Context, db *sql.DB, plan *MigrationPlan) error {
+	if db == nil {
+		return errors.New("DB is nil")
+	}
+	if plan == nil {
+		return errors.New("ApplyMigration: plan is nil")
+	}
+	for i := 0; i < len(plan.Statements); i++ {
+		if _, err := db.ExecContext(ctx, plan.Statements[i]); err != nil {
+			return fmt.Errorf("ApplyMigration: statement %d of %d: %s: %w", i+1, len(plan.Statements), plan.Statements[i], err)
+		}
+	}
+	return nil
+}
+
+func (d *DialectMySQL) planCreateTable(plan *MigrationPlan, t *Table) error {
+	s, err := d.CreateTableSql(t)
+	if err != nil {
+		return err
+	}
+	plan.Statements = append(plan.Statements, s)
+	for i := 0; i < len(t.indexes); i++ {
+		s, err := d.CreateTableIndexSql(t.indexes[i])
+		if err != nil {
+			return err
+		}
+		plan.Statements = append(plan.Statements, s)
+	}
+	return nil
+}
+
+// Keys and indexes are dropped before the fields are changed, and added after
+func (d *DialectMySQL) planAlterTable(plan *MigrationPlan, changes []*SchemaChange) error {
+	want, have := changes[0].want, changes[0].have
+	alter := "ALTER TABLE " + mysqlQuote(want.name) + SPACE
+	pkChanged := strings.Join(fieldNames(want.pks), ",") != strings.Join(fieldNames(have.pks), ",")
+
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		if c.Kind != Removed {
+			continue
+		}
+		switch c.Object {
+		case ForeignKeyObject:
+			if c.foreignKey.name == "" {
+				return fmt.Errorf("MigrationPlan: table %s: foreign key %s has no name", want.name, c.Name)
+			}
+			plan.Statements = append(plan.Statements, alter+"DROP FOREIGN KEY "+mysqlQuote(c.foreignKey.name))
+		case IndexObject:
+			plan.Statements = append(plan.Statements, "DROP INDEX "+mysqlQuote(d.indexName(c.index))+" ON "+mysqlQuote(want.name))
+		}
+	}
+	if pkChanged && len(have.pks) > 0 {
+		plan.Statements = append(plan.Statements, alter+"DROP PRIMARY KEY")
+	}
+
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		if c.Object != FieldObject {
+			continue
+		}
+		switch c.Kind {
+		case Added:
+			s, err := d.fieldSql(c.field)
+			if err != nil {
+				return err
+			}
+			plan.Statements = append(plan.Statements, alter+"ADD COLUMN "+s)
+			if c.field.unique {
+				plan.Statements = append(plan.Statements, alter+"ADD "+d.uniqueKeySql(c.field))
+			}
+		case Removed:
+			plan.Statements = append(plan.Statements, alter+"DROP COLUMN "+mysqlQuote(c.field.name))
+		case Changed:
+			s, err := d.fieldSql(c.field)
+			if err != nil {
+				return err
+			}
+			plan.Statements = append(plan.Statements, alter+"MODIFY COLUMN "+s)
+			hf := have.Field(c.Name)
+			if c.field.unique && !hf.unique {
+				plan.Statements = append(plan.Statements, alter+"ADD "+d.uniqueKeySql(c.field))
+			} else if !c.field.unique && hf.unique {
+				plan.Statements = append(plan.Statements, alter+"DROP INDEX "+mysqlQuote(c.field.name))
+			}
+		}
+	}
+
+	if pkChanged && len(want.pks) > 0 {
+		plan.Statements = append(plan.Statements, alter+"ADD PRIMARY KEY ("+d.keyParts(want.pks)+")")
+	}
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		if c.Kind != Added {
+			continue
+		}
+		switch c.Object {
+		case IndexObject:
+			s, err := d.CreateTableIndexSql(c.index)
+			if err != nil {
+				return err
+			}
+			plan.Statements = append(plan.Statements, s)
+		case ForeignKeyObject:
+			s, err := d.foreignKeySql(c.foreignKey)
+			if err != nil {
+				return err
+			}
+			plan.Statements = append(plan.Statements, alter+"ADD "+s)
+		}
+	}
+	return nil
+}
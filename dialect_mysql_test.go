@@ -0,0 +1,249 @@
+package dalkeeth
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+const mysqlTestModelJSON = `{"tables": [
+ {"name": "owners",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "email", "type": "string", "length": 255, "notNull": true, "unique": true},
+             {"name": "code", "type": "string", "length": 8, "unique": true},
+             {"name": "bio", "type": "string"},
+             {"name": "citizen", "type": "bool", "default": true},
+             {"name": "nick", "type": "string", "length": 16, "default": "it's"}],
+  "indexes": [{"fields": ["bio", "code"]}]},
+ {"name": "memberships",
+  "fields": [{"name": "owner_id", "type": "int", "pk": true},
+             {"name": "club", "type": "string", "length": 32, "pk": true}],
+  "foreignKeys": [{"field": "owner_id", "table": "owners", "foreignKey": "id", "onDelete": "cascade"}]}
+]}`
+
+func TestDialectMySQL_CreateTableSql(t *testing.T) {
+	m := jsonTestModel(t, mysqlTestModelJSON)
+	d := new(DialectMySQL)
+
+	for table, expected := range map[string]string{
+		"owners": "CREATE TABLE IF NOT EXISTS `owners` (`id` BIGINT NOT NULL AUTO_INCREMENT, `email` VARCHAR(255) NOT NULL," +
+			" `code` VARCHAR(8), `bio` TEXT, `citizen` BOOLEAN DEFAULT true, `nick` VARCHAR(16) DEFAULT 'it''s'," +
+			" PRIMARY KEY (`id`), UNIQUE KEY `email` (`email`(191)), UNIQUE KEY `code` (`code`))" +
+			" ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+		"memberships": "CREATE TABLE IF NOT EXISTS `memberships` (`owner_id` BIGINT NOT NULL, `club` VARCHAR(32) NOT NULL," +
+			" PRIMARY KEY (`owner_id`, `club`), FOREIGN KEY (`owner_id`) REFERENCES `owners` (`id`) ON DELETE CASCADE)" +
+			" ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+	} {
+		s, err := d.CreateTableSql(m.TableByKey(table))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected {
+			t.Fatalf("Expected:\n%s\nGot:\n%s", expected, s)
+		}
+	}
+
+	d = &DialectMySQL{Engine: "Aria", Charset: "latin1", Collate: "latin1_swedish_ci", IndexPrefixLength: 100}
+	s, err := d.CreateTableIndexSql(m.TableByKey("owners").indexes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "CREATE INDEX `idx_owners_bio_code` ON `owners` (`bio`(100), `code`)" {
+		t.Fatal(s)
+	}
+	if s = d.tableOptions(); s != "ENGINE=Aria DEFAULT CHARSET=latin1 COLLATE=latin1_swedish_ci" {
+		t.Fatal(s)
+	}
+
+	bio := m.TableByKey("owners").Field("bio")
+	bio.defaultValue = "none"
+	if _, err = d.CreateTableSql(m.TableByKey("owners")); err == nil {
+		t.Fatal("TEXT field with a default", ShouldHaveFailed)
+	}
+	bio.defaultValue = ""
+
+	fk := m.TableByKey("memberships").foreignKeys[0]
+	fk.deferrable = true
+	if _, err = d.CreateTableSql(m.TableByKey("memberships")); err == nil {
+		t.Fatal("Deferrable foreign key", ShouldHaveFailed)
+	}
+}
+
+func TestDialectMySQL_RecordSql(t *testing.T) {
+	m := jsonTestModel(t, mysqlTestModelJSON)
+	d := new(DialectMySQL)
+	owners := m.TableByKey("owners")
+
+	// AUTO_INCREMENT primary key
+	rec := owners.NewRecord()
+	if err := rec.SetValue("email", "a@b.c"); err != nil {
+		t.Fatal(err)
+	}
+	s, err := d.SaveSql(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "INSERT INTO `owners` (`email`) VALUES (?)" {
+		t.Fatal(s)
+	}
+
+	if err = rec.SetValue("id", 7); err != nil {
+		t.Fatal(err)
+	}
+	s, err = d.UpsertSql(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "INSERT INTO `owners` (`id`, `email`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `email`=VALUES(`email`)" {
+		t.Fatal(s)
+	}
+
+	s, args, err := d.UpdateSql(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "UPDATE `owners` SET `email`=? WHERE `id`=?" || len(args) != 2 {
+		t.Fatal(s, args)
+	}
+
+	memberships := m.TableByKey("memberships")
+	membership := memberships.NewRecord()
+	if err = membership.SetValue("owner_id", 7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.SaveSql(membership); err == nil {
+		t.Fatal("Composite primary key not set", ShouldHaveFailed)
+	}
+	if err = membership.SetValue("club", "chess"); err != nil {
+		t.Fatal(err)
+	}
+	s, err = d.UpsertSql(membership)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "INSERT INTO `memberships` (`owner_id`, `club`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `owner_id`=`owner_id`" {
+		t.Fatal(s)
+	}
+
+	s, args, err = d.GetSingleRecordSql(memberships.NewRecord(), []any{int64(7), "chess"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "SELECT `owner_id`, `club` FROM `memberships` WHERE `owner_id`=? AND `club`=?" || len(args) != 2 {
+		t.Fatal(s, args)
+	}
+
+	s, _, err = d.DeleteSql(memberships, []any{int64(7), "chess"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "DELETE FROM `memberships` WHERE `owner_id`=? AND `club`=?" {
+		t.Fatal(s)
+	}
+
+	q := NewQuery().Select(owners.Field("id")).From(owners).Where(W("email", EQ, "a@b.c")).Offset(5)
+	s, _, err = d.SelectQuerySql2(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "SELECT `owners`.`id` FROM `owners` WHERE `email` = ? LIMIT 18446744073709551615 OFFSET 5" {
+		t.Fatal(s)
+	}
+}
+
+func TestDialectMySQL_MigrationPlan(t *testing.T) {
+	have := jsonTestModel(t, `{"tables": [
+ {"name": "owners",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "email", "type": "string", "length": 64, "unique": true},
+             {"name": "old", "type": "int"}],
+  "indexes": [{"fields": ["old"]}]},
+ {"name": "pets",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "owner_id", "type": "int"}],
+  "foreignKeys": [{"field": "owner_id", "table": "owners", "foreignKey": "id"}]}
+]}`)
+	// As extracted from the database
+	have.TableByKey("pets").foreignKeys[0].name = "pets_ibfk_1"
+
+	want := jsonTestModel(t, `{"tables": [
+ {"name": "owners",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "email", "type": "string", "length": 255, "notNull": true},
+             {"name": "code", "type": "string", "length": 8, "unique": true}]},
+ {"name": "pets",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "owner_id", "type": "int"}],
+  "foreignKeys": [{"field": "owner_id", "table": "owners", "foreignKey": "id", "onDelete": "set_null"}]}
+]}`)
+
+	diff, err := DiffSchema(want, have)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := new(DialectMySQL).MigrationPlan(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"DROP INDEX `idx_owners_old` ON `owners`",
+		"ALTER TABLE `owners` MODIFY COLUMN `email` VARCHAR(255) NOT NULL",
+		"ALTER TABLE `owners` DROP INDEX `email`",
+		"ALTER TABLE `owners` ADD COLUMN `code` VARCHAR(8)",
+		"ALTER TABLE `owners` ADD UNIQUE KEY `code` (`code`)",
+		"ALTER TABLE `owners` DROP COLUMN `old`",
+		"ALTER TABLE `pets` DROP FOREIGN KEY `pets_ibfk_1`",
+		"ALTER TABLE `pets` ADD FOREIGN KEY (`owner_id`) REFERENCES `owners` (`id`) ON DELETE SET NULL",
+	}
+	if got := strings.Join(plan.Statements, "\n"); got != strings.Join(expected, "\n") {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", strings.Join(expected, "\n"), got)
+	}
+
+	have.TableByKey("pets").foreignKeys[0].name = ""
+	if _, err = new(DialectMySQL).MigrationPlan(diff); err == nil {
+		t.Fatal("Dropping an unnamed foreign key", ShouldHaveFailed)
+	}
+}
+
+func TestMysqlExtractionValues(t *testing.T) {
+	for _, c := range []struct {
+		s         string
+		fieldType FieldType
+		expected  string
+	}{
+		{"no-name", StringType, "no-name"},
+		{"'it''s'", StringType, "it's"},
+		{"NULL", StringType, ""},
+		{"1", BoolType, "true"},
+		{"0", BoolType, "false"},
+		{"42", IntType, "42"},
+	} {
+		if got := mysqlDefaultValue(c.s, c.fieldType); got != c.expected {
+			t.Fatalf("%s: expected [%s]; got [%s]", c.s, c.expected, got)
+		}
+	}
+
+	for columnType, expected := range map[string]FieldType{
+		"tinyint(1)": BoolType,
+		"tinyint(4)": IntType,
+	} {
+		got, err := mysqlFieldType("tinyint", columnType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("%s: expected %s; got %s", columnType, expected, got)
+		}
+	}
+
+	f, err := mysqlField("email", "varchar", "varchar(64)", sql.NullInt64{Int64: 64, Valid: true}, true, sql.NullString{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.fieldType != StringType || f.length != 64 || !f.notNull {
+		t.Fatal("Expected NOT NULL varchar(64); got", fieldDescription(f))
+	}
+	if _, err = mysqlFieldType("json", "json"); err == nil {
+		t.Fatal("Unsupported type", ShouldHaveFailed)
+	}
+}
@@ -0,0 +1,695 @@
+package dalkeeth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DialectPostgres generates PostgreSQL SQL. It does not import a driver: open the database with lib/pq or
+// pgx (stdlib) and pass it to OpenDB.
+// Model names are quoted everywhere, including the fields of conditions (see identifier.go), so they keep their case.
+type DialectPostgres struct {
+}
+
+// Postgres accepts an OFFSET without a LIMIT; LIMIT ALL is the same as no LIMIT
+const postgresNoLimit = "ALL"
+
+// Postgres truncates longer identifiers
+const postgresMaxIdentifierLength = 63
+
+func (d *DialectPostgres) DialectName() string {
+	return "Postgres"
+}
+
+func (d *DialectPostgres) Capabilities() Capabilities {
+	return Capabilities{
+		Upsert:                OnConflict,
+		WindowFunctions:       true,
+		PartialIndexes:        true,
+		IsTrue:                true,
+		TransactionalDDL:      true,
+		DeferrableForeignKeys: true,
+		AutoIncrement:         true,
+		Returning:             true,
+		MaxParams:             65535,
+		MaxIdentifierLength:   postgresMaxIdentifierLength,
+	}
+}
+
+func (d *DialectPostgres) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (d *DialectPostgres) Table(t *Table) (string, error) {
+	if err := d.ValidTableName(t.name); err != nil {
+		return "", err
+	}
+	return pgQuote(t.name), nil
+}
+
+// "name", with embedded double quotes doubled
+func pgQuote(name string) string {
+	return quoteIdentifier(name, ansiQuote)
+}
+
+func (d *DialectPostgres) QuoteIdentifier(name string) string {
+	return pgQuote(name)
+}
+
+// Longer names would be truncated by Postgres
+func (d *DialectPostgres) ValidIdentifier(name string) error {
+	return validIdentifier(d, name, postgresMaxIdentifierLength)
+}
+
+func (d *DialectPostgres) ValidTableName(name string) error {
+	if err := d.ValidIdentifier(name); err != nil {
+		return err
+	}
+	if strings.HasPrefix(name, "pg_") {
+		return fmt.Errorf("Invalid table name [%s] for dialect %s", name, d.DialectName())
+	}
+	return nil
+}
+
+// InitDB has nothing to set: Postgres always enforces foreign keys
+func (d *DialectPostgres) InitDB(db *sql.DB) error {
+	if db == nil {
+		return errors.New("DB is nil")
+	}
+	return nil
+}
+
+func (d *DialectPostgres) CreateTableIndexSql(ind *Index) (string, error) {
+	if ind == nil {
+		return "", errors.New("Index is nil")
+	}
+	if err := d.ValidIdentifier(d.indexName(ind)); err != nil {
+		return "", err
+	}
+
+	s := "CREATE "
+	if ind.unique {
+		s += "UNIQUE "
+	}
+	s += "INDEX " + pgQuote(d.indexName(ind)) + " ON " + pgQuote(ind.table.name) + " (" + quoteFields(d, ind.fields) + ")"
+
+	return s, nil
+}
+
+// idx_table_field0_field1... unless the index was extracted from a database
+func (d *DialectPostgres) indexName(ind *Index) string {
+	if ind.name != "" {
+		return ind.name
+	}
+	s := "idx_" + ind.table.name
+	for i := 0; i < len(ind.fields); i++ {
+		s += "_" + ind.fields[i].name
+	}
+	return s
+}
+
+func (d *DialectPostgres) DropTableSql(t *Table) (string, error) {
+	if t == nil {
+		return "", errors.New("Table is nil")
+	}
+	if err := d.ValidTableName(t.name); err != nil {
+		return "", err
+	}
+	return "DROP TABLE IF EXISTS " + pgQuote(t.name), nil
+}
+
+func (d *DialectPostgres) CreateTableSql(t *Table) (string, error) {
+	if t == nil {
+		return "", errors.New("Table is nil")
+	}
+	if err := d.ValidTableName(t.name); err != nil {
+		return "", err
+	}
+	if len(t.fields) == 0 {
+		return "", errors.New("Num fields = zero")
+	}
+	if err := validFields(d, t); err != nil {
+		return "", err
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + pgQuote(t.name) + " ("
+
+	for i := 0; i < len(t.fields); i++ {
+		fsql, err := d.fieldSql(t.fields[i], len(t.pks) == 1)
+		if err != nil {
+			return "", err
+		}
+		if i != 0 {
+			s += COMMA_SPACE
+		}
+		s += fsql
+	}
+	if len(t.pks) > 1 {
+		s += ", PRIMARY KEY (" + quoteFields(d, t.pks) + ")"
+	}
+	for i := 0; i < len(t.foreignKeys); i++ {
+		s += COMMA_SPACE + d.foreignKeySql(t.foreignKeys[i])
+	}
+	s += ")"
+
+	return s, nil
+}
+
+func (d *DialectPostgres) foreignKeySql(fk *ForeignKey) string {
+	s := "FOREIGN KEY (" + quoteFields(d, fk.fields) + ") REFERENCES " + pgQuote(fk.foreignTable.name) +
+		" (" + quoteFields(d, fk.foreignFields) + ")"
+	if fk.onDelete != NoAction {
+		s += " ON DELETE " + fk.onDelete.String()
+	}
+	if fk.onUpdate != NoAction {
+		s += " ON UPDATE " + fk.onUpdate.String()
+	}
+	if fk.deferrable {
+		s += " DEFERRABLE INITIALLY DEFERRED"
+	}
+	return s
+}
+
+// PRIMARY KEY only if inlinePk: composite primary keys are a table constraint
+func (d *DialectPostgres) fieldSql(f *Field, inlinePk bool) (string, error) {
+	ftype, err := d.fieldType(f)
+	if err != nil {
+		return "", err
+	}
+	s := pgQuote(f.name) + SPACE + ftype
+
+	if f.notNull {
+		s += " NOT NULL"
+	}
+	if f.unique {
+		s += " UNIQUE"
+	}
+	// Generated if not set on save
+	if autoIncrementKey(f) {
+		s += " GENERATED BY DEFAULT AS IDENTITY"
+	}
+	if f.pk && inlinePk {
+		s += " PRIMARY KEY"
+	}
+	if f.defaultValue != "" {
+		def, err := pgDefault(f)
+		if err != nil {
+			return "", err
+		}
+		s += " DEFAULT " + def
+	}
+	return s, nil
+}
+
+func (d *DialectPostgres) fieldType(f *Field) (string, error) {
+	switch f.fieldType {
+	case IntType:
+		return "BIGINT", nil
+	case StringType:
+		if f.length == 0 {
+			return "TEXT", nil
+		}
+		return "VARCHAR(" + strconv.Itoa(f.length) + ")", nil
+	case FloatType:
+		return "DOUBLE PRECISION", nil
+	case BoolType:
+		return "BOOLEAN", nil
+	case ByteArrayType:
+		return "BYTEA", nil
+	}
+	return "", fmt.Errorf("Field %s: type %s not supported by dialect Postgres", f.name, f.fieldType)
+}
+
+// Strings are quoted with single quotes; booleans are TRUE or FALSE
+func pgDefault(f *Field) (string, error) {
+	if err := defaultTypeMatchesFieldType(f); err != nil {
+		return "", err
+	}
+	switch f.fieldType {
+	case StringType:
+		return "'" + strings.ReplaceAll(f.defaultValue, "'", "''") + "'", nil
+	case BoolType:
+		b, err := strconv.ParseBool(f.defaultValue)
+		if err != nil {
+			return "", fmt.Errorf("Field %s: invalid boolean default [%s]", f.name, f.defaultValue)
+		}
+		if b {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	}
+	return f.defaultValue, nil
+}
+
+func (d *DialectPostgres) ExtractTable(db *sql.DB, tableName string) (*Table, error) {
+	return d.tableInfo(db, tableName)
+}
+
+// Tables of the current schema, by name
+func (d *DialectPostgres) TableNames(db *sql.DB) ([]string, error) {
+	if db == nil {
+		return nil, errors.New("DB is nil")
+	}
+	rows, err := db.Query("SELECT table_name FROM information_schema.tables" +
+		" WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name")
+	defer closeRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+const pgColumnsSql = "SELECT column_name, data_type, is_nullable, column_default, character_maximum_length" +
+	" FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position"
+
+// Primary key and unique constraint fields, in constraint order
+const pgKeyConstraintsSql = "SELECT tc.constraint_name, tc.constraint_type, kcu.column_name" +
+	" FROM information_schema.table_constraints tc" +
+	" JOIN information_schema.key_column_usage kcu" +
+	" ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name" +
+	" WHERE tc.table_schema = current_schema() AND tc.table_name = $1 AND tc.constraint_type IN ('PRIMARY KEY', 'UNIQUE')" +
+	" ORDER BY tc.constraint_name, kcu.ordinal_position"
+
+func (d *DialectPostgres) tableInfo(db *sql.DB, tableName string) (*Table, error) {
+	if db == nil {
+		return nil, errors.New("DB is nil")
+	}
+	if err := d.ValidTableName(tableName); err != nil {
+		return nil, err
+	}
+
+	pks, uniques, err := d.keyConstraints(db, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(pgColumnsSql, tableName)
+	defer closeRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	tbl, err := NewTable2(tableName)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, dataType, nullable string
+		var dflt sql.NullString
+		var length sql.NullInt64
+		if err := rows.Scan(&name, &dataType, &nullable, &dflt, &length); err != nil {
+			return nil, err
+		}
+		f, err := pgField(name, dataType, length, nullable == "NO", dflt)
+		if err != nil {
+			return nil, fmt.Errorf("Table %s: %w", tableName, err)
+		}
+		if _, err = tbl.AddField(f); err != nil {
+			return nil, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(tbl.fields) == 0 {
+		return nil, fmt.Errorf("Table %s does not exist", tableName)
+	}
+	closeRows(rows)
+
+	if err = d.setKeys(tbl, pks, uniques); err != nil {
+		return nil, err
+	}
+	if err = d.indexInfo(db, tbl); err != nil {
+		return nil, err
+	}
+	if err = d.foreignKeyInfo(db, tbl); err != nil {
+		return nil, err
+	}
+	return tbl, nil
+}
+
+// Fields of the primary key, and of each unique constraint
+func (d *DialectPostgres) keyConstraints(db *sql.DB, tableName string) ([]string, [][]string, error) {
+	rows, err := db.Query(pgKeyConstraintsSql, tableName)
+	defer closeRows(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pks []string
+	var uniques [][]string
+	var previous string
+	for rows.Next() {
+		var name, constraintType, column string
+		if err := rows.Scan(&name, &constraintType, &column); err != nil {
+			return nil, nil, err
+		}
+		if constraintType == "PRIMARY KEY" {
+			pks = append(pks, column)
+			continue
+		}
+		if name != previous {
+			uniques = append(uniques, nil)
+			previous = name
+		}
+		uniques[len(uniques)-1] = append(uniques[len(uniques)-1], column)
+	}
+	return pks, uniques, rows.Err()
+}
+
+// Primary key fields are NOT NULL in Postgres, but are not NOT NULL in the model.
+// Single field unique constraints are set on the field; others are unique indexes.
+func (d *DialectPostgres) setKeys(tbl *Table, pks []string, uniques [][]string) error {
+	for i := 0; i < len(pks); i++ {
+		f := tbl.Field(pks[i])
+		if f == nil {
+			return fmt.Errorf("Table %s: primary key field %s does not exist", tbl.name, pks[i])
+		}
+		f.pk = true
+		f.notNull = false
+		tbl.pks = append(tbl.pks, f)
+	}
+	if len(tbl.pks) == 1 {
+		tbl.pk = tbl.pks[0]
+	}
+
+	for i := 0; i < len(uniques); i++ {
+		if len(uniques[i]) == 1 {
+			f := tbl.Field(uniques[i][0])
+			if f == nil {
+				return fmt.Errorf("Table %s: unique field %s does not exist", tbl.name, uniques[i][0])
+			}
+			f.unique = true
+			continue
+		}
+		if err := tbl.AddIndex(true, uniques[i]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Indexes made by CREATE INDEX; indexes of primary key and unique constraints are not included.
+// Index fields are not reported by information_schema, so the pg_catalog tables are used.
+const pgIndexesSql = "SELECT i.relname, ix.indisunique, a.attname" +
+	" FROM pg_catalog.pg_index ix" +
+	" JOIN pg_catalog.pg_class t ON t.oid = ix.indrelid" +
+	" JOIN pg_catalog.pg_class i ON i.oid = ix.indexrelid" +
+	" JOIN pg_catalog.pg_namespace n ON n.oid = t.relnamespace" +
+	" CROSS JOIN LATERAL unnest(ix.indkey::smallint[]) WITH ORDINALITY AS k(attnum, position)" +
+	" LEFT JOIN pg_catalog.pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum" +
+	" WHERE n.nspname = current_schema() AND t.relname = $1" +
+	" AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_constraint c WHERE c.conindid = ix.indexrelid)" +
+	" ORDER BY i.relname, k.position"
+
+func (d *DialectPostgres) indexInfo(db *sql.DB, tbl *Table) error {
+	rows, err := db.Query(pgIndexesSql, tbl.name)
+	defer closeRows(rows)
+	if err != nil {
+		return err
+	}
+
+	type indexInfo struct {
+		name   string
+		unique bool
+		fields []string
+	}
+	var indexes []*indexInfo
+	for rows.Next() {
+		var name string
+		var unique bool
+		var field sql.NullString
+		if err := rows.Scan(&name, &unique, &field); err != nil {
+			return err
+		}
+		if !field.Valid {
+			return fmt.Errorf("Index %s: expression indexes are not supported", name)
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].name != name {
+			indexes = append(indexes, &indexInfo{name: name, unique: unique})
+		}
+		last := indexes[len(indexes)-1]
+		last.fields = append(last.fields, field.String)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(indexes); i++ {
+		if err = tbl.AddIndex(indexes[i].unique, indexes[i].fields...); err != nil {
+			return err
+		}
+		tbl.indexes[len(tbl.indexes)-1].name = indexes[i].name
+	}
+	return nil
+}
+
+// The referenced fields are matched to the fields by their position in the referenced unique constraint
+const pgForeignKeysSql = "SELECT tc.constraint_name, kcu.column_name, fkcu.table_name, fkcu.column_name," +
+	" rc.update_rule, rc.delete_rule, tc.is_deferrable, tc.initially_deferred" +
+	" FROM information_schema.table_constraints tc" +
+	" JOIN information_schema.key_column_usage kcu" +
+	" ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name" +
+	" JOIN information_schema.referential_constraints rc" +
+	" ON rc.constraint_schema = tc.constraint_schema AND rc.constraint_name = tc.constraint_name" +
+	" JOIN information_schema.key_column_usage fkcu" +
+	" ON fkcu.constraint_schema = rc.unique_constraint_schema AND fkcu.constraint_name = rc.unique_constraint_name" +
+	" AND fkcu.ordinal_position = kcu.position_in_unique_constraint" +
+	" WHERE tc.table_schema = current_schema() AND tc.table_name = $1 AND tc.constraint_type = 'FOREIGN KEY'" +
+	" ORDER BY tc.constraint_name, kcu.ordinal_position"
+
+// The foreign tables and keys are placeholders with only a name, to be resolved in a model (see ExtractModel).
+// Foreign keys are ordered by constraint name, as Postgres does not keep the order of declaration.
+func (d *DialectPostgres) foreignKeyInfo(db *sql.DB, tbl *Table) error {
+	rows, err := db.Query(pgForeignKeysSql, tbl.name)
+	defer closeRows(rows)
+	if err != nil {
+		return err
+	}
+
+	var fks []*ForeignKey
+	for rows.Next() {
+		var name, column, foreignTable, foreignColumn, onUpdate, onDelete, deferrable, deferred string
+		if err := rows.Scan(&name, &column, &foreignTable, &foreignColumn, &onUpdate, &onDelete, &deferrable, &deferred); err != nil {
+			return err
+		}
+		field := tbl.Field(column)
+		if field == nil {
+			return fmt.Errorf("Table %s: foreign key field %s does not exist", tbl.name, column)
+		}
+
+		if len(fks) == 0 || fks[len(fks)-1].name != name {
+			foreignTbl, err := NewTable2(foreignTable)
+			if err != nil {
+				return err
+			}
+			fk := &ForeignKey{
+				name:         name,
+				tbl:          tbl,
+				foreignTable: foreignTbl,
+				deferrable:   deferrable == "YES" && deferred == "YES",
+			}
+			if fk.onDelete, err = ParseForeignKeyAction(onDelete); err != nil {
+				return fmt.Errorf("Table %s: %w", tbl.name, err)
+			}
+			if fk.onUpdate, err = ParseForeignKeyAction(onUpdate); err != nil {
+				return fmt.Errorf("Table %s: %w", tbl.name, err)
+			}
+			fks = append(fks, fk)
+		}
+		fk := fks[len(fks)-1]
+		fk.fields = append(fk.fields, field)
+		fk.foreignFields = append(fk.foreignFields, &Field{name: foreignColumn})
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(fks); i++ {
+		if err = tbl.addForeignKey(fks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pgField(name, dataType string, length sql.NullInt64, notNull bool, dflt sql.NullString) (*Field, error) {
+	f := &Field{
+		name:    name,
+		notNull: notNull,
+	}
+	var err error
+	f.fieldType, err = pgFieldType(dataType)
+	if err != nil {
+		return nil, fmt.Errorf("Field %s: %w", name, err)
+	}
+	if f.fieldType == StringType && length.Valid {
+		f.length = int(length.Int64)
+	}
+	if dflt.Valid {
+		f.defaultValue = pgDefaultValue(dflt.String)
+	}
+	return f, nil
+}
+
+// Field type from the information_schema data type
+func pgFieldType(dataType string) (FieldType, error) {
+	switch strings.ToLower(dataType) {
+	case "bigint", "integer", "smallint":
+		return IntType, nil
+	case "text", "character varying", "character":
+		return StringType, nil
+	case "double precision", "real", "numeric":
+		return FloatType, nil
+	case "boolean":
+		return BoolType, nil
+	case "bytea":
+		return ByteArrayType, nil
+	}
+	return FunctionType, fmt.Errorf("unsupported column type [%s]", dataType)
+}
+
+// Default values are returned by information_schema as SQL expressions: 'x'::text, '-1'::integer, 42, true.
+// Sequences (serial fields) and NULL are no default.
+func pgDefaultValue(s string) string {
+	if strings.HasPrefix(s, "nextval(") || strings.HasPrefix(strings.ToUpper(s), "NULL") {
+		return ""
+	}
+	if strings.HasPrefix(s, "'") {
+		var value string
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				value += string(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				value += "'"
+				i++
+				continue
+			}
+			return value
+		}
+		return value
+	}
+	if i := strings.Index(s, "::"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.Trim(s, "()")
+}
+
+// The primary key is returned by the insert (see Session.Save)
+func (d *DialectPostgres) SaveSql(r *InRecord) (string, error) {
+	s, err := insertSql(d, r)
+	if err != nil {
+		return "", err
+	}
+	if len(r.table.pks) > 0 {
+		s += " RETURNING " + quoteFields(d, r.table.pks)
+	}
+	return s, nil
+}
+
+// Inserts the record, or updates its set fields if there is a record with its primary key
+func (d *DialectPostgres) UpsertSql(r *InRecord) (string, error) {
+	if r == nil {
+		return "", errors.New("DialectPostgres.Upsert: record is nil")
+	}
+	if len(r.table.pks) == 0 {
+		return "", fmt.Errorf("DialectPostgres.Upsert: table %s has no primary key", r.table.name)
+	}
+	s, err := insertSql(d, r)
+	if err != nil {
+		return "", err
+	}
+	pks := quoteFields(d, r.table.pks)
+
+	s += " ON CONFLICT (" + pks + ")"
+
+	var set string
+	for i := 0; i < len(r.values); i++ {
+		if r.values[i].isSet && !r.values[i].field.pk {
+			if set != "" {
+				set += COMMA_SPACE
+			}
+			name := pgQuote(r.values[i].field.name)
+			set += name + "=EXCLUDED." + name
+		}
+	}
+	if set == "" {
+		s += " DO NOTHING"
+	} else {
+		s += " DO UPDATE SET " + set
+	}
+	return s + " RETURNING " + pks, nil
+}
+
+func (d *DialectPostgres) GetSingleRecordSql(rec *InRecord, key []any) (string, []any, error) {
+	return getSingleRecordSql(d, rec, key)
+}
+
+func (d *DialectPostgres) ExistsSql(tbl *Table, key []any) (string, []any, error) {
+	return existsSql(d, tbl, key)
+}
+
+// Updates the set fields of the record, by primary key
+func (d *DialectPostgres) UpdateSql(r *InRecord) (string, []any, error) {
+	return updateSql(d, r)
+}
+
+// Updates the values in all the records of the table matching the condition
+func (d *DialectPostgres) UpdateWhereSql(tbl *Table, cond Condition, values []*Value) (string, []any, error) {
+	return updateWhereSql(d, tbl, cond, values)
+}
+
+func (d *DialectPostgres) DeleteSql(tbl *Table, key []any) (string, []any, error) {
+	return deleteSql(d, tbl, key)
+}
+
+func (d *DialectPostgres) DeleteWhereSql(tbl *Table, cond Condition) (string, []any, error) {
+	return deleteWhereSql(d, tbl, cond)
+}
+
+func (d *DialectPostgres) SelectQuerySql(q *SelectQuery) (string, []any, error) {
+	return selectQuerySql(d, q, postgresNoLimit)
+}
+
+func (d *DialectPostgres) SelectQuerySql2(q *Query) (string, []any, error) {
+	return querySql(d, q, postgresNoLimit)
+}
+
+func (d *DialectPostgres) JoinSql(*Join, string, ...*Field) error {
+	return NotImplemented
+}
+
+func (d *DialectPostgres) ArbitraryFunc(string, []any) (string, error) {
+	return "", NotImplemented
+}
+
+func (d *DialectPostgres) FunctionFieldSql(ff FunctionField) (string, error) {
+	return functionFieldSql(d, ff)
+}
+
+func (d *DialectPostgres) FieldAsSql(fa *FieldAs) (string, error) {
+	if fa == nil {
+		return "", errors.New("FieldAs is nil")
+	}
+	if fa.field == nil {
+		return "", errors.New("FieldAs.field is nil")
+	}
+	if len(fa.field.name) == 0 {
+		return "", errors.New("FieldAs.field.name is empty string")
+	}
+	return d.QuoteIdentifier(fa.field.name) + " AS " + d.QuoteIdentifier(fa.alias), nil
+}
@@ -0,0 +1,199 @@
+package dalkeeth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// MigrationPlan orders the changes: added tables (foreign tables first), changed tables, removed tables.
+// Postgres alters tables in place: fields are added, dropped and altered, and constraints are dropped and added.
+// Constraints not extracted with their name are dropped by the Postgres default name: table_pkey, table_field_key.
+func (d *DialectPostgres) MigrationPlan(diff *SchemaDiff) (*MigrationPlan, error) {
+	if diff == nil {
+		return nil, errors.New("MigrationPlan: diff is nil")
+	}
+	tables, err := diffTables(diff)
+	if err != nil {
+		return nil, err
+	}
+	plan := new(MigrationPlan)
+
+	for i := 0; i < len(tables.added); i++ {
+		if err = d.planCreateTable(plan, tables.added[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < len(tables.changed); i++ {
+		if err = d.planAlterTable(plan, tables.changed[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < len(tables.removed); i++ {
+		plan.Statements = append(plan.Statements, "DROP TABLE "+pgQuote(tables.removed[i].name))
+	}
+
+	return plan, nil
+}
+
+// ApplyMigration runs the plan in a transaction: Postgres table changes are transactional
+func (d *DialectPostgres) ApplyMigration(ctx context.Context, db *sql.DB, plan *MigrationPlan) error {
+	if db == nil {
+		return errors.New("DB is nil")
+	}
+	if plan == nil {
+		return errors.New("ApplyMigration: plan is nil")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(plan.Statements); i++ {
+		if _, err = tx.ExecContext(ctx, plan.Statements[i]); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				log.Println(rollbackErr)
+			}
+			return fmt.Errorf("ApplyMigration: %s: %w", plan.Statements[i], err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *DialectPostgres) planCreateTable(plan *MigrationPlan, t *Table) error {
+	s, err := d.CreateTableSql(t)
+	if err != nil {
+		return err
+	}
+	plan.Statements = append(plan.Statements, s)
+	for i := 0; i < len(t.indexes); i++ {
+		s, err := d.CreateTableIndexSql(t.indexes[i])
+		if err != nil {
+			return err
+		}
+		plan.Statements = append(plan.Statements, s)
+	}
+	return nil
+}
+
+// Constraints and indexes are dropped before the fields are changed, and added after
+func (d *DialectPostgres) planAlterTable(plan *MigrationPlan, changes []*SchemaChange) error {
+	want, have := changes[0].want, changes[0].have
+	alter := "ALTER TABLE " + pgQuote(want.name) + SPACE
+	pkChanged := strings.Join(fieldNames(want.pks), ",") != strings.Join(fieldNames(have.pks), ",")
+
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		if c.Kind != Removed {
+			continue
+		}
+		switch c.Object {
+		case ForeignKeyObject:
+			plan.Statements = append(plan.Statements, alter+"DROP CONSTRAINT "+pgQuote(d.foreignKeyName(c.foreignKey)))
+		case IndexObject:
+			plan.Statements = append(plan.Statements, "DROP INDEX "+pgQuote(d.indexName(c.index)))
+		}
+	}
+	if pkChanged && len(have.pks) > 0 {
+		plan.Statements = append(plan.Statements, alter+"DROP CONSTRAINT "+pgQuote(have.name+"_pkey"))
+	}
+
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		if c.Object != FieldObject {
+			continue
+		}
+		switch c.Kind {
+		case Added:
+			s, err := d.fieldSql(c.field, false)
+			if err != nil {
+				return err
+			}
+			plan.Statements = append(plan.Statements, alter+"ADD COLUMN "+s)
+		case Removed:
+			plan.Statements = append(plan.Statements, alter+"DROP COLUMN "+pgQuote(c.field.name))
+		case Changed:
+			statements, err := d.alterField(want, c.field, have.Field(c.Name))
+			if err != nil {
+				return err
+			}
+			plan.Statements = append(plan.Statements, statements...)
+		}
+	}
+
+	if pkChanged && len(want.pks) > 0 {
+		plan.Statements = append(plan.Statements, alter+"ADD PRIMARY KEY ("+quoteFields(d, want.pks)+")")
+	}
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		if c.Kind != Added {
+			continue
+		}
+		switch c.Object {
+		case IndexObject:
+			s, err := d.CreateTableIndexSql(c.index)
+			if err != nil {
+				return err
+			}
+			plan.Statements = append(plan.Statements, s)
+		case ForeignKeyObject:
+			plan.Statements = append(plan.Statements, alter+"ADD "+d.foreignKeySql(c.foreignKey))
+		}
+	}
+	return nil
+}
+
+// The statements changing the have field to the want field; primary key changes are made by the table
+func (d *DialectPostgres) alterField(tbl *Table, want, have *Field) ([]string, error) {
+	alter := "ALTER TABLE " + pgQuote(tbl.name) + SPACE
+	column := alter + "ALTER COLUMN " + pgQuote(want.name) + SPACE
+	var statements []string
+
+	if want.fieldType != have.fieldType || want.length != have.length {
+		ftype, err := d.fieldType(want)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, column+"TYPE "+ftype+" USING "+pgQuote(want.name)+"::"+ftype)
+	}
+	if want.notNull != have.notNull {
+		if want.notNull {
+			statements = append(statements, column+"SET NOT NULL")
+		} else {
+			statements = append(statements, column+"DROP NOT NULL")
+		}
+	}
+	if want.defaultValue != have.defaultValue {
+		if want.defaultValue == "" {
+			statements = append(statements, column+"DROP DEFAULT")
+		} else {
+			def, err := pgDefault(want)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, column+"SET DEFAULT "+def)
+		}
+	}
+	if want.unique != have.unique {
+		constraint := pgQuote(tbl.name + "_" + want.name + "_key")
+		if want.unique {
+			statements = append(statements, alter+"ADD CONSTRAINT "+constraint+" UNIQUE ("+pgQuote(want.name)+")")
+		} else {
+			statements = append(statements, alter+"DROP CONSTRAINT "+constraint)
+		}
+	}
+	return statements, nil
+}
+
+// The extracted name, or the Postgres default: table_field0_field1_fkey
+func (d *DialectPostgres) foreignKeyName(fk *ForeignKey) string {
+	if fk.name != "" {
+		return fk.name
+	}
+	return fk.tbl.name + "_" + strings.Join(fieldNames(fk.fields), "_") + "_fkey"
+}
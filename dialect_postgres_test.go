@@ -0,0 +1,320 @@
+package dalkeeth
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+const pgTestModelJSON = `{"tables": [
+ {"name": "owners",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "email", "type": "string", "length": 64, "notNull": true, "unique": true},
+             {"name": "weight", "type": "float", "default": 1.5},
+             {"name": "citizen", "type": "bool", "default": true},
+             {"name": "photo", "type": "bytes"},
+             {"name": "nick", "type": "string", "default": "it's"}],
+  "indexes": [{"unique": true, "fields": ["id", "email"]}]},
+ {"name": "memberships",
+  "fields": [{"name": "owner_id", "type": "int", "pk": true},
+             {"name": "club", "type": "string", "pk": true}],
+  "foreignKeys": [{"field": "owner_id", "table": "owners", "foreignKey": "id", "onDelete": "cascade"}]}
+]}`
+
+func jsonTestModel(t *testing.T, s string) *Model {
+	m, err := ReadModelJSON(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestDialectPostgres_CreateTableSql(t *testing.T) {
+	m := jsonTestModel(t, pgTestModelJSON)
+	d := new(DialectPostgres)
+
+	for table, expected := range map[string]string{
+		"owners": `CREATE TABLE IF NOT EXISTS "owners" ("id" BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, "email" VARCHAR(64) NOT NULL UNIQUE,` +
+			` "weight" DOUBLE PRECISION DEFAULT 1.5, "citizen" BOOLEAN DEFAULT TRUE, "photo" BYTEA, "nick" TEXT DEFAULT 'it''s')`,
+		"memberships": `CREATE TABLE IF NOT EXISTS "memberships" ("owner_id" BIGINT, "club" TEXT, PRIMARY KEY ("owner_id", "club"),` +
+			` FOREIGN KEY ("owner_id") REFERENCES "owners" ("id") ON DELETE CASCADE)`,
+	} {
+		s, err := d.CreateTableSql(m.TableByKey(table))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected {
+			t.Fatalf("Expected:\n%s\nGot:\n%s", expected, s)
+		}
+	}
+
+	s, err := d.CreateTableIndexSql(m.TableByKey("owners").indexes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `CREATE UNIQUE INDEX "idx_owners_id_email" ON "owners" ("id", "email")` {
+		t.Fatal(s)
+	}
+
+	if err = d.ValidTableName("pg_class"); err == nil {
+		t.Fatal("pg_ table name", ShouldHaveFailed)
+	}
+}
+
+func TestDialectPostgres_RecordSql(t *testing.T) {
+	m := jsonTestModel(t, pgTestModelJSON)
+	d := new(DialectPostgres)
+	owners := m.TableByKey("owners")
+
+	rec := owners.NewRecord()
+	for name, v := range map[string]any{"id": 7, "email": "a@b.c", "weight": 2.5} {
+		if err := rec.SetValue(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := d.SaveSql(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `INSERT INTO "owners" ("id", "email", "weight") VALUES ($1, $2, $3) RETURNING "id"` {
+		t.Fatal(s)
+	}
+
+	noKey := owners.NewRecord()
+	if err = noKey.SetValue("email", "a@b.c"); err != nil {
+		t.Fatal(err)
+	}
+	s, err = d.SaveSql(noKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `INSERT INTO "owners" ("email") VALUES ($1) RETURNING "id"` {
+		t.Fatal(s)
+	}
+
+	s, err = d.UpsertSql(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `INSERT INTO "owners" ("id", "email", "weight") VALUES ($1, $2, $3) ON CONFLICT ("id")`+
+		` DO UPDATE SET "email"=EXCLUDED."email", "weight"=EXCLUDED."weight" RETURNING "id"` {
+		t.Fatal(s)
+	}
+
+	s, args, err := d.UpdateSql(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `UPDATE "owners" SET "email"=$1, "weight"=$2 WHERE "id"=$3` || len(args) != 3 || args[2] != int64(7) {
+		t.Fatal(s, args)
+	}
+
+	s, args, err = d.GetSingleRecordSql(owners.NewRecord(), []any{int64(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `SELECT "id", "email", "weight", "citizen", "photo", "nick" FROM "owners" WHERE "id"=$1` || len(args) != 1 {
+		t.Fatal(s, args)
+	}
+
+	memberships := m.TableByKey("memberships")
+	s, args, err = d.DeleteSql(memberships, []any{int64(7), "chess"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `DELETE FROM "memberships" WHERE "owner_id"=$1 AND "club"=$2` || len(args) != 2 {
+		t.Fatal(s, args)
+	}
+
+	s, _, err = d.ExistsSql(memberships, []any{int64(7), "chess"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `SELECT 1 FROM "memberships" WHERE "owner_id"=$1 AND "club"=$2` {
+		t.Fatal(s)
+	}
+	if _, _, err = d.ExistsSql(memberships, []any{int64(7)}); err == nil {
+		t.Fatal("Too few key values", ShouldHaveFailed)
+	}
+
+	s, args, err = d.DeleteWhereSql(owners, W("weight", GT, 2.0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `DELETE FROM "owners" WHERE "weight" > $1` || len(args) != 1 {
+		t.Fatal(s, args)
+	}
+}
+
+// The Postgres insert, RETURNING the key, is run on SQLite, which has the same syntax
+func TestDialectPostgres_SaveReturnsKey(t *testing.T) {
+	m := jsonTestModel(t, pgTestModelJSON)
+	owners := m.TableByKey("owners")
+
+	db, err := openTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The identity column: an INTEGER PRIMARY KEY is generated by SQLite
+	if _, err = db.Exec(`CREATE TABLE "owners" ("id" INTEGER PRIMARY KEY, "email" TEXT NOT NULL, "weight" REAL)`); err != nil {
+		t.Fatal(err)
+	}
+	sess, err := OpenDB(m, db, new(DialectPostgres))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	rec := owners.NewRecord()
+	if err = rec.SetValue("email", "a@b.c"); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+	var id int64
+	if err = rec.GetInt("id", &id); err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatal("Expected generated key 1; got", id)
+	}
+
+	rec = owners.NewRecord()
+	if err = rec.SetValue("email", "d@e.f"); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Upsert(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.GetInt("id", &id); err != nil {
+		t.Fatal(err)
+	}
+	if id != 2 {
+		t.Fatal("Expected generated key 2; got", id)
+	}
+}
+
+func TestDialectPostgres_SelectQuerySql2(t *testing.T) {
+	m := jsonTestModel(t, pgTestModelJSON)
+	d := new(DialectPostgres)
+	owners := m.TableByKey("owners")
+
+	q := NewQuery().Select(owners.Field("id")).From(owners).
+		Where(W("email", In, "a", "b"), W("weight", LT, 3.0)).Offset(10)
+	s, args, err := d.SelectQuerySql2(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `SELECT "owners"."id" FROM "owners" WHERE ("email" IN ($1, $2)) AND ("weight" < $3) LIMIT ALL OFFSET 10`
+	if s != expected || len(args) != 3 {
+		t.Fatalf("Expected:\n%s\nGot:\n%s %v", expected, s, args)
+	}
+}
+
+func TestDialectPostgres_MigrationPlan(t *testing.T) {
+	have := jsonTestModel(t, `{"tables": [
+ {"name": "owners",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "email", "type": "string", "notNull": true},
+             {"name": "old", "type": "int"}]},
+ {"name": "pets",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "owner_id", "type": "int"}],
+  "foreignKeys": [{"field": "owner_id", "table": "owners", "foreignKey": "id"}]},
+ {"name": "unused",
+  "fields": [{"name": "id", "type": "int", "pk": true}]}
+]}`)
+	// As extracted from the database
+	have.TableByKey("pets").foreignKeys[0].name = "pets_owner_fk"
+
+	want := jsonTestModel(t, `{"tables": [
+ {"name": "owners",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "email", "type": "string", "length": 64, "unique": true, "default": "none"},
+             {"name": "age", "type": "int", "notNull": true, "default": 0}],
+  "indexes": [{"fields": ["age"]}]},
+ {"name": "pets",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "owner_id", "type": "int"}],
+  "foreignKeys": [{"field": "owner_id", "table": "owners", "foreignKey": "id", "onDelete": "cascade"}]},
+ {"name": "toys",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "pet_id", "type": "int"}],
+  "foreignKeys": [{"field": "pet_id", "table": "pets", "foreignKey": "id"}]}
+]}`)
+
+	diff, err := DiffSchema(want, have)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := new(DialectPostgres).MigrationPlan(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`CREATE TABLE IF NOT EXISTS "toys" ("id" BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY, "pet_id" BIGINT, FOREIGN KEY ("pet_id") REFERENCES "pets" ("id"))`,
+		`ALTER TABLE "owners" ALTER COLUMN "email" TYPE VARCHAR(64) USING "email"::VARCHAR(64)`,
+		`ALTER TABLE "owners" ALTER COLUMN "email" DROP NOT NULL`,
+		`ALTER TABLE "owners" ALTER COLUMN "email" SET DEFAULT 'none'`,
+		`ALTER TABLE "owners" ADD CONSTRAINT "owners_email_key" UNIQUE ("email")`,
+		`ALTER TABLE "owners" ADD COLUMN "age" BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE "owners" DROP COLUMN "old"`,
+		`CREATE INDEX "idx_owners_age" ON "owners" ("age")`,
+		`ALTER TABLE "pets" DROP CONSTRAINT "pets_owner_fk"`,
+		`ALTER TABLE "pets" ADD FOREIGN KEY ("owner_id") REFERENCES "owners" ("id") ON DELETE CASCADE`,
+		`DROP TABLE "unused"`,
+	}
+	if got := strings.Join(plan.Statements, "\n"); got != strings.Join(expected, "\n") {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", strings.Join(expected, "\n"), got)
+	}
+}
+
+func TestPgExtractionValues(t *testing.T) {
+	for s, expected := range map[string]string{
+		"'no-name'::text":                    "no-name",
+		"'it''s'::character varying":         "it's",
+		"'-1'::integer":                      "-1",
+		"42":                                 "42",
+		"true":                               "true",
+		"NULL::text":                         "",
+		"nextval('owners_id_seq'::regclass)": "",
+	} {
+		if got := pgDefaultValue(s); got != expected {
+			t.Fatalf("%s: expected [%s]; got [%s]", s, expected, got)
+		}
+	}
+
+	f, err := pgField("email", "character varying", sql.NullInt64{Int64: 64, Valid: true}, true, sql.NullString{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.fieldType != StringType || f.length != 64 || !f.notNull {
+		t.Fatal("Expected NOT NULL varchar(64); got", fieldDescription(f))
+	}
+	if _, err = pgFieldType("tsvector"); err == nil {
+		t.Fatal("Unsupported type", ShouldHaveFailed)
+	}
+}
+
+func TestPgDefault(t *testing.T) {
+	for value, expected := range map[string]string{
+		"1":     "TRUE",
+		"t":     "TRUE",
+		"true":  "TRUE",
+		"0":     "FALSE",
+		"FALSE": "FALSE",
+	} {
+		got, err := pgDefault(&Field{name: "citizen", fieldType: BoolType, defaultValue: value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("%s: expected [%s]; got [%s]", value, expected, got)
+		}
+	}
+	if _, err := pgDefault(&Field{name: "citizen", fieldType: BoolType, defaultValue: "yes"}); err == nil {
+		t.Fatal("Invalid boolean default", ShouldHaveFailed)
+	}
+}
@@ -1,95 +1,305 @@
 package dalkeeth
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// DialectSqlite3 does not depend on a SQLite driver: the main package imports one of the drivers below, which
+// registers it with database/sql, and opens the database with its name (see Open and SqliteConnector).
+// modernc.org/sqlite is pure Go, for static binaries and cross-compiling without cgo.
 type DialectSqlite3 struct {
 }
 
+// Registered names of the SQLite drivers
+const (
+	SqliteCgoDriver    = "sqlite3" // github.com/mattn/go-sqlite3
+	SqlitePureGoDriver = "sqlite"  // modernc.org/sqlite
+)
+
+// SqliteConnector opens connections with the driver registered as driverName, whatever its name, and turns on
+// foreign key enforcement on each of them. The database is sql.OpenDB(connector).
+func SqliteConnector(driverName, dataSourceName string) (driver.Connector, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	if err = db.Close(); err != nil {
+		return nil, err
+	}
+
+	var connector driver.Connector
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dataSourceName); err != nil {
+			return nil, err
+		}
+	} else {
+		connector = dsnConnector{dsn: dataSourceName, driver: drv}
+	}
+	return sqliteConnector{connector}, nil
+}
+
+// Connector of a driver which does not have one
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type sqliteConnector struct {
+	driver.Connector
+}
+
+func (c sqliteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = execConn(ctx, conn, "PRAGMA foreign_keys = ON"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Exec on a driver connection, which need not implement driver.ExecerContext
+func execConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if execer, ok := stmt.(driver.StmtExecContext); ok {
+		_, err = execer.ExecContext(ctx, nil)
+		return err
+	}
+	_, err = stmt.Exec(nil)
+	return err
+}
+
+// SqliteDSN is the data source name of the database file for one of the known drivers, with its parameter turning
+// on foreign key enforcement for every connection of the pool. Use SqliteConnector with other drivers.
+func SqliteDSN(driverName, file string) (string, error) {
+	if file == "" {
+		return "", errors.New("SqliteDSN: file is empty")
+	}
+	dsn := file
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	return sqliteForeignKeysDSN(driverName, dsn)
+}
+
+// The data source name with the driver parameter turning on foreign keys when each connection is opened
+func sqliteForeignKeysDSN(driverName, dsn string) (string, error) {
+	var param string
+	switch driverName {
+	case SqliteCgoDriver:
+		param = "_foreign_keys=1"
+	case SqlitePureGoDriver:
+		param = "_pragma=foreign_keys(1)"
+	default:
+		return "", fmt.Errorf("SqliteDSN: unknown SQLite driver: %s", driverName)
+	}
+
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + param, nil
+	}
+	return dsn + "?" + param, nil
+}
+
 func (d *DialectSqlite3) DialectName() string {
 	return "Sqlite3"
 }
 
+// SQLite 3.25 or later; the maximum number of placeholders is SQLITE_MAX_VARIABLE_NUMBER as of SQLite 3.32
+func (d *DialectSqlite3) Capabilities() Capabilities {
+	return Capabilities{
+		Upsert:                OnConflict,
+		WindowFunctions:       true,
+		PartialIndexes:        true,
+		IsTrue:                true,
+		TransactionalDDL:      true,
+		DeferrableForeignKeys: true,
+		MaxParams:             32766,
+	}
+}
+
+func (d *DialectSqlite3) Placeholder(n int) string {
+	return "?"
+}
+
 func (d *DialectSqlite3) Table(t *Table) (string, error) {
 	if err := d.ValidTableName(t.name); err != nil {
 		return "", err
 	}
-	return "[" + t.name + "]", nil
+	return d.QuoteIdentifier(t.name), nil
+}
+
+// "name", with embedded double quotes doubled
+func (d *DialectSqlite3) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, ansiQuote)
+}
+
+// SQLite has no length limit
+func (d *DialectSqlite3) ValidIdentifier(name string) error {
+	return validIdentifier(d, name, 0)
 }
 
 func (d *DialectSqlite3) CreateTableIndexSql(ind *Index) (string, error) {
 	if ind == nil {
 		return "", errors.New("Index is nil")
 	}
+	if err := d.ValidIdentifier(d.indexName(ind)); err != nil {
+		return "", err
+	}
 
 	s := "CREATE "
 	if ind.unique {
 		s += "UNIQUE"
 	}
-	s += " INDEX idx_" + ind.table.name
+	s += " INDEX " + d.QuoteIdentifier(d.indexName(ind))
 
-	for i := 0; i < len(ind.fields); i++ {
-		s += "_" + ind.fields[i].name
-	}
+	s += " ON " + d.QuoteIdentifier(ind.table.name) + "(" + quoteFields(d, ind.fields) + ")"
 
-	s += " ON " + ind.table.name + "("
+	return s, nil
+}
 
+// idx_table_field0_field1... unless the index was extracted from a database
+func (d *DialectSqlite3) indexName(ind *Index) string {
+	if ind.name != "" {
+		return ind.name
+	}
+	s := "idx_" + ind.table.name
 	for i := 0; i < len(ind.fields); i++ {
-		if i != 0 {
-			s += COMMA_SPACE
-		}
-		s += ind.fields[i].name
+		s += "_" + ind.fields[i].name
 	}
+	return s
+}
 
-	s += ")"
-
-	return s, nil
+func (d *DialectSqlite3) SelectQuerySql(q *SelectQuery) (string, []any, error) {
+	return selectQuerySql(d, q, sqliteNoLimit)
 }
-func (d *DialectSqlite3) SelectQuerySql(q *SelectQuery) (string, error) {
 
+// Sqlite needs a LIMIT for an OFFSET
+const sqliteNoLimit = "-1"
+
+// noLimit is the LIMIT of the dialect for no limit, used when there is only an OFFSET
+func selectQuerySql(d Dialect, q *SelectQuery, noLimit string) (string, []any, error) {
+	if q == nil {
+		return "", nil, errors.New("SelectQuery is nil")
+	}
 	if !q.Validated() {
-		return "", errors.New("SelectQuery not validated")
+		return "", nil, errors.New("SelectQuery not validated")
 	}
+	args := NewArgs(d)
 
 	s := "SELECT "
 	if q.Distinct {
 		s += "DISTINCT "
 	}
 
-	s += d.makeFields(q.Fields)
-	if len(q.Pks) != 0 {
-		s += d.makePks(q.Pks)
-	} else {
-		s += d.makeWhereClause(q.Where)
+	fields, err := makeFields(d, q.Fields)
+	if err != nil {
+		return "", nil, err
+	}
+	s += fields
+
+	from, err := makeFrom(d, q.From)
+	if err != nil {
+		return "", nil, err
 	}
-	s += d.makeGroupBy(q.GroupBy)
-	s += d.makeHaving(q.Having)
-	if q.Limit > 0 {
-		s += " LIMIT " + strconv.FormatInt(q.Limit, 10)
+	s += " FROM " + from
+
+	where, err := selectWhereClause(q, args)
+	if err != nil {
+		return "", nil, err
+	}
+	s += where
+
+	s += makeGroupBy(d, q.GroupBy)
+
+	having, err := makeHaving(q.Having, args)
+	if err != nil {
+		return "", nil, err
+	}
+	s += having
+
+	s += makeOrderBy(d, q)
+
+	limit := minLimit(q.Limit, q.SelectLimit)
+	if limit > 0 {
+		s += " LIMIT " + strconv.FormatInt(limit, 10)
 	}
 	if q.Offset > 0 {
+		if limit == 0 {
+			s += " LIMIT " + noLimit
+		}
 		s += " OFFSET " + strconv.FormatInt(q.Offset, 10)
 	}
 
-	if q.GlobalOrdering != NoOrdering {
-		s += "ORDER BY "
-		s += d.makeOrderByFields(q.OrderByFields)
-		if q.GlobalOrdering == ASC {
-			s += " ASC"
-		} else {
-			s += " DESC"
-		}
+	return s, args.Values(), nil
+}
+
+// InitDB turns on foreign keys, which are not enforced by default in SQLite. The pragma is set per connection:
+// Open, SqliteConnector and SqliteDSN set it on every connection of the pool, and InitDB sets it if db is limited
+// to one connection. A pool of more connections opened otherwise is used as it is, with foreign keys
+// enforced only if the caller turns them on.
+func (d *DialectSqlite3) InitDB(db *sql.DB) error {
+	on, err := sqliteForeignKeys(db)
+	if err != nil || on || db.Stats().MaxOpenConnections != 1 {
+		return err
 	}
 
-	log.Fatal("NotImplemented")
+	if _, err = db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		return err
+	}
+	if on, err = sqliteForeignKeys(db); err != nil {
+		return err
+	}
+	if !on {
+		return errors.New("DialectSqlite3.InitDB: unable to turn on foreign keys")
+	}
+	return nil
+}
 
-	return "", nil
+func sqliteForeignKeys(db *sql.DB) (bool, error) {
+	var on int64
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&on); err != nil {
+		return false, err
+	}
+	return on == 1, nil
+}
+
+func (d *DialectSqlite3) DropTableSql(t *Table) (string, error) {
+	if t == nil {
+		return "", errors.New("Table is nil")
+	}
+	if err := d.ValidTableName(t.name); err != nil {
+		return "", err
+	}
+	return "DROP TABLE IF EXISTS " + d.QuoteIdentifier(t.name), nil
 }
 
 func (d *DialectSqlite3) CreateTableSql(t *Table) (string, error) {
@@ -102,11 +312,14 @@ func (d *DialectSqlite3) CreateTableSql(t *Table) (string, error) {
 		return "", err
 	}
 
-	s := "CREATE TABLE IF NOT EXISTS " + t.name
+	s := "CREATE TABLE IF NOT EXISTS " + d.QuoteIdentifier(t.name)
 
 	if len(t.fields) == 0 {
 		return "", errors.New("Num fields = zero")
 	}
+	if err = validFields(d, t); err != nil {
+		return "", err
+	}
 
 	s += " ("
 
@@ -120,6 +333,9 @@ func (d *DialectSqlite3) CreateTableSql(t *Table) (string, error) {
 		}
 		s += fsql
 	}
+	if len(t.pks) > 1 {
+		s += ", PRIMARY KEY(" + quoteFields(d, t.pks) + ")"
+	}
 
 	foreignKeysSql, err := d.foreignKeys(t.foreignKeys)
 	if err != nil {
@@ -139,14 +355,24 @@ func (d *DialectSqlite3) foreignKeys(fKeys []*ForeignKey) (string, error) {
 	var s string
 	for i := 0; i < len(fKeys); i++ {
 		fk := fKeys[i]
-		s += ", FOREIGN KEY(" + fk.field.name + ") REFERENCES " + fk.foreignTable.name + "(" + fk.field.name + ")"
+		s += ", FOREIGN KEY(" + quoteFields(d, fk.fields) + ") REFERENCES " + d.QuoteIdentifier(fk.foreignTable.name) +
+			"(" + quoteFields(d, fk.foreignFields) + ")"
+		if fk.onDelete != NoAction {
+			s += " ON DELETE " + fk.onDelete.String()
+		}
+		if fk.onUpdate != NoAction {
+			s += " ON UPDATE " + fk.onUpdate.String()
+		}
+		if fk.deferrable {
+			s += " DEFERRABLE INITIALLY DEFERRED"
+		}
 	}
 
 	return s, nil
 }
 
 func (d *DialectSqlite3) fieldSql(f *Field) (string, error) {
-	s := f.name + SPACE
+	s := d.QuoteIdentifier(f.name) + SPACE
 
 	switch f.fieldType {
 	case IntType:
@@ -162,6 +388,8 @@ func (d *DialectSqlite3) fieldSql(f *Field) (string, error) {
 
 	case BoolType:
 		s += "BOOLEAN"
+	case ByteArrayType:
+		s += "BLOB"
 	}
 	if f.notNull {
 		s += " NOT NULL"
@@ -169,7 +397,7 @@ func (d *DialectSqlite3) fieldSql(f *Field) (string, error) {
 	if f.unique {
 		s += " UNIQUE"
 	}
-	if f.pk {
+	if f.pk && (f.table == nil || len(f.table.pks) == 1) {
 		s += " PRIMARY KEY"
 	}
 	if f.defaultValue != "" {
@@ -188,40 +416,40 @@ func defaultTypeMatchesFieldType(f *Field) error {
 	var err error
 	switch f.fieldType {
 	case IntType:
-		_, err = strconv.ParseUint(f.defaultValue, 10, 64)
+		_, err = strconv.ParseInt(f.defaultValue, 10, 64)
 	case FloatType:
 		_, err = strconv.ParseFloat(f.defaultValue, 64)
 	}
 	return err
 }
 
+// Strings are quoted with single quotes
 func makeDefault(f *Field) (string, error) {
 	if err := defaultTypeMatchesFieldType(f); err != nil {
 		return "", err
 	}
 
 	def := " DEFAULT "
-	//return `" + quote(f.defaultValue) + "`"
-	var delimiter = ""
 	if f.fieldType == StringType {
-		delimiter = "`"
+		return def + quote(f.defaultValue), nil
 	}
-	def += delimiter + quote(f.defaultValue) + delimiter
-
-	return def, nil
+	return def + f.defaultValue, nil
 }
 
+// 'the string', with embedded single quotes doubled
 func quote(s string) string {
-	return strings.ReplaceAll(s, "`", "``")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
 }
 
+// Names starting with sqlite_ are reserved for SQLite
 func (d *DialectSqlite3) ValidTableName(name string) error {
-	if len(name) == 0 || strings.HasPrefix(name, "sqlite_") {
+	if err := d.ValidIdentifier(name); err != nil {
+		return err
+	}
+	if strings.HasPrefix(strings.ToLower(name), "sqlite_") {
 		return fmt.Errorf("Invalid table name [%s] for dialect %s", name, d.DialectName())
 	}
-
 	return nil
-
 }
 
 func closeRows(rows *sql.Rows) {
@@ -237,16 +465,40 @@ func (d *DialectSqlite3) ExtractTable(db *sql.DB, tableName string) (*Table, err
 	return d.tableInfo(db, tableName)
 }
 
+// Tables in the database, except the sqlite_ internal tables, by name
+func (d *DialectSqlite3) TableNames(db *sql.DB) ([]string, error) {
+	if db == nil {
+		return nil, errors.New("DB is nil")
+	}
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	defer closeRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
 func (d *DialectSqlite3) tableInfo(db *sql.DB, tableName string) (*Table, error) {
 	if db == nil {
 		return nil, errors.New("DB is nil")
 	}
-	q := fmt.Sprintf("PRAGMA table_info(%s);", tableName)
+	if err := d.ValidTableName(tableName); err != nil {
+		return nil, err
+	}
+	q := "PRAGMA table_info(" + d.QuoteIdentifier(tableName) + ");"
 	var cid, notNull, pk int64
 	var name, ftype string
 	var dflt_value sql.NullString
 
-	//log.Println(q)
 	rows, err := db.Query(q)
 	defer closeRows(rows)
 
@@ -254,18 +506,246 @@ func (d *DialectSqlite3) tableInfo(db *sql.DB, tableName string) (*Table, error)
 		return nil, err
 	}
 
+	tbl, err := NewTable2(tableName)
+	if err != nil {
+		return nil, err
+	}
+	// pk is the position of the field in the primary key, from 1
+	pkPositions := make(map[*Field]int64)
 	for rows.Next() {
 		if err := rows.Scan(&cid, &name, &ftype, &notNull, &dflt_value, &pk); err != nil {
 			return nil, err
 		}
-		log.Println("tableName:", cid, name, ftype, notNull, "[", dflt_value, "]", pk)
+		f, err := sqliteField(name, ftype, notNull != 0, dflt_value, pk != 0)
+		if err != nil {
+			return nil, fmt.Errorf("Table %s: %w", tableName, err)
+		}
+		if _, err = tbl.AddField(f); err != nil {
+			return nil, err
+		}
+		pkPositions[f] = pk
+	}
+	sort.SliceStable(tbl.pks, func(i, j int) bool {
+		return pkPositions[tbl.pks[i]] < pkPositions[tbl.pks[j]]
+	})
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(tbl.fields) == 0 {
+		return nil, fmt.Errorf("Table %s does not exist", tableName)
 	}
 
-	return nil, nil
+	// Before the next queries, which need the connection with an in-memory database
+	closeRows(rows)
+
+	if err = d.indexInfo(db, tbl); err != nil {
+		return nil, err
+	}
+	if err = d.foreignKeyInfo(db, tbl); err != nil {
+		return nil, err
+	}
+
+	return tbl, nil
 }
 
-// Only use fields that have set values
-func insertFields(r *InRecord) (string, error) {
+// Indexes created by CREATE INDEX, and by UNIQUE constraints; single field UNIQUE constraints are set on the field
+func (d *DialectSqlite3) indexInfo(db *sql.DB, tbl *Table) error {
+	type indexList struct {
+		name   string
+		unique bool
+		origin string
+	}
+	var indexes []indexList
+
+	rows, err := db.Query("PRAGMA index_list(" + d.QuoteIdentifier(tbl.name) + ");")
+	defer closeRows(rows)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var seq, unique, partial int64
+		var il indexList
+		if err := rows.Scan(&seq, &il.name, &unique, &il.origin, &partial); err != nil {
+			return err
+		}
+		il.unique = unique != 0
+		indexes = append(indexes, il)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	closeRows(rows)
+
+	// PRAGMA index_list lists the most recent index first
+	for i := len(indexes) - 1; i >= 0; i-- {
+		il := indexes[i]
+		if il.origin == "pk" {
+			continue
+		}
+		fields, err := d.indexFields(db, il.name)
+		if err != nil {
+			return err
+		}
+		if il.origin == "u" && len(fields) == 1 {
+			tbl.Field(fields[0]).unique = true
+			continue
+		}
+		if err = tbl.AddIndex(il.unique, fields...); err != nil {
+			return err
+		}
+		tbl.indexes[len(tbl.indexes)-1].name = il.name
+	}
+	return nil
+}
+
+func (d *DialectSqlite3) indexFields(db *sql.DB, indexName string) ([]string, error) {
+	rows, err := db.Query("PRAGMA index_info(" + d.QuoteIdentifier(indexName) + ");")
+	defer closeRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for rows.Next() {
+		var seqno, cid int64
+		var name sql.NullString
+		if err := rows.Scan(&seqno, &cid, &name); err != nil {
+			return nil, err
+		}
+		if !name.Valid {
+			return nil, fmt.Errorf("Index %s: expression indexes are not supported", indexName)
+		}
+		fields = append(fields, name.String)
+	}
+	return fields, rows.Err()
+}
+
+// The foreign tables and keys are placeholders with only a name, to be resolved in a model (see ExtractModel).
+// The foreign key names are empty if they are the primary key of the foreign table.
+// Whether a foreign key is deferrable is not reported by SQLite, and is not extracted.
+func (d *DialectSqlite3) foreignKeyInfo(db *sql.DB, tbl *Table) error {
+	rows, err := db.Query("PRAGMA foreign_key_list(" + d.QuoteIdentifier(tbl.name) + ");")
+	defer closeRows(rows)
+	if err != nil {
+		return err
+	}
+
+	// The fields of a composite foreign key are rows with the same id
+	fks := make(map[int64]*ForeignKey)
+	var ids []int64
+	for rows.Next() {
+		var id, seq int64
+		var table, from, onUpdate, onDelete, match string
+		var to sql.NullString
+		if err := rows.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			return err
+		}
+		field := tbl.Field(from)
+		if field == nil {
+			return fmt.Errorf("Table %s: foreign key field %s does not exist", tbl.name, from)
+		}
+
+		fk, ok := fks[id]
+		if !ok {
+			foreignTbl, err := NewTable2(table)
+			if err != nil {
+				return err
+			}
+			fk = &ForeignKey{
+				tbl:          tbl,
+				foreignTable: foreignTbl,
+			}
+			if fk.onDelete, err = ParseForeignKeyAction(onDelete); err != nil {
+				return fmt.Errorf("Table %s: %w", tbl.name, err)
+			}
+			if fk.onUpdate, err = ParseForeignKeyAction(onUpdate); err != nil {
+				return fmt.Errorf("Table %s: %w", tbl.name, err)
+			}
+			fks[id] = fk
+			ids = append(ids, id)
+		}
+		if seq != int64(len(fk.fields)) {
+			return fmt.Errorf("Table %s: foreign key %d: unexpected field sequence %d", tbl.name, id, seq)
+		}
+		fk.fields = append(fk.fields, field)
+		fk.foreignFields = append(fk.foreignFields, &Field{name: to.String})
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// foreign_key_list is in reverse order of declaration
+	for i := len(ids) - 1; i >= 0; i-- {
+		if err = tbl.addForeignKey(fks[ids[i]]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sqliteField(name, declType string, notNull bool, dflt sql.NullString, pk bool) (*Field, error) {
+	f := &Field{
+		name:    name,
+		notNull: notNull,
+		pk:      pk,
+	}
+	var err error
+	f.fieldType, f.length, err = sqliteFieldType(declType)
+	if err != nil {
+		return nil, fmt.Errorf("Field %s: %w", name, err)
+	}
+	if dflt.Valid && strings.ToUpper(dflt.String) != "NULL" {
+		f.defaultValue = unquoteSqlite(dflt.String)
+	}
+	return f, nil
+}
+
+// Field type from the declared column type, following the sqlite type affinity rules
+func sqliteFieldType(declType string) (FieldType, int, error) {
+	t := strings.ToUpper(strings.TrimSpace(declType))
+	switch {
+	case strings.Contains(t, "BOOL"):
+		return BoolType, 0, nil
+	case strings.Contains(t, "INT"):
+		return IntType, 0, nil
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return StringType, sqliteTypeLength(t), nil
+	case t == "", strings.Contains(t, "BLOB"):
+		return ByteArrayType, 0, nil
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"), strings.Contains(t, "NUM"), strings.Contains(t, "DEC"):
+		return FloatType, 0, nil
+	}
+	return FunctionType, 0, fmt.Errorf("unsupported column type [%s]", declType)
+}
+
+// n of VARCHAR(n); 0 if none
+func sqliteTypeLength(t string) int {
+	open := strings.Index(t, "(")
+	end := strings.Index(t, ")")
+	if open < 0 || end < open {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(t[open+1 : end]))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Default values are returned by PRAGMA table_info as SQL text: 'x', "x" or `x`
+func unquoteSqlite(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	q := s[0]
+	if (q == '\'' || q == '"' || q == '`') && s[len(s)-1] == q {
+		return strings.ReplaceAll(s[1:len(s)-1], string([]byte{q, q}), string(q))
+	}
+	return s
+}
+
+// Only use fields that have set values; the auto increment primary key may be unset if the dialect has them
+func insertFields(d Dialect, r *InRecord) (string, error) {
 	s := ""
 
 	first := true
@@ -278,12 +758,12 @@ func insertFields(r *InRecord) (string, error) {
 				s += COMMA_SPACE
 			}
 			first = false
-			s += v.field.name
+			s += d.QuoteIdentifier(v.field.name)
 		} else {
 			if v.field.notNull {
 				return "", errors.New("Field " + v.field.name + " must be set: not null")
 			}
-			if v.field.pk {
+			if v.field.pk && !(d.Capabilities().AutoIncrement && autoIncrementKey(v.field)) {
 				return "", errors.New("Field " + v.field.name + " must be set: primary key")
 			}
 		}
@@ -294,8 +774,13 @@ func insertFields(r *InRecord) (string, error) {
 	return s, nil
 }
 
+// A single integer primary key; it is generated on save by the dialects with Capabilities.AutoIncrement
+func autoIncrementKey(f *Field) bool {
+	return f.pk && f.fieldType == IntType && f.table != nil && len(f.table.pks) == 1
+}
+
 // Only use fields that have set values
-func wantedFields(r *InRecord) (string, error) {
+func wantedFields(d Dialect, r *InRecord) (string, error) {
 	s := ""
 
 	first := true
@@ -308,7 +793,7 @@ func wantedFields(r *InRecord) (string, error) {
 				s += COMMA_SPACE
 			}
 			first = false
-			s += v.field.name
+			s += d.QuoteIdentifier(v.field.name)
 		}
 	}
 	if first {
@@ -317,25 +802,75 @@ func wantedFields(r *InRecord) (string, error) {
 	return s, nil
 }
 
-func (d *DialectSqlite3) GetSingleRecordSql(rec *InRecord, id int64) (string, error) {
+func (d *DialectSqlite3) GetSingleRecordSql(rec *InRecord, key []any) (string, []any, error) {
+	return getSingleRecordSql(d, rec, key)
+}
+
+func getSingleRecordSql(d Dialect, rec *InRecord, key []any) (string, []any, error) {
 
 	if rec == nil {
-		return "", errors.New("InRecord is nil")
+		return "", nil, errors.New("InRecord is nil")
 	}
 
 	s := "SELECT "
 
-	wanted, err := wantedFields(rec)
+	wanted, err := wantedFields(d, rec)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+
+	args := NewArgs(d)
+	where, err := keyWhere(d, rec.table, key, args)
+	if err != nil {
+		return "", nil, err
 	}
 
-	s += wanted + " FROM " + rec.table.name + " WHERE " + ID + "=" + strconv.FormatInt(id, 10)
+	s += wanted + " FROM " + d.QuoteIdentifier(rec.table.name) + " WHERE " + where
+
+	return s, args.Values(), nil
+}
+
+func (d *DialectSqlite3) ExistsSql(tbl *Table, key []any) (string, []any, error) {
+	return existsSql(d, tbl, key)
+}
+
+func existsSql(d Dialect, tbl *Table, key []any) (string, []any, error) {
+	if tbl == nil {
+		return "", nil, fmt.Errorf("Dialect%s.Exists: table is nil", d.DialectName())
+	}
+	args := NewArgs(d)
+	where, err := keyWhere(d, tbl, key, args)
+	if err != nil {
+		return "", nil, err
+	}
+	return "SELECT 1 FROM " + d.QuoteIdentifier(tbl.name) + " WHERE " + where, args.Values(), nil
+}
 
+// pk1=? AND pk2=? for the primary key fields of the table, with the key values in order
+func keyWhere(d Dialect, tbl *Table, key []any, args *Args) (string, error) {
+	if len(tbl.pks) == 0 {
+		return "", fmt.Errorf("Table %s has no primary key", tbl.name)
+	}
+	if len(key) != len(tbl.pks) {
+		return "", fmt.Errorf("Table %s: primary key has %d fields; have %d values", tbl.name, len(tbl.pks), len(key))
+	}
+	var s string
+	for i := 0; i < len(tbl.pks); i++ {
+		if key[i] == nil {
+			return "", fmt.Errorf("Table %s: primary key field %s value is nil", tbl.name, tbl.pks[i].name)
+		}
+		if err := validTypeForField(key[i], tbl.pks[i]); err != nil {
+			return "", err
+		}
+		if i != 0 {
+			s += LAnd.String()
+		}
+		s += d.QuoteIdentifier(tbl.pks[i].name) + "=" + args.Add(key[i])
+	}
 	return s, nil
 }
 
-func (d *DialectSqlite3) valuesPlaceholders(r *InRecord) (string, error) {
+func valuesPlaceholders(d Dialect, r *InRecord) (string, error) {
 	s := ""
 
 	first := true
@@ -348,24 +883,29 @@ func (d *DialectSqlite3) valuesPlaceholders(r *InRecord) (string, error) {
 				s += COMMA_SPACE
 			}
 			first = false
-			s += "$" + strconv.Itoa(placeHolderCount)
+			s += d.Placeholder(placeHolderCount)
 			placeHolderCount++
 		}
 	}
 	if first {
-		return "", errors.New("DialectSqlite3.valuesPlaceholders: No fields set in record")
+		return "", fmt.Errorf("Dialect%s.valuesPlaceholders: No fields set in record", d.DialectName())
 	}
 	return s, nil
 }
 
 func (d *DialectSqlite3) SaveSql(r *InRecord) (string, error) {
+	return insertSql(d, r)
+}
+
+// INSERT INTO table (set fields) VALUES (placeholders)
+func insertSql(d Dialect, r *InRecord) (string, error) {
 	if r == nil {
-		return "", errors.New("DialectSqlite3.Save: record is nil")
+		return "", fmt.Errorf("Dialect%s.Save: record is nil", d.DialectName())
 	}
 
-	s := "INSERT INTO " + r.table.name + " ("
+	s := "INSERT INTO " + d.QuoteIdentifier(r.table.name) + " ("
 
-	fieldsSet, err := insertFields(r)
+	fieldsSet, err := insertFields(d, r)
 	if err != nil {
 		return "", err
 	}
@@ -373,7 +913,7 @@ func (d *DialectSqlite3) SaveSql(r *InRecord) (string, error) {
 	s += fieldsSet
 	s += ") VALUES ("
 
-	valuesP, err := d.valuesPlaceholders(r)
+	valuesP, err := valuesPlaceholders(d, r)
 	if err != nil {
 		return "", err
 	}
@@ -384,70 +924,305 @@ func (d *DialectSqlite3) SaveSql(r *InRecord) (string, error) {
 	return s, nil
 }
 
-func (d *DialectSqlite3) DeleteSql(tbl *Table, id int64) (string, error) {
+// Inserts the record, or updates its set fields if there is a record with its primary key
+func (d *DialectSqlite3) UpsertSql(r *InRecord) (string, error) {
+	s, err := d.SaveSql(r)
+	if err != nil {
+		return "", err
+	}
+	if len(r.table.pks) == 0 {
+		return "", fmt.Errorf("DialectSqlite3.Upsert: table %s has no primary key", r.table.name)
+	}
+	s += " ON CONFLICT(" + quoteFields(d, r.table.pks) + ")"
+
+	var set string
+	for i := 0; i < len(r.values); i++ {
+		if r.values[i].isSet && !r.values[i].field.pk {
+			if set != "" {
+				set += COMMA_SPACE
+			}
+			name := d.QuoteIdentifier(r.values[i].field.name)
+			set += name + "=excluded." + name
+		}
+	}
+	if set == "" {
+		return s + " DO NOTHING", nil
+	}
+	return s + " DO UPDATE SET " + set, nil
+}
+
+// Updates the set fields of the record, by primary key
+func (d *DialectSqlite3) UpdateSql(r *InRecord) (string, []any, error) {
+	return updateSql(d, r)
+}
+
+func updateSql(d Dialect, r *InRecord) (string, []any, error) {
+	if r == nil {
+		return "", nil, fmt.Errorf("Dialect%s.Update: record is nil", d.DialectName())
+	}
+	if r.table == nil {
+		return "", nil, fmt.Errorf("Dialect%s.Update: record table is nil", d.DialectName())
+	}
+	if len(r.table.pks) == 0 {
+		return "", nil, fmt.Errorf("Dialect%s.Update: table %s has no primary key", d.DialectName(), r.table.name)
+	}
+	key := make([]any, len(r.table.pks))
+	for i := 0; i < len(r.table.pks); i++ {
+		pkValue, ok := r.valuesMap[r.table.pks[i].name]
+		if !ok || !pkValue.isSet {
+			return "", nil, fmt.Errorf("Dialect%s.Update: primary key %s must be set", d.DialectName(), r.table.pks[i].name)
+		}
+		key[i] = actualValue(pkValue.value)
+	}
+
+	var values []*Value
+	for i := 0; i < len(r.values); i++ {
+		if r.values[i].isSet && !r.values[i].field.pk {
+			values = append(values, r.values[i])
+		}
+	}
+	args := NewArgs(d)
+
+	set, err := updateSet(d, values, args)
+	if err != nil {
+		return "", nil, err
+	}
+	where, err := keyWhere(d, r.table, key, args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	s := "UPDATE " + d.QuoteIdentifier(r.table.name) + " SET " + set + " WHERE " + where
+
+	return s, args.Values(), nil
+}
+
+// Updates the values in all the records of the table matching the condition
+func (d *DialectSqlite3) UpdateWhereSql(tbl *Table, cond Condition, values []*Value) (string, []any, error) {
+	return updateWhereSql(d, tbl, cond, values)
+}
+
+func updateWhereSql(d Dialect, tbl *Table, cond Condition, values []*Value) (string, []any, error) {
+	if tbl == nil {
+		return "", nil, fmt.Errorf("Dialect%s.UpdateWhere: table is nil", d.DialectName())
+	}
+	if cond == nil {
+		return "", nil, fmt.Errorf("Dialect%s.UpdateWhere: condition is nil", d.DialectName())
+	}
+	for i := 0; i < len(values); i++ {
+		if values[i] == nil || values[i].field == nil {
+			return "", nil, fmt.Errorf("Dialect%s.UpdateWhere: value or value field is nil", d.DialectName())
+		}
+		if values[i].field.table != tbl {
+			return "", nil, fmt.Errorf("Dialect%s.UpdateWhere: field %s is not in table %s", d.DialectName(), values[i].field.name, tbl.name)
+		}
+		if err := validTypeForField(actualValue(values[i].value), values[i].field); err != nil {
+			return "", nil, err
+		}
+	}
+	args := NewArgs(d)
+
+	set, err := updateSet(d, values, args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	where, err := evaluateCondition(cond, args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "UPDATE " + d.QuoteIdentifier(tbl.name) + " SET " + set + " WHERE " + where, args.Values(), nil
+}
+
+func updateSet(d Dialect, values []*Value, args *Args) (string, error) {
+	if len(values) == 0 {
+		return "", errors.New("No fields set to update")
+	}
+	var s string
+	for i := 0; i < len(values); i++ {
+		if i != 0 {
+			s += COMMA_SPACE
+		}
+		s += d.QuoteIdentifier(values[i].field.name) + "=" + args.Add(actualValue(values[i].value))
+	}
+	return s, nil
+}
+
+func (d *DialectSqlite3) DeleteSql(tbl *Table, key []any) (string, []any, error) {
+	return deleteSql(d, tbl, key)
+}
+
+func deleteSql(d Dialect, tbl *Table, key []any) (string, []any, error) {
 	if tbl == nil {
-		return "", errors.New("DialectSqlite3.Delete: table is nil")
+		return "", nil, fmt.Errorf("Dialect%s.Delete: table is nil", d.DialectName())
+	}
+
+	if tbl.name == "" {
+		return "", nil, fmt.Errorf("Dialect%s.Delete: table name is empty string", d.DialectName())
 	}
-	if id < 0 {
-		return "", errors.New("DialectSqlite3.Delete: id < 0")
+
+	args := NewArgs(d)
+	where, err := keyWhere(d, tbl, key, args)
+	if err != nil {
+		return "", nil, fmt.Errorf("Dialect%s.Delete: %w", d.DialectName(), err)
 	}
 
+	return "DELETE FROM " + d.QuoteIdentifier(tbl.name) + " WHERE " + where, args.Values(), nil
+}
+
+func (d *DialectSqlite3) DeleteWhereSql(tbl *Table, cond Condition) (string, []any, error) {
+	return deleteWhereSql(d, tbl, cond)
+}
+
+func deleteWhereSql(d Dialect, tbl *Table, cond Condition) (string, []any, error) {
+	if tbl == nil {
+		return "", nil, fmt.Errorf("Dialect%s.DeleteWhere: table is nil", d.DialectName())
+	}
 	if tbl.name == "" {
-		return "", errors.New("DialectSqlite3.Delete: table name is empty string")
+		return "", nil, fmt.Errorf("Dialect%s.DeleteWhere: table name is empty string", d.DialectName())
 	}
+	if cond == nil {
+		return "", nil, fmt.Errorf("Dialect%s.DeleteWhere: condition is nil", d.DialectName())
+	}
+	args := NewArgs(d)
 
-	return "DELETE FROM " + tbl.name + " WHERE " + tbl.pk.name + "=?", NotImplemented
+	where, err := evaluateCondition(cond, args)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "DELETE FROM " + d.QuoteIdentifier(tbl.name) + " WHERE " + where, args.Values(), nil
 }
 
 func (d *DialectSqlite3) JoinSql(*Join, string, ...*Field) error {
 	return NotImplemented
 }
 
-func (d *DialectSqlite3) makeFields(fields []AField) string {
-	if len(fields) == 0 {
-		return ""
+// Fields of a table are qualified by the table, as a name can be in more than one From table
+func makeFields(d Dialect, fields []AField) (string, error) {
+	var s string
+
+	for i := 0; i < len(fields); i++ {
+		var field string
+		if f, ok := fields[i].(*Field); ok {
+			field = quoteField(d, f)
+		} else {
+			var err error
+			if field, err = fields[i].ToSqlString(d); err != nil {
+				return "", err
+			}
+		}
+		if i != 0 {
+			s += COMMA_SPACE
+		}
+		s += field
 	}
 
-	//s := fields[0].name
-	//s := fields[0].name
-	s, _ := fields[0].ToSqlString(d)
+	return s, nil
+}
 
-	for i := 1; i < len(fields); i++ {
-		field, _ := fields[i].ToSqlString(d)
-		s += COMMA_SPACE + field
+func makeFrom(d Dialect, tables []*Table) (string, error) {
+	var s string
+
+	for i := 0; i < len(tables); i++ {
+		tbl, err := d.Table(tables[i])
+		if err != nil {
+			return "", err
+		}
+		if i != 0 {
+			s += COMMA_SPACE
+		}
+		s += tbl
 	}
 
-	return s
+	return s, nil
 }
 
-func (d *DialectSqlite3) makePks(pks []int64) string {
-	log.Fatal("makePks")
-	return "fail"
+// Primary key list and where condition are combined with AND
+func selectWhereClause(q *SelectQuery, args *Args) (string, error) {
+	var s string
+
+	if len(q.Pks) > 0 {
+		s = makePks(args.dialect, q.From[0].pk, q.Pks, args)
+	}
+
+	if q.Where != nil {
+		cond, err := evaluateCondition(q.Where, args)
+		if err != nil {
+			return "", err
+		}
+		if len(s) > 0 {
+			s = "(" + s + ")" + LAnd.String() + "(" + cond + ")"
+		} else {
+			s = cond
+		}
+	}
+
+	if len(s) == 0 {
+		return "", nil
+	}
+	return " WHERE " + s, nil
 }
 
-func (d *DialectSqlite3) makeWhereClause(cond Condition) string {
-	return "fail"
+func makePks(d Dialect, pk *Field, pks []int64, args *Args) string {
+	s := quoteField(d, pk) + In.String() + "("
+	for i := 0; i < len(pks); i++ {
+		if i != 0 {
+			s += COMMA_SPACE
+		}
+		s += args.Add(pks[i])
+	}
+	return s + ")"
 }
-func (d *DialectSqlite3) makeGroupBy(fields []*Field) string {
+
+func makeGroupBy(d Dialect, fields []*Field) string {
 	if len(fields) == 0 {
 		return ""
 	}
+	var s string
+	for i := 0; i < len(fields); i++ {
+		if i != 0 {
+			s += COMMA_SPACE
+		}
+		s += quoteField(d, fields[i])
+	}
+	return " GROUP BY " + s
+}
 
-	s := "GROUP BY " + fields[0].name
-	for i := 1; i < len(fields); i++ {
-		s += COMMA_SPACE + fields[i].name
+func makeHaving(cond Condition, args *Args) (string, error) {
+	if cond == nil {
+		return "", nil
 	}
-	return s
+	s, err := evaluateCondition(cond, args)
+	if err != nil {
+		return "", err
+	}
+	return " HAVING " + s, nil
 }
 
-func (d *DialectSqlite3) makeHaving(cond Condition) string {
-	return "fail"
+// Without order by fields, the global ordering orders by the primary key of the first table
+func makeOrderBy(d Dialect, q *SelectQuery) string {
+	if len(q.OrderByFields) > 0 {
+		return " ORDER BY " + makeOrderByFields(d, q.OrderByFields, q.GlobalOrdering)
+	}
+	if q.GlobalOrdering != NoOrdering && len(q.From[0].pks) > 0 {
+		var s string
+		for i := 0; i < len(q.From[0].pks); i++ {
+			if i != 0 {
+				s += COMMA_SPACE
+			}
+			s += quoteField(d, q.From[0].pks[i]) + SPACE + q.GlobalOrdering.String()
+		}
+		return " ORDER BY " + s
+	}
+	return ""
 }
 
 const SPACE = " "
 const COMMA_SPACE = ", "
 
-func (d *DialectSqlite3) makeOrderByFields(fields []*FieldOrdered) string {
+func makeOrderByFields(d Dialect, fields []*FieldOrdered, global Ordering) string {
 	if len(fields) == 0 {
 		return ""
 	}
@@ -459,11 +1234,14 @@ func (d *DialectSqlite3) makeOrderByFields(fields []*FieldOrdered) string {
 			s += COMMA_SPACE
 		}
 		f := fields[i]
-		s += f.name
-		if f.ordering != NoOrdering {
-			s += SPACE
+		s += quoteField(d, &f.Field)
+		ordering := f.ordering
+		if ordering == NoOrdering {
+			ordering = global
+		}
+		if ordering != NoOrdering {
+			s += SPACE + ordering.String()
 		}
-		s += f.ordering.String()
 	}
 	return s
 }
@@ -481,25 +1259,29 @@ var sqlFunctionString = map[SQLFunctionId]string{
 }
 
 func (d *DialectSqlite3) FunctionFieldSql(ff FunctionField) (string, error) {
-	if true {
-		return "HEOOO", nil
-	}
-	var numExpectedFields int
-	var exists bool
-	if numExpectedFields, exists = sqlFunctionNArgs[ff.sqlFunctionId]; !exists {
-		log.Fatal(fmt.Errorf("FunctionField does not exist: %d   in sqlFunctionId map", ff.sqlFunctionId))
+	return functionFieldSql(d, ff)
+}
+
+func functionFieldSql(d Dialect, ff FunctionField) (string, error) {
+	nArgs, exists := sqlFunctionNArgs[ff.sqlFunctionId]
+	if !exists {
+		return "", fmt.Errorf("FunctionField does not exist: %d in sqlFunctionId map", ff.sqlFunctionId)
 	}
-	if numExpectedFields != len(ff.fields) {
-		log.Fatal(fmt.Errorf("NumExpectedFields %d for functionid %d does not match acting num fields %d", numExpectedFields, ff.sqlFunctionId, len(ff.fields)))
+	if len(ff.fields) < nArgs[0] || len(ff.fields) > nArgs[1] {
+		return "", fmt.Errorf("Number of fields %d for functionid %d must be between %d and %d", len(ff.fields), ff.sqlFunctionId, nArgs[0], nArgs[1])
 	}
 
-	var funcName string
-	if funcName, exists = sqlFunctionString[ff.sqlFunctionId]; !exists {
-		log.Fatal(fmt.Errorf("FunctionField does not exist: %d in sqlFunctionString map", ff.sqlFunctionId))
+	funcName, exists := sqlFunctionString[ff.sqlFunctionId]
+	if !exists {
+		return "", fmt.Errorf("FunctionField does not exist: %d in sqlFunctionString map", ff.sqlFunctionId)
 	}
 
 	f := funcName + "("
 
+	if ff.sqlFunctionId == COUNT && len(ff.fields) == 0 {
+		f += "*"
+	}
+
 	for i := 0; i < len(ff.fields); i++ {
 		if i > 0 {
 			f += ","
@@ -528,38 +1310,71 @@ func (d *DialectSqlite3) FieldAsSql(fa *FieldAs) (string, error) {
 		return "", errors.New("FieldAs.field.name is empty string")
 	}
 
-	return fa.field.name + " AS " + fa.alias, nil
+	return d.QuoteIdentifier(fa.field.name) + " AS " + d.QuoteIdentifier(fa.alias), nil
 }
 
-func (d *DialectSqlite3) SelectQuerySql2(q *Query) (string, error) {
+func (d *DialectSqlite3) SelectQuerySql2(q *Query) (string, []any, error) {
+	return querySql(d, q, sqliteNoLimit)
+}
+
+// noLimit is the LIMIT of the dialect for no limit, used when there is only an OFFSET
+func querySql(d Dialect, q *Query, noLimit string) (string, []any, error) {
+	if q == nil {
+		return "", nil, errors.New("Query is nil")
+	}
+	args := NewArgs(d)
 	var sql string = "SELECT "
 
-	err := makeSelectFields(&sql, q.selectFields, q.selectRaw)
+	err := makeSelectFields(&sql, d, q.selectFields, q.selectRaw)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	sql += " FROM "
 
-	err = makeFromTables(&sql, q.fromTables, q.fromRaw)
+	fromTables := q.baseTables()
+	err = makeFromTables(&sql, d, fromTables, q.fromRaw)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	err = makeWhereClause(&sql, q.where, q.whereEquals, q.whereRaw)
+	joinEquals, err := makeJoins(&sql, d, fromTables, q.joins)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+
+	whereEquals := append(append([]AField{}, q.whereEquals...), joinEquals...)
+	err = makeWhereClause(&sql, d, args, q.where, whereEquals, q.whereRaw)
+	if err != nil {
+		return "", nil, err
+	}
+
+	makeGroupByClause(&sql, d, q.groupBy)
+
+	err = makeHavingClause(&sql, args, q.having)
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = makeOrderByClause(&sql, d, q.orderBy)
+	if err != nil {
+		return "", nil, err
 	}
 
-	return sql, nil
+	makeLimitOffset(&sql, q.limit, q.offset, noLimit)
+
+	return sql, args.Values(), nil
 }
 
-func makeSelectFields(sql *string, fields []*Field, rawFields []string) error {
+func makeSelectFields(sql *string, d Dialect, fields []*Field, rawFields []string) error {
+	if len(fields) == 0 && len(rawFields) == 0 {
+		return errors.New("No fields selected")
+	}
 	for i := 0; i < len(fields); i++ {
 		if i != 0 {
 			*sql += ","
 		}
-		*sql += fields[i].name
+		*sql += quoteField(d, fields[i])
 	}
 
 	for i := 0; i < len(rawFields); i++ {
@@ -572,12 +1387,19 @@ func makeSelectFields(sql *string, fields []*Field, rawFields []string) error {
 	return nil
 }
 
-func makeFromTables(sql *string, tables []*Table, rawTables string) error {
+func makeFromTables(sql *string, d Dialect, tables []*Table, rawTables string) error {
+	if len(tables) == 0 && len(rawTables) == 0 {
+		return errors.New("No tables to select from")
+	}
 	for i := 0; i < len(tables); i++ {
 		if i != 0 {
 			*sql += ","
 		}
-		*sql += tables[i].name
+		tbl, err := d.Table(tables[i])
+		if err != nil {
+			return err
+		}
+		*sql += tbl
 	}
 
 	if len(tables) > 0 && len(rawTables) > 0 {
@@ -585,14 +1407,163 @@ func makeFromTables(sql *string, tables []*Table, rawTables string) error {
 	}
 	*sql += rawTables
 
-	*sql += " "
+	return nil
+}
+
+// Joins the table not yet in the query; joins between tables already in the query are returned as where equals
+func makeJoins(sql *string, d Dialect, tables []*Table, joins []*Join2) ([]AField, error) {
+	present := make(map[*Table]struct{}, len(tables))
+	for i := 0; i < len(tables); i++ {
+		present[tables[i]] = struct{}{}
+	}
+
+	var equals []AField
+	for i := 0; i < len(joins); i++ {
+		j := joins[i]
+		if j == nil || j.f1 == nil || j.f2 == nil {
+			return nil, errors.New("Join field is nil")
+		}
+		if j.f1.table == nil || j.f2.table == nil {
+			return nil, errors.New("Join field has no table")
+		}
+
+		var joinTable *Table
+		if _, ok := present[j.f2.table]; !ok {
+			joinTable = j.f2.table
+		} else if _, ok := present[j.f1.table]; !ok {
+			joinTable = j.f1.table
+		} else {
+			equals = append(equals, j.f1, j.f2)
+			continue
+		}
+		present[joinTable] = struct{}{}
+		*sql += j.joinType.String() + d.QuoteIdentifier(joinTable.name) + " ON " + quoteField(d, j.f1) + EQ.String() + quoteField(d, j.f2)
+	}
+	return equals, nil
+}
+
+// All conditions are combined with AND
+func makeWhereClause(sql *string, d Dialect, args *Args, where []Condition, whereEquals []AField, whereRaw string) error {
+	if len(whereEquals)%2 != 0 {
+		return fmt.Errorf("Where equals needs pairs of fields; has %d fields", len(whereEquals))
+	}
+	var conds []string
+
+	for i := 0; i < len(where); i++ {
+		if where[i] == nil {
+			return errors.New("Where condition is nil")
+		}
+		s, err := evaluateCondition(where[i], args)
+		if err != nil {
+			return err
+		}
+		conds = append(conds, s)
+	}
+
+	for i := 0; i < len(whereEquals); i += 2 {
+		left, err := queryFieldSql(d, whereEquals[i])
+		if err != nil {
+			return err
+		}
+		right, err := queryFieldSql(d, whereEquals[i+1])
+		if err != nil {
+			return err
+		}
+		conds = append(conds, left+EQ.String()+right)
+	}
+
+	if len(whereRaw) > 0 {
+		conds = append(conds, whereRaw)
+	}
+
+	if len(conds) == 0 {
+		return nil
+	}
+
+	*sql += " WHERE "
+	for i := 0; i < len(conds); i++ {
+		if i != 0 {
+			*sql += LAnd.String()
+		}
+		if len(conds) > 1 {
+			*sql += "(" + conds[i] + ")"
+		} else {
+			*sql += conds[i]
+		}
+	}
 
 	return nil
 }
 
-func makeWhereClause(sql *string, where []*Condition, whereEquals []AField, whereRaw string) error {
+func makeGroupByClause(sql *string, d Dialect, fields []*Field) {
+	if len(fields) == 0 {
+		return
+	}
+	*sql += " GROUP BY "
+	for i := 0; i < len(fields); i++ {
+		if i != 0 {
+			*sql += COMMA_SPACE
+		}
+		*sql += quoteField(d, fields[i])
+	}
+}
 
-	*sql += "WHERE "
+func makeHavingClause(sql *string, args *Args, having Condition) error {
+	if having == nil {
+		return nil
+	}
+	s, err := evaluateCondition(having, args)
+	if err != nil {
+		return err
+	}
+	*sql += " HAVING " + s
+	return nil
+}
 
+func makeOrderByClause(sql *string, d Dialect, orderBy []*OrderBy) error {
+	if len(orderBy) == 0 {
+		return nil
+	}
+	*sql += " ORDER BY "
+	for i := 0; i < len(orderBy); i++ {
+		if orderBy[i] == nil {
+			return errors.New("Order by is nil")
+		}
+		if i != 0 {
+			*sql += COMMA_SPACE
+		}
+		s, err := queryFieldSql(d, orderBy[i].field)
+		if err != nil {
+			return err
+		}
+		*sql += s
+		if orderBy[i].ordering != NoOrdering {
+			*sql += SPACE + orderBy[i].ordering.String()
+		}
+	}
 	return nil
 }
+
+// -1 is unset
+func makeLimitOffset(sql *string, limit, offset int64, noLimit string) {
+	if limit >= 0 {
+		*sql += " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+	if offset > 0 {
+		if limit < 0 {
+			*sql += " LIMIT " + noLimit
+		}
+		*sql += " OFFSET " + strconv.FormatInt(offset, 10)
+	}
+}
+
+// Fields are qualified with their table name
+func queryFieldSql(d Dialect, af AField) (string, error) {
+	if af == nil {
+		return "", errors.New("Field is nil")
+	}
+	if f, ok := af.(*Field); ok {
+		return quoteField(d, f), nil
+	}
+	return af.ToSqlString(d)
+}
@@ -0,0 +1,293 @@
+package dalkeeth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Prefix of the table made by the table rebuild procedure
+const sqliteRebuildPrefix = "dalkeeth_new_"
+
+// MigrationPlan orders the changes: added tables (foreign tables first), changed tables, removed tables.
+// Added fields are added with ALTER TABLE when sqlite allows it; other field and foreign key changes
+// rebuild the table: create the new table, copy the records, drop the old table and rename the new one.
+func (d *DialectSqlite3) MigrationPlan(diff *SchemaDiff) (*MigrationPlan, error) {
+	if diff == nil {
+		return nil, errors.New("MigrationPlan: diff is nil")
+	}
+	tables, err := diffTables(diff)
+	if err != nil {
+		return nil, err
+	}
+	plan := new(MigrationPlan)
+
+	for i := 0; i < len(tables.added); i++ {
+		if err = d.planCreateTable(plan, tables.added[i], tables.added[i].name); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < len(tables.changed); i++ {
+		changes := tables.changed[i]
+		if sqliteNeedsRebuild(changes) {
+			err = d.planRebuildTable(plan, changes[0].want, changes[0].have)
+		} else {
+			err = d.planAlterTable(plan, changes)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < len(tables.removed); i++ {
+		plan.Statements = append(plan.Statements, "DROP TABLE "+d.QuoteIdentifier(tables.removed[i].name))
+	}
+
+	return plan, nil
+}
+
+// ApplyMigration runs the plan in a transaction with foreign key enforcement turned off, as required by the
+// table rebuild procedure; the foreign keys are checked before committing.
+func (d *DialectSqlite3) ApplyMigration(ctx context.Context, db *sql.DB, plan *MigrationPlan) error {
+	if db == nil {
+		return errors.New("DB is nil")
+	}
+	if plan == nil {
+		return errors.New("ApplyMigration: plan is nil")
+	}
+
+	// PRAGMA foreign_keys is per connection, and cannot be changed in a transaction
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var foreignKeys int64
+	if err = conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return err
+	}
+	if foreignKeys != 0 {
+		if _, err = conn.ExecContext(ctx, "PRAGMA foreign_keys=OFF"); err != nil {
+			return err
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), "PRAGMA foreign_keys=ON"); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = sqliteApplyMigration(ctx, tx, plan, foreignKeys != 0)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Println(rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func sqliteApplyMigration(ctx context.Context, tx *sql.Tx, plan *MigrationPlan, checkForeignKeys bool) error {
+	for i := 0; i < len(plan.Statements); i++ {
+		if _, err := tx.ExecContext(ctx, plan.Statements[i]); err != nil {
+			return fmt.Errorf("ApplyMigration: %s: %w", plan.Statements[i], err)
+		}
+	}
+	if !checkForeignKeys {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	defer closeRows(rows)
+	if err != nil {
+		return err
+	}
+	if rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int64
+		if err = rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return err
+		}
+		return fmt.Errorf("ApplyMigration: foreign key violation: table %s references %s", table, parent)
+	}
+	return rows.Err()
+}
+
+func (d *DialectSqlite3) planCreateTable(plan *MigrationPlan, t *Table, name string) error {
+	tmp := *t
+	tmp.name = name
+	s, err := d.CreateTableSql(&tmp)
+	if err != nil {
+		return err
+	}
+	plan.Statements = append(plan.Statements, s)
+	if name != t.name {
+		return nil
+	}
+	return d.planCreateIndexes(plan, t)
+}
+
+func (d *DialectSqlite3) planCreateIndexes(plan *MigrationPlan, t *Table) error {
+	for i := 0; i < len(t.indexes); i++ {
+		s, err := d.CreateTableIndexSql(t.indexes[i])
+		if err != nil {
+			return err
+		}
+		plan.Statements = append(plan.Statements, s)
+	}
+	return nil
+}
+
+// The records are copied for the fields in both tables
+func (d *DialectSqlite3) planRebuildTable(plan *MigrationPlan, want, have *Table) error {
+	newName := sqliteRebuildPrefix + want.name
+	if err := d.planCreateTable(plan, want, newName); err != nil {
+		return err
+	}
+
+	var fields []string
+	for i := 0; i < len(want.fields); i++ {
+		if have.Field(want.fields[i].name) != nil {
+			fields = append(fields, want.fields[i].name)
+		}
+	}
+	if len(fields) > 0 {
+		fs := quoteAll(d, fields)
+		plan.Statements = append(plan.Statements, "INSERT INTO "+d.QuoteIdentifier(newName)+" ("+fs+") SELECT "+fs+
+			" FROM "+d.QuoteIdentifier(have.name))
+	}
+
+	plan.Statements = append(plan.Statements,
+		"DROP TABLE "+d.QuoteIdentifier(have.name),
+		"ALTER TABLE "+d.QuoteIdentifier(newName)+" RENAME TO "+d.QuoteIdentifier(want.name))
+
+	return d.planCreateIndexes(plan, want)
+}
+
+func (d *DialectSqlite3) planAlterTable(plan *MigrationPlan, changes []*SchemaChange) error {
+	// Drop before create, as a changed index is removed and added
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		if c.Object == IndexObject && c.Kind == Removed {
+			plan.Statements = append(plan.Statements, "DROP INDEX "+d.QuoteIdentifier(d.indexName(c.index)))
+		}
+	}
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		switch {
+		case c.Object == FieldObject && c.Kind == Added:
+			s, err := d.fieldSql(c.field)
+			if err != nil {
+				return err
+			}
+			plan.Statements = append(plan.Statements, "ALTER TABLE "+d.QuoteIdentifier(c.Table)+" ADD COLUMN "+s)
+		case c.Object == IndexObject && c.Kind == Added:
+			s, err := d.CreateTableIndexSql(c.index)
+			if err != nil {
+				return err
+			}
+			plan.Statements = append(plan.Statements, s)
+		}
+	}
+	return nil
+}
+
+// ALTER TABLE can only add fields, without PRIMARY KEY or UNIQUE, and NOT NULL only with a default.
+// Indexes made by UNIQUE constraints cannot be dropped.
+func sqliteNeedsRebuild(changes []*SchemaChange) bool {
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		switch c.Object {
+		case FieldObject:
+			if c.Kind != Added {
+				return true
+			}
+			f := c.field
+			if f.pk || f.unique || (f.notNull && f.defaultValue == "") {
+				return true
+			}
+		case IndexObject:
+			if c.Kind == Removed && strings.HasPrefix(c.index.name, "sqlite_autoindex_") {
+				return true
+			}
+		case ForeignKeyObject:
+			return true
+		}
+	}
+	return false
+}
+
+// The tables of a schema diff, in the order a migration changes them
+type migrationTables struct {
+	added   []*Table          // Foreign tables first
+	changed [][]*SchemaChange // The changes of each changed table, in model order
+	removed []*Table          // Tables referencing them first
+}
+
+// diffTables gathers the added, changed and removed tables of the diff, ordered by their foreign keys
+func diffTables(diff *SchemaDiff) (*migrationTables, error) {
+	var added, removed []*Table
+	for i := 0; i < len(diff.Changes); i++ {
+		c := diff.Changes[i]
+		if c.Object != TableObject {
+			continue
+		}
+		switch c.Kind {
+		case Added:
+			added = append(added, c.want)
+		case Removed:
+			removed = append(removed, c.have)
+		}
+	}
+
+	tables := new(migrationTables)
+	var err error
+	if tables.added, err = tablesInDependencyOrder(added); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(diff.want.tables); i++ {
+		changes := diff.tableChanges(diff.want.tables[i].name)
+		if len(changes) == 0 || changes[0].Object == TableObject {
+			continue
+		}
+		tables.changed = append(tables.changed, changes)
+	}
+
+	if removed, err = tablesInDependencyOrder(removed); err != nil {
+		return nil, err
+	}
+	for i := len(removed) - 1; i >= 0; i-- {
+		tables.removed = append(tables.removed, removed[i])
+	}
+	return tables, nil
+}
+
+// Foreign tables before the tables referencing them, of the tables
+func tablesInDependencyOrder(tables []*Table) ([]*Table, error) {
+	sorted, err := tablesByDependency(tables)
+	if err != nil {
+		return nil, err
+	}
+	in := make(map[*Table]struct{}, len(tables))
+	for i := 0; i < len(tables); i++ {
+		in[tables[i]] = struct{}{}
+	}
+	var ordered []*Table
+	for i := 0; i < len(sorted); i++ {
+		if _, ok := in[sorted[i]]; ok {
+			ordered = append(ordered, sorted[i])
+		}
+	}
+	return ordered, nil
+}
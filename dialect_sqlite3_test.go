@@ -1,8 +1,10 @@
 package dalkeeth
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
+	"path/filepath"
 	"testing"
 )
 
@@ -53,6 +55,256 @@ func Test_SimplePKLookup(t *testing.T) {
 		t.Fatal("Unabler to find record with pid=", VPersonID0)
 	}
 
-	t.Log(rec)
-	t.Fatal(NotImplemented)
+	var name string
+	err = rec.GetString(FName, &name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Fred" {
+		t.Fatal(fmt.Errorf("Wrong name for pid=%d: %s", VPersonID0, name))
+	}
+
+	rec, err = sess.Get(personTbl, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec != nil {
+		t.Fatal("Should not find record with pid=0")
+	}
+}
+
+func TestDialectSqlite3_UpdateSql(t *testing.T) {
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := model.TableByKey(TPerson)
+
+	rec := persons.NewRecord()
+	if err = rec.SetValue(FName, "Sally"); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.SetValue(FId, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.SetValue(FAge, 33); err != nil {
+		t.Fatal(err)
+	}
+
+	sql, args, err := new(DialectSqlite3).UpdateSql(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != `UPDATE "persons" SET "age"=?, "name"=? WHERE "id"=?` {
+		t.Fatal(sql)
+	}
+	if len(args) != 3 || args[0] != int64(33) || args[1] != "Sally" || args[2] != int64(7) {
+		t.Fatal(args)
+	}
+}
+
+func TestDialectSqlite3_ExtractModel(t *testing.T) {
+	mdl0, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(mdl0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	d := new(DialectSqlite3)
+
+	m, err := ExtractModel(sess.db, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.tables) != len(mdl0.tables) {
+		t.Fatal(fmt.Errorf("Should extract %d tables; extracted %d", len(mdl0.tables), len(m.tables)))
+	}
+
+	for i := 0; i < len(mdl0.tables); i++ {
+		tbl := mdl0.tables[i]
+		extracted := m.TableByKey(tbl.name)
+		if extracted == nil {
+			t.Fatal("Table not extracted:", tbl.name)
+		}
+		expected, err := d.CreateTableSql(tbl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := d.CreateTableSql(extracted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected {
+			t.Fatalf("Expected:\n%s\nGot:\n%s", expected, s)
+		}
+	}
+
+	pa := m.TableByKey(JTPersonName)
+	if len(pa.indexes) != 1 || !pa.indexes[0].unique || len(pa.indexes[0].fields) != 2 ||
+		pa.indexes[0].fields[0].name != FPersonId || pa.indexes[0].fields[1].name != FAddressId {
+		t.Fatal("person_address should have unique index on person_id, address_id")
+	}
+	if len(pa.foreignKeys) != 1 || pa.foreignKeys[0].foreignTable != m.TableByKey(TPerson) || pa.foreignKeys[0].foreignFields[0].name != FId {
+		t.Fatal("person_address should have foreign key to persons.id")
+	}
+}
+
+func TestDialectSqlite3_ExtractTable_Constraints(t *testing.T) {
+	db, err := openTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	d := new(DialectSqlite3)
+
+	for _, s := range []string{
+		"CREATE TABLE owners (id INTEGER PRIMARY KEY, email VARCHAR(100) UNIQUE, score DOUBLE DEFAULT 'x''y', UNIQUE(id, email))",
+		"CREATE TABLE pets (id INTEGER PRIMARY KEY, owner_id INT REFERENCES owners, name TEXT NOT NULL)",
+		"CREATE INDEX pets_name ON pets(name)",
+		"CREATE TABLE tags (a INT, b INT, FOREIGN KEY(a, b) REFERENCES owners(id, email) ON DELETE CASCADE ON UPDATE SET NULL)",
+	} {
+		if _, err = db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	owners, err := d.ExtractTable(db, "owners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := owners.Field("email")
+	if email.fieldType != StringType || email.length != 100 || !email.unique {
+		t.Fatalf("Wrong email field: %+v", email)
+	}
+	if owners.Field("score").fieldType != FloatType || owners.Field("score").defaultValue != "x'y" {
+		t.Fatalf("Wrong score field: %+v", owners.Field("score"))
+	}
+	if len(owners.indexes) != 1 || !owners.indexes[0].unique || len(owners.indexes[0].fields) != 2 {
+		t.Fatal("owners should have unique index on id, email")
+	}
+
+	pets, err := d.ExtractTable(db, "pets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pets.indexes) != 1 || pets.indexes[0].unique || pets.indexes[0].fields[0].name != "name" {
+		t.Fatal("pets should have index on name")
+	}
+	if len(pets.foreignKeys) != 1 || pets.foreignKeys[0].foreignTable.name != "owners" {
+		t.Fatal("pets should have foreign key to owners")
+	}
+
+	tags, err := d.ExtractTable(db, "tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags.foreignKeys) != 1 || len(tags.foreignKeys[0].fields) != 2 || tags.foreignKeys[0].fields[1].name != "b" ||
+		tags.foreignKeys[0].foreignFields[1].name != "email" ||
+		tags.foreignKeys[0].onDelete != Cascade || tags.foreignKeys[0].onUpdate != SetNull {
+		t.Fatal("tags should have composite foreign key to owners(id, email)")
+	}
+	if _, err = d.ExtractTable(db, "unknown"); err == nil {
+		t.Fatal("Unknown table", ShouldHaveFailed)
+	}
+
+	// pets.owner_id references the primary key of owners
+	m, err := ExtractModel(db, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fk := m.TableByKey("pets").foreignKeys[0]; fk.foreignFields[0] != m.TableByKey("owners").pk {
+		t.Fatal("pets.owner_id should reference owners.id")
+	}
+	if fk := m.TableByKey("tags").foreignKeys[0]; fk.foreignFields[1] != m.TableByKey("owners").Field("email") {
+		t.Fatal("tags.b should reference owners.email")
+	}
+}
+
+func TestSqliteDSN(t *testing.T) {
+	if _, err := SqliteDSN("not-a-driver", "x.db"); err == nil {
+		t.Fatal("Unknown driver", ShouldHaveFailed)
+	}
+	s, err := SqliteDSN(SqliteCgoDriver, "file:x.db?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "file:x.db?mode=ro&_foreign_keys=1" {
+		t.Fatal(s)
+	}
+
+	// Every connection of the pool enforces foreign keys
+	dsn, err := SqliteDSN(testSqliteDriver, filepath.Join(t.TempDir(), "fk.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(testSqliteDriver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxIdleConns(0)
+
+	if _, err = db.Exec("CREATE TABLE owners (id INTEGER PRIMARY KEY); CREATE TABLE pets (id INTEGER PRIMARY KEY, owner_id INT REFERENCES owners)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("INSERT INTO pets (id, owner_id) VALUES (1, 9)"); err == nil {
+		t.Fatal("Missing foreign record", ShouldHaveFailed)
+	}
+}
+
+// String defaults are SQL strings: SQLite reads a default in backticks as an identifier
+func TestDialectSqlite3_StringDefault(t *testing.T) {
+	m := jsonTestModel(t, `{"tables": [{"name": "owners",
+  "fields": [{"name": "id", "type": "int", "pk": true}, {"name": "nick", "type": "string", "default": "it's `+"`x`"+`"}]}]}`)
+	owners := m.TableByKey("owners")
+
+	s, err := makeDefault(owners.Field("nick"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != " DEFAULT 'it''s `x`'" {
+		t.Fatal(s)
+	}
+
+	db, err := openTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := OpenDB(m, db, new(DialectSqlite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	if err = sess.WriteModelTableSchemaToDB(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := owners.NewRecord()
+	if err = rec.SetValue("id", int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec, err = sess.Get(owners, 1); err != nil {
+		t.Fatal(err)
+	}
+	var nick string
+	if err = rec.GetString("nick", &nick); err != nil {
+		t.Fatal(err)
+	}
+	if nick != "it's `x`" {
+		t.Fatal("Expected the default; got", nick)
+	}
+
+	extracted, err := sess.Dialect().ExtractTable(db, "owners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := extracted.Field("nick").defaultValue; d != "it's `x`" {
+		t.Fatal("Expected the default; got", d)
+	}
 }
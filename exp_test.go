@@ -13,12 +13,18 @@ func TestSimple_Positive(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	s, err := Evaluate(exp)
+	s, args, err := Evaluate(exp, new(DialectSqlite3))
 
 	if err != nil {
 		t.Error(err)
 	}
 	log.Println(s)
+	if s != "MAX(foo) NOT BETWEEN ? AND ?" {
+		t.Error(s)
+	}
+	if len(args) != 2 || args[0] != int64(43) || args[1] != int64(55) {
+		t.Error(args)
+	}
 }
 
 func TestComplex_Positive(t *testing.T) {
@@ -30,12 +36,15 @@ func TestComplex_Positive(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	s, err := Evaluate(exp)
+	s, args, err := Evaluate(exp, new(DialectSqlite3))
 
 	if err != nil {
 		t.Error(err)
 	}
 	log.Println(s)
+	if len(args) != 6 {
+		t.Error(args)
+	}
 }
 
 func TestSimpleStringString_Positive(t *testing.T) {
@@ -50,13 +59,100 @@ func TestSimpleStringString_Positive(t *testing.T) {
 				t.Error(err)
 			}
 
-			s, err := Evaluate(exp)
+			s, args, err := Evaluate(exp, new(DialectSqlite3))
 
 			if err != nil {
 				t.Error(err)
 			}
 			log.Println(s)
+			if len(args) != 1 || args[0] != "Fred%" {
+				t.Error(args)
+			}
 		}
 	}
 
 }
+
+func TestCondition_StringValueNotInlined(t *testing.T) {
+	value := `x" OR 1=1 --`
+	exp := W("name", EQ, value)
+
+	err := exp.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, args, err := Evaluate(exp, new(DialectSqlite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `"name" = ?` {
+		t.Fatal(s)
+	}
+	if len(args) != 1 || args[0] != value {
+		t.Fatal(args)
+	}
+}
+
+func TestCondition_ArgsInOrder(t *testing.T) {
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := model.TableByKey(TPerson)
+
+	exp := Or(
+		W(persons.Field(FId), In, 1, 2, 3),
+		Not(W(persons.Field(FName), Like, "S%")),
+		W(persons.Field(FWeight), Between, 50.5, 80.0),
+		W(persons.Field(FAge), EQ, persons.Field(FWeight)),
+	)
+	s, args, err := Evaluate(exp, new(DialectSqlite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `"persons"."id" IN (?, ?, ?) OR NOT ("persons"."name" LIKE ?) OR "persons"."weight" BETWEEN ? AND ?` +
+		` OR "persons"."age" = "persons"."weight"`
+	if s != expected {
+		t.Log(expected)
+		t.Fatal(s)
+	}
+	expectedArgs := []any{int64(1), int64(2), int64(3), "S%", 50.5, 80.0}
+	if len(args) != len(expectedArgs) {
+		t.Fatal(args)
+	}
+	for i := 0; i < len(args); i++ {
+		if args[i] != expectedArgs[i] {
+			t.Fatal(args)
+		}
+	}
+}
+
+func TestCondition_InjectionAgainstDB(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	q := sess.NewSelectQuery().Select(persons.Field(FId))
+	q.Where = W(persons.Field(FName), EQ, "x' OR 1=1 --")
+	exists, err := q.Exists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("Value should be bound, not inlined")
+	}
+
+	q = sess.NewSelectQuery().Select(persons.Field(FId))
+	q.Where = W(persons.Field(FName), EQ, "Fred_3")
+	ids, err := q.Pluck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != int64(3) {
+		t.Fatal(ids)
+	}
+}
@@ -47,6 +47,7 @@ type Field struct {
 	defaultValue string
 	table        *Table
 	rangge       *Range
+	err          error // First error from the builder methods
 }
 
 type Range struct {
@@ -69,6 +70,21 @@ func validTypeForField(v any, f *Field) error {
 			return fmt.Errorf("Table %s Field %s: Value %d is int; field type is %s", f.table.name, f.name, t, f.fieldType)
 		}
 
+	case int64:
+		if f.fieldType != IntType {
+			return fmt.Errorf("Table %s Field %s: Value %d is int64; field type is %s", f.table.name, f.name, t, f.fieldType)
+		}
+
+	case float64:
+		if f.fieldType != FloatType {
+			return fmt.Errorf("Table %s Field %s: Value %f is float64; field type is %s", f.table.name, f.name, t, f.fieldType)
+		}
+
+	case bool:
+		if f.fieldType != BoolType {
+			return fmt.Errorf("Table %s Field %s: Value %t is bool; field type is %s", f.table.name, f.name, t, f.fieldType)
+		}
+
 	case string:
 		if f.fieldType != StringType {
 			return fmt.Errorf("Table %s Field %s: Value %s is string; field type is %s", f.table.name, f.name, t, f.fieldType)
@@ -83,16 +99,23 @@ func validTypeForField(v any, f *Field) error {
 	return nil
 }
 
+// The quoted name; unquoted without a dialect
 func (f *Field) ToSqlString(d Dialect) (string, error) {
-	return f.name, nil
+	if d == nil {
+		return f.name, nil
+	}
+	return d.QuoteIdentifier(f.name), nil
 }
 
-func (f *Field) CreateFieldSql() (string, error) {
+func (f *Field) CreateFieldSql(d Dialect) (string, error) {
 	if f.name == "" {
 		return "", errors.New("Field name is empty")
 	}
+	if d == nil {
+		return "", errors.New("Field.CreateFieldSql: dialect is nil")
+	}
 
-	s := f.name + " " + sqlFieldType(f)
+	s := d.QuoteIdentifier(f.name) + " " + sqlFieldType(f)
 
 	return s, nil
 }
@@ -126,7 +149,46 @@ func sqlFieldType(f *Field) string {
 }
 
 func (f *Field) As(alias string) AField {
-	return new(FieldAs)
+	return &FieldAs{
+		field: f,
+		alias: alias,
+	}
+}
+
+// table.field; field if it is not in a table
+func (f *Field) qualifiedName() string {
+	if f.table == nil {
+		return f.name
+	}
+	return f.table.name + "." + f.name
+}
+
+func (f *Field) Name() string {
+	return f.name
+}
+
+func (f *Field) Type() FieldType {
+	return f.fieldType
+}
+
+func (f *Field) Table() *Table {
+	return f.table
+}
+
+// Ordering of fields in queries
+
+func (f *Field) Asc() *FieldOrdered {
+	return &FieldOrdered{
+		Field:    *f,
+		ordering: ASC,
+	}
+}
+
+func (f *Field) Desc() *FieldOrdered {
+	return &FieldOrdered{
+		Field:    *f,
+		ordering: DESC,
+	}
 }
 
 // SQL functions used in queries
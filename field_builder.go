@@ -0,0 +1,182 @@
+package dalkeeth
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// NewField starts building a field, i.e.
+//
+//	NewField("age", IntType).NotNull().Default(99).Range(0, 150)
+//
+// The first error in the chain is kept and returned by Err and Table.AddField.
+func NewField(name string, fieldType FieldType) *Field {
+	f := &Field{
+		name:      name,
+		fieldType: fieldType,
+	}
+	if !isIdentifier(name) {
+		f.err = fmt.Errorf("NewField: invalid field name [%s]", name)
+		return f
+	}
+	if fieldType < IntType || fieldType >= FunctionType {
+		f.err = fmt.Errorf("NewField: field %s: invalid field type %d", name, fieldType)
+	}
+	return f
+}
+
+// Err returns the first error from building the field
+func (f *Field) Err() error {
+	return f.err
+}
+
+func (f *Field) PK() *Field {
+	if f.building("PK") {
+		f.pk = true
+	}
+	return f
+}
+
+func (f *Field) NotNull() *Field {
+	if f.building("NotNull") {
+		f.notNull = true
+	}
+	return f
+}
+
+func (f *Field) Unique() *Field {
+	if f.building("Unique") {
+		f.unique = true
+	}
+	return f
+}
+
+func (f *Field) Indexed() *Field {
+	if f.building("Indexed") {
+		f.indexed = true
+	}
+	return f
+}
+
+// Length of a string field; 0 is no length
+func (f *Field) Length(n int) *Field {
+	if !f.building("Length") {
+		return f
+	}
+	if f.fieldType != StringType {
+		f.fail("Length", fmt.Errorf("only for %s; field type is %s", StringType, f.fieldType))
+		return f
+	}
+	if n < 0 {
+		f.fail("Length", fmt.Errorf("length < 0: %d", n))
+		return f
+	}
+	f.length = n
+	return f
+}
+
+// Default value of the field; its type must match the field type
+func (f *Field) Default(v any) *Field {
+	if !f.building("Default") {
+		return f
+	}
+	s, err := defaultValueString(f.fieldType, v)
+	if err != nil {
+		f.fail("Default", err)
+		return f
+	}
+	f.defaultValue = s
+	if f.rangge != nil {
+		if err = f.inRange(v); err != nil {
+			f.fail("Default", err)
+		}
+	}
+	return f
+}
+
+// Range of the values of an int field, inclusive; checked by InRecord.SetValue
+func (f *Field) Range(min, max int64) *Field {
+	if !f.building("Range") {
+		return f
+	}
+	if f.fieldType != IntType {
+		f.fail("Range", fmt.Errorf("only for %s; field type is %s", IntType, f.fieldType))
+		return f
+	}
+	if min > max {
+		f.fail("Range", fmt.Errorf("min %d > max %d", min, max))
+		return f
+	}
+	f.rangge = &Range{
+		min: min,
+		max: max,
+	}
+	if f.defaultValue != "" {
+		def, _ := strconv.ParseInt(f.defaultValue, 10, 64)
+		if err := f.inRange(def); err != nil {
+			f.fail("Range", err)
+		}
+	}
+	return f
+}
+
+// A field can no longer be changed after an error or after it is added to a table
+func (f *Field) building(method string) bool {
+	if f.err != nil {
+		return false
+	}
+	if f.table != nil {
+		f.fail(method, fmt.Errorf("already in table %s", f.table.name))
+		return false
+	}
+	return true
+}
+
+func (f *Field) fail(method string, err error) {
+	f.err = fmt.Errorf("Field.%s: field %s: %w", method, f.name, err)
+}
+
+func (f *Field) inRange(v any) error {
+	if f.rangge == nil {
+		return nil
+	}
+	var n int64
+	switch t := actualValue(v).(type) {
+	case int:
+		n = int64(t)
+	case int64:
+		n = t
+	default:
+		return nil
+	}
+	if n < f.rangge.min || n > f.rangge.max {
+		return fmt.Errorf("Field %s: value %d not in range [%d, %d]", f.name, n, f.rangge.min, f.rangge.max)
+	}
+	return nil
+}
+
+func defaultValueString(fieldType FieldType, v any) (string, error) {
+	switch t := v.(type) {
+	case int:
+		if fieldType == IntType {
+			return strconv.Itoa(t), nil
+		}
+	case int64:
+		if fieldType == IntType {
+			return strconv.FormatInt(t, 10), nil
+		}
+	case float64:
+		if fieldType == FloatType {
+			return strconv.FormatFloat(t, 'g', -1, 64), nil
+		}
+	case bool:
+		if fieldType == BoolType {
+			return strconv.FormatBool(t), nil
+		}
+	case string:
+		if fieldType == StringType {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("default value %v (%T) does not match field type %s", v, v, fieldType)
+}
@@ -0,0 +1,80 @@
+package dalkeeth
+
+import (
+	"testing"
+)
+
+func TestNewField_Table(t *testing.T) {
+	setupTest()
+	model := NewModel()
+	people, err := model.NewTable("people")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = people.AddFields(
+		NewField("id", IntType).PK(),
+		NewField("age", IntType).NotNull().Default(99).Range(0, 150),
+		NewField("name", StringType).Length(64).Unique().Default("none"),
+		NewField("weight", FloatType).Default(1.5),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := new(DialectSqlite3).CreateTableSql(people)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE TABLE IF NOT EXISTS "people" ("id" INT PRIMARY KEY, "age" INT NOT NULL DEFAULT 99,` +
+		" \"name\" varchar(64) UNIQUE DEFAULT 'none', \"weight\" REAL DEFAULT 1.5)"
+	if s != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+
+	rec := people.NewRecord()
+	if err = rec.SetValue("age", 150); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.SetValue("age", 151); err == nil {
+		t.Fatal("Out of range", ShouldHaveFailed)
+	}
+	if err = rec.SetValue("age", -1); err == nil {
+		t.Fatal("Out of range", ShouldHaveFailed)
+	}
+
+	// In a table
+	f := people.fieldsMap["name"].NotNull()
+	if f.Err() == nil {
+		t.Fatal("Field already in table", ShouldHaveFailed)
+	}
+}
+
+func TestNewField_Invalid(t *testing.T) {
+	setupTest()
+	fields := map[string]*Field{
+		"empty name":           NewField("", IntType),
+		"invalid name":         NewField("a b", IntType),
+		"function type":        NewField("f", FunctionType),
+		"length of int":        NewField("f", IntType).Length(10),
+		"negative length":      NewField("f", StringType).Length(-1),
+		"default type":         NewField("f", IntType).Default("a"),
+		"range of string":      NewField("f", StringType).Range(0, 10),
+		"range min > max":      NewField("f", IntType).Range(10, 0),
+		"default out of range": NewField("f", IntType).Default(20).Range(0, 10),
+		"range of default":     NewField("f", IntType).Range(0, 10).Default(20),
+	}
+
+	for name, f := range fields {
+		if f.Err() == nil {
+			t.Fatal(name, ShouldHaveFailed)
+		}
+		tbl, err := NewModel().NewTable("t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tbl.AddField(f); err == nil {
+			t.Fatal(name, "AddField", ShouldHaveFailed)
+		}
+	}
+}
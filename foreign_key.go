@@ -0,0 +1,147 @@
+package dalkeeth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ForeignKeyAction is what the database does to the referencing records when the referenced record is deleted
+// or its key is updated
+type ForeignKeyAction int
+
+const (
+	NoAction ForeignKeyAction = iota // The default: the change fails if there are referencing records
+	Restrict
+	Cascade
+	SetNull
+	SetDefault
+)
+
+func (a ForeignKeyAction) String() string {
+	return [...]string{"NO ACTION", "RESTRICT", "CASCADE", "SET NULL", "SET DEFAULT"}[a]
+}
+
+// ParseForeignKeyAction accepts the SQL names in any case, with a space or an underscore: "set null", "SET_NULL";
+// empty is NoAction
+func ParseForeignKeyAction(s string) (ForeignKeyAction, error) {
+	name := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), "_", SPACE))
+	if name == "" {
+		return NoAction, nil
+	}
+	for a := NoAction; a <= SetDefault; a++ {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return NoAction, fmt.Errorf("Unknown foreign key action [%s]", s)
+}
+
+// ForeignKeyOption is an option of Model.AddForeignKey and Model.AddCompositeForeignKey
+type ForeignKeyOption func(*ForeignKey) error
+
+func OnDelete(a ForeignKeyAction) ForeignKeyOption {
+	return func(fk *ForeignKey) error {
+		fk.onDelete = a
+		return nil
+	}
+}
+
+func OnUpdate(a ForeignKeyAction) ForeignKeyOption {
+	return func(fk *ForeignKey) error {
+		fk.onUpdate = a
+		return nil
+	}
+}
+
+// Deferrable foreign keys are checked when the transaction commits, not at each statement
+func Deferrable() ForeignKeyOption {
+	return func(fk *ForeignKey) error {
+		fk.deferrable = true
+		return nil
+	}
+}
+
+type ForeignKey struct {
+	name          string // Constraint name; set when extracted from a database that reports it
+	tbl           *Table
+	fields        []*Field
+	foreignTable  *Table
+	foreignFields []*Field
+	onDelete      ForeignKeyAction
+	onUpdate      ForeignKeyAction
+	deferrable    bool
+}
+
+func newForeignKey(tbl *Table, fields []*Field, foreignTable *Table, foreignFields []*Field, opts ...ForeignKeyOption) (*ForeignKey, error) {
+	fk := &ForeignKey{
+		tbl:           tbl,
+		fields:        fields,
+		foreignTable:  foreignTable,
+		foreignFields: foreignFields,
+	}
+	for i := 0; i < len(opts); i++ {
+		if opts[i] == nil {
+			continue
+		}
+		if err := opts[i](fk); err != nil {
+			return nil, err
+		}
+	}
+	return fk, nil
+}
+
+func (fk *ForeignKey) Table() *Table {
+	return fk.tbl
+}
+
+func (fk *ForeignKey) Fields() []*Field {
+	return fk.fields
+}
+
+func (fk *ForeignKey) ForeignTable() *Table {
+	return fk.foreignTable
+}
+
+// The referenced fields of the foreign table, in the order of Fields
+func (fk *ForeignKey) ForeignFields() []*Field {
+	return fk.foreignFields
+}
+
+func (fk *ForeignKey) OnDelete() ForeignKeyAction {
+	return fk.onDelete
+}
+
+func (fk *ForeignKey) OnUpdate() ForeignKeyAction {
+	return fk.onUpdate
+}
+
+func (fk *ForeignKey) Deferrable() bool {
+	return fk.deferrable
+}
+
+func (fk *ForeignKey) composite() bool {
+	return len(fk.fields) > 1
+}
+
+func (fk *ForeignKey) validate() error {
+	if fk.onDelete < NoAction || fk.onDelete > SetDefault || fk.onUpdate < NoAction || fk.onUpdate > SetDefault {
+		return fmt.Errorf("Table %s: unknown foreign key action", fk.tbl.name)
+	}
+	if fk.onDelete != SetNull && fk.onUpdate != SetNull {
+		return nil
+	}
+	for i := 0; i < len(fk.fields); i++ {
+		if fk.fields[i].notNull || fk.fields[i].pk {
+			return fmt.Errorf("Table %s: SET NULL foreign key action on NOT NULL field %s", fk.tbl.name, fk.fields[i].name)
+		}
+	}
+	return nil
+}
+
+func fieldNames(fields []*Field) []string {
+	names := make([]string, len(fields))
+	for i := 0; i < len(fields); i++ {
+		names[i] = fields[i].name
+	}
+	return names
+}
@@ -0,0 +1,282 @@
+package dalkeeth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const fkTestModelJSON = `{"tables": [
+ {"name": "owners",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "email", "type": "string", "notNull": true}],
+  "indexes": [{"unique": true, "fields": ["id", "email"]}]},
+ {"name": "pets",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "owner_id", "type": "int"},
+             {"name": "owner_email", "type": "string"}],
+  "foreignKeys": [{"field": "owner_id", "table": "owners", "foreignKey": "id", "onDelete": "cascade"},
+                  {"fields": ["owner_id", "owner_email"], "table": "owners", "foreignKeys": ["id", "email"],
+                   "onUpdate": "set_null", "deferrable": true}]}
+]}`
+
+func fkTestSession() (*Session, error) {
+	m, err := ReadModelJSON(strings.NewReader(fkTestModelJSON))
+	if err != nil {
+		return nil, err
+	}
+	db, err := openTestDB()
+	if err != nil {
+		return nil, err
+	}
+	sess, err := OpenDB(m, db, new(DialectSqlite3))
+	if err != nil {
+		return nil, err
+	}
+	return sess, sess.WriteModelTableSchemaToDB()
+}
+
+func TestParseForeignKeyAction(t *testing.T) {
+	for s, a := range map[string]ForeignKeyAction{
+		"":          NoAction,
+		"NO ACTION": NoAction,
+		"cascade":   Cascade,
+		"set_null":  SetNull,
+		"Set Null":  SetNull,
+		"restrict":  Restrict,
+	} {
+		got, err := ParseForeignKeyAction(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != a {
+			t.Fatalf("%s: expected %s; got %s", s, a, got)
+		}
+	}
+	if _, err := ParseForeignKeyAction("delete"); err == nil {
+		t.Fatal("Unknown action", ShouldHaveFailed)
+	}
+}
+
+func TestDialectSqlite3_ForeignKeysSql(t *testing.T) {
+	m, err := ReadModelJSON(strings.NewReader(fkTestModelJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := new(DialectSqlite3).CreateTableSql(m.TableByKey("pets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE TABLE IF NOT EXISTS "pets" ("id" INT PRIMARY KEY, "owner_id" INT, "owner_email" TEXT` +
+		`, FOREIGN KEY("owner_id") REFERENCES "owners"("id") ON DELETE CASCADE` +
+		`, FOREIGN KEY("owner_id", "owner_email") REFERENCES "owners"("id", "email") ON UPDATE SET NULL DEFERRABLE INITIALLY DEFERRED)`
+	if s != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+}
+
+func TestModel_AddForeignKey_Invalid(t *testing.T) {
+	m := NewModel()
+	owners, err := m.NewTable("owners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = owners.AddFields(NewField("id", IntType).PK(), NewField("email", StringType)); err != nil {
+		t.Fatal(err)
+	}
+	pets, err := m.NewTable("pets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = pets.AddFields(NewField("id", IntType).PK(), NewField("owner_id", IntType).NotNull()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = m.AddForeignKey(pets, "owner_id", owners, "id", OnDelete(SetNull)); err == nil {
+		t.Fatal("SET NULL on NOT NULL field", ShouldHaveFailed)
+	}
+	if err = m.AddCompositeForeignKey(pets, []string{"owner_id"}, owners, []string{"id", "email"}); err == nil {
+		t.Fatal("Different number of fields", ShouldHaveFailed)
+	}
+	if err = m.AddCompositeForeignKey(pets, []string{"owner_id", "owner_id"}, owners, []string{"id", "email"}); err == nil {
+		t.Fatal("Repeated field", ShouldHaveFailed)
+	}
+	if err = m.AddCompositeForeignKey(pets, nil, owners, nil); err == nil {
+		t.Fatal("No fields", ShouldHaveFailed)
+	}
+	if len(pets.foreignKeys) != 0 {
+		t.Fatal("Failed foreign keys should not be added")
+	}
+}
+
+func TestSession_ForeignKeysEnforced(t *testing.T) {
+	sess, err := fkTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	owners := sess.TableByKey("owners")
+	pets := sess.TableByKey("pets")
+
+	pet := pets.NewRecord()
+	if err = pet.SetValue("id", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = pet.SetValue("owner_id", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(pet); err == nil {
+		t.Fatal("Pet without owner", ShouldHaveFailed)
+	}
+
+	owner := owners.NewRecord()
+	if err = owner.SetValue("id", 7); err != nil {
+		t.Fatal(err)
+	}
+	if err = owner.SetValue("email", "a@b.c"); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(owner); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(pet); err != nil {
+		t.Fatal(err)
+	}
+
+	// ON DELETE CASCADE
+	if _, err = sess.Delete(owners, 7); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := sess.Get(pets, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec != nil {
+		t.Fatal("Pet should be deleted with its owner")
+	}
+}
+
+// Every connection of the pool of a file database
+func TestSession_ForeignKeysEnforcedPool(t *testing.T) {
+	m := jsonTestModel(t, fkTestModelJSON)
+	file := filepath.Join(t.TempDir(), "fk.db")
+	sess, err := Open(m, testSqliteDriver, file, new(DialectSqlite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	ctx := context.Background()
+	conn1, err := sess.DB().Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn1.Close()
+	conn2, err := sess.DB().Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+
+	for i, conn := range []*sql.Conn{conn1, conn2} {
+		var on int64
+		if err = conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&on); err != nil {
+			t.Fatal(err)
+		}
+		if on != 1 {
+			t.Fatalf("Foreign keys should be on for connection %d", i+1)
+		}
+	}
+
+	// Pooled without the driver parameter: used as it is
+	db, err := sql.Open(testSqliteDriver, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolSess, err := OpenDB(m, db, new(DialectSqlite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	poolSess.Close()
+}
+
+// A driver registered under another name, without a driver.Connector
+type wrappedSqliteDriver struct {
+	driver.Driver
+}
+
+var registerWrappedSqlite sync.Once
+
+func TestSession_ForeignKeysWrappedDriver(t *testing.T) {
+	const name = "dalkeeth-wrapped-sqlite"
+	registerWrappedSqlite.Do(func() {
+		db, err := sql.Open(testSqliteDriver, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sql.Register(name, wrappedSqliteDriver{db.Driver()})
+		db.Close()
+	})
+
+	m := jsonTestModel(t, fkTestModelJSON)
+	sess, err := Open(m, name, filepath.Join(t.TempDir(), "fk.db"), new(DialectSqlite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := sess.DB().Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for i := 0; i < len(conns); i++ {
+		var on int64
+		if err = conns[i].QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&on); err != nil {
+			t.Fatal(err)
+		}
+		if on != 1 {
+			t.Fatalf("Foreign keys should be on for connection %d", i+1)
+		}
+	}
+}
+
+func TestSession_ForeignKeysRoundTrip(t *testing.T) {
+	sess, err := fkTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	diff, err := sess.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Fatal("Extracted foreign keys should match the model:", diff)
+	}
+
+	var buf bytes.Buffer
+	if err = Generate(&buf, sess.model, "models"); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+	for _, s := range []string{
+		`dalkeeth:"owner_id,fk=owners.id,ondelete=cascade"`,
+		"m.AddCompositeForeignKey(tbl, []string{FPetsOwnerID, FPetsOwnerEmail}, m.TableByKey(TOwners), []string{FOwnersID, FOwnersEmail}, dalkeeth.OnUpdate(dalkeeth.SetNull), dalkeeth.Deferrable())",
+	} {
+		if !strings.Contains(src, s) {
+			t.Fatalf("Generated source should contain:\n%s\nGot:\n%s", s, src)
+		}
+	}
+}
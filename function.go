@@ -119,12 +119,15 @@ const (
 
 )
 
-var sqlFunctionNArgs = map[SQLFunctionId]int{
-	AVG:      1,
-	COUNT:    3,
-	COALESCE: 1,
-	MAX:      99,
-	RANDOM:   0,
+const MaxFunctionArgs = 100
+
+// min, max number of arguments
+var sqlFunctionNArgs = map[SQLFunctionId][2]int{
+	AVG:      {1, 1},
+	COUNT:    {0, 1}, // count(*) when no args
+	COALESCE: {2, MaxFunctionArgs},
+	MAX:      {1, MaxFunctionArgs},
+	RANDOM:   {0, 0},
 }
 
 type FunctionField struct {
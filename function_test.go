@@ -19,7 +19,7 @@ func Test_SimpleStringField(t *testing.T) {
 		t.Error(err)
 	}
 	if s != "AVG(name)" {
-		//t.Error(NotImplemented)
+		t.Error(s)
 	}
 }
 
@@ -56,3 +56,25 @@ func Test_SimpleStringField_WrongNArgs(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func Test_CountStar(t *testing.T) {
+	d := new(DialectSqlite3)
+
+	s, err := NewFunctionField(COUNT).ToSqlString(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "COUNT(*)" {
+		t.Fatal(s)
+	}
+}
+
+func Test_FunctionField_WrongNArgsError(t *testing.T) {
+	sf := StringField{fieldName: "name"}
+	ff := NewFunctionField(AVG, sf, sf)
+
+	_, err := ff.ToSqlString(new(DialectSqlite3))
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
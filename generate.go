@@ -0,0 +1,375 @@
+package dalkeeth
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Generate writes Go source for the model, in package pkg: table and field name constants, a struct for each
+// table (see TableFromStruct), field accessors, a Repo constructor for each table and a NewModel func.
+// Fields that can be NULL are pointers.
+func Generate(w io.Writer, m *Model, pkg string) error {
+	if w == nil {
+		return errors.New("Generate: writer is nil")
+	}
+	if m == nil {
+		return errors.New("Generate: model is nil")
+	}
+	if !isIdentifier(pkg) {
+		return fmt.Errorf("Generate: invalid package name [%s]", pkg)
+	}
+
+	tables, err := tablesByDependency(m.tables)
+	if err != nil {
+		return err
+	}
+	g := generator{
+		names: make(map[string]string),
+	}
+	if err = g.nameTables(tables); err != nil {
+		return err
+	}
+
+	g.printf("// Code generated by dalkeeth-gen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", pkg)
+	g.printf("import (\n\t\"github.com/gnewton/dalkeeth\"\n)\n\n")
+
+	g.printf("// Table keys\nconst (\n")
+	for i := 0; i < len(tables); i++ {
+		g.printf("\t%s = %q\n", g.tableConst(tables[i]), tables[i].name)
+	}
+	g.printf(")\n\n")
+
+	g.printf("// Field names\nconst (\n")
+	for i := 0; i < len(tables); i++ {
+		for j := 0; j < len(tables[i].fields); j++ {
+			f := tables[i].fields[j]
+			g.printf("\t%s = %q\n", g.fieldConst(f), f.name)
+		}
+	}
+	g.printf(")\n\n")
+
+	for i := 0; i < len(tables); i++ {
+		if err = g.table(tables[i]); err != nil {
+			return err
+		}
+	}
+
+	g.model(tables)
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("Generate: %w", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// GenerateFromDB writes the Go source (see Generate) for the tables in the database
+func GenerateFromDB(w io.Writer, db *sql.DB, d Dialect, pkg string) error {
+	m, err := ExtractModel(db, d)
+	if err != nil {
+		return err
+	}
+	return Generate(w, m, pkg)
+}
+
+// Foreign tables before the tables referencing them, otherwise in model order
+func tablesByDependency(tables []*Table) ([]*Table, error) {
+	sorted := make([]*Table, 0, len(tables))
+	state := make(map[*Table]int) // 1: visiting, 2: done
+
+	var visit func(t *Table) error
+	visit = func(t *Table) error {
+		switch state[t] {
+		case 1:
+			return fmt.Errorf("Foreign key cycle at table %s", t.name)
+		case 2:
+			return nil
+		}
+		state[t] = 1
+		for i := 0; i < len(t.foreignKeys); i++ {
+			if ft := t.foreignKeys[i].foreignTable; ft != t {
+				if err := visit(ft); err != nil {
+					return err
+				}
+			}
+		}
+		state[t] = 2
+		sorted = append(sorted, t)
+		return nil
+	}
+
+	for i := 0; i < len(tables); i++ {
+		if err := visit(tables[i]); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+type generator struct {
+	buf   bytes.Buffer
+	names map[string]string // Go name: what it names
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) nameTables(tables []*Table) error {
+	for i := 0; i < len(tables); i++ {
+		t := tables[i]
+		for _, name := range []string{g.tableType(t), g.tableConst(t), "New" + g.tableType(t) + "Repo"} {
+			if err := g.reserve(name, "table "+t.name); err != nil {
+				return err
+			}
+		}
+		for j := 0; j < len(t.fields); j++ {
+			f := t.fields[j]
+			what := "field " + f.qualifiedName()
+			if err := g.reserve(g.fieldConst(f), what); err != nil {
+				return err
+			}
+			if err := g.reserve(g.fieldFunc(f), what); err != nil {
+				return err
+			}
+		}
+	}
+	return g.reserve("NewModel", "model")
+}
+
+func (g *generator) reserve(goName, what string) error {
+	if other, ok := g.names[goName]; ok {
+		return fmt.Errorf("Generate: Go name %s of %s collides with %s", goName, what, other)
+	}
+	g.names[goName] = what
+	return nil
+}
+
+func (g *generator) tableType(t *Table) string {
+	return goName(t.name)
+}
+
+func (g *generator) tableConst(t *Table) string {
+	return "T" + goName(t.name)
+}
+
+func (g *generator) fieldConst(f *Field) string {
+	return "F" + goName(f.table.name) + goName(f.name)
+}
+
+func (g *generator) fieldFunc(f *Field) string {
+	return goName(f.table.name) + goName(f.name) + "Field"
+}
+
+func (g *generator) table(t *Table) error {
+	typeName := g.tableType(t)
+	g.printf("// %s is a record of table %s\n", typeName, t.name)
+	g.printf("type %s struct {\n", typeName)
+	for i := 0; i < len(t.fields); i++ {
+		f := t.fields[i]
+		tag, err := structTag(f)
+		if err != nil {
+			return err
+		}
+		g.printf("\t%s %s %s\n", goName(f.name), goType(f), tag)
+	}
+	g.printf("}\n\n")
+
+	for i := 0; i < len(t.fields); i++ {
+		f := t.fields[i]
+		g.printf("// %s returns field %s of table %s in the model\n", g.fieldFunc(f), f.name, t.name)
+		g.printf("func %s(m *dalkeeth.Model) *dalkeeth.Field {\n", g.fieldFunc(f))
+		g.printf("\treturn m.TableByKey(%s).Field(%s)\n}\n\n", g.tableConst(t), g.fieldConst(f))
+	}
+
+	g.printf("// New%sRepo returns the Repo of table %s\n", typeName, t.name)
+	g.printf("func New%sRepo(sess *dalkeeth.Session) (*dalkeeth.Repo[%s], error) {\n", typeName, typeName)
+	g.printf("\treturn dalkeeth.NewRepoS[%s](sess, %s)\n}\n\n", typeName, g.tableConst(t))
+	return nil
+}
+
+func (g *generator) model(tables []*Table) {
+	needTbl := false
+	for i := 0; i < len(tables); i++ {
+		needTbl = needTbl || len(tables[i].indexes) > 0 || len(modelForeignKeys(tables[i])) > 0
+	}
+
+	g.printf("// NewModel returns the frozen model of the tables\n")
+	g.printf("func NewModel() (*dalkeeth.Model, error) {\n")
+	g.printf("\tm := dalkeeth.NewModel()\n")
+	if needTbl {
+		g.printf("\tvar tbl *dalkeeth.Table\n")
+	}
+	g.printf("\tvar err error\n\n")
+
+	for i := 0; i < len(tables); i++ {
+		t := tables[i]
+		fks := modelForeignKeys(t)
+		result := "_"
+		if len(t.indexes) > 0 || len(fks) > 0 {
+			result = "tbl"
+		}
+		g.printf("\tif %s, err = m.TableFromStruct(%s, %s{}); err != nil {\n\t\treturn nil, err\n\t}\n", result, g.tableConst(t), g.tableType(t))
+		for j := 0; j < len(t.indexes); j++ {
+			idx := t.indexes[j]
+			g.printf("\tif err = tbl.AddIndex(%t, %s); err != nil {\n\t\treturn nil, err\n\t}\n", idx.unique, strings.Join(g.fieldConsts(idx.fields), COMMA_SPACE))
+		}
+		for j := 0; j < len(fks); j++ {
+			fk := fks[j]
+			args := []string{
+				"tbl",
+				"[]string{" + strings.Join(g.fieldConsts(fk.fields), COMMA_SPACE) + "}",
+				"m.TableByKey(" + g.tableConst(fk.foreignTable) + ")",
+				"[]string{" + strings.Join(g.fieldConsts(fk.foreignFields), COMMA_SPACE) + "}",
+			}
+			if fk.onDelete != NoAction {
+				args = append(args, "dalkeeth.OnDelete(dalkeeth."+actionGoNames[fk.onDelete]+")")
+			}
+			if fk.onUpdate != NoAction {
+				args = append(args, "dalkeeth.OnUpdate(dalkeeth."+actionGoNames[fk.onUpdate]+")")
+			}
+			if fk.deferrable {
+				args = append(args, "dalkeeth.Deferrable()")
+			}
+			g.printf("\tif err = m.AddCompositeForeignKey(%s); err != nil {\n\t\treturn nil, err\n\t}\n", strings.Join(args, COMMA_SPACE))
+		}
+	}
+	g.printf("\n\treturn m, m.Freeze()\n}\n")
+}
+
+// Foreign keys of the table which are not in struct tags
+func modelForeignKeys(t *Table) []*ForeignKey {
+	var fks []*ForeignKey
+	for i := 0; i < len(t.foreignKeys); i++ {
+		fk := t.foreignKeys[i]
+		if fk.composite() || tagForeignKey(fk.fields[0]) != fk {
+			fks = append(fks, fk)
+		}
+	}
+	return fks
+}
+
+func (g *generator) fieldConsts(fields []*Field) []string {
+	consts := make([]string, len(fields))
+	for i := 0; i < len(fields); i++ {
+		consts[i] = g.fieldConst(fields[i])
+	}
+	return consts
+}
+
+func structTag(f *Field) (string, error) {
+	opts := []string{f.name}
+	if f.pk {
+		opts = append(opts, "pk")
+	}
+	if f.notNull {
+		opts = append(opts, "notnull")
+	}
+	if f.unique {
+		opts = append(opts, "unique")
+	}
+	if f.length != 0 {
+		opts = append(opts, "len="+strconv.Itoa(f.length))
+	}
+	if f.defaultValue != "" {
+		if strings.Contains(f.defaultValue, ",") {
+			return "", fmt.Errorf("Generate: field %s: default value [%s] with a comma cannot be a struct tag", f.qualifiedName(), f.defaultValue)
+		}
+		opts = append(opts, "default="+f.defaultValue)
+	}
+	if fk := tagForeignKey(f); fk != nil {
+		opts = append(opts, "fk="+fk.foreignTable.name+"."+fk.foreignFields[0].name)
+		if fk.onDelete != NoAction {
+			opts = append(opts, "ondelete="+actionTagValue(fk.onDelete))
+		}
+		if fk.onUpdate != NoAction {
+			opts = append(opts, "onupdate="+actionTagValue(fk.onUpdate))
+		}
+		if fk.deferrable {
+			opts = append(opts, "deferrable")
+		}
+	}
+
+	tag := StructTag + ":" + strconv.Quote(strings.Join(opts, ","))
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag), nil
+	}
+	return "`" + tag + "`", nil
+}
+
+// The foreign key of the field written in its struct tag: the first single field one.
+// The others are added in NewModel.
+func tagForeignKey(f *Field) *ForeignKey {
+	for i := 0; i < len(f.table.foreignKeys); i++ {
+		fk := f.table.foreignKeys[i]
+		if !fk.composite() && fk.fields[0] == f {
+			return fk
+		}
+	}
+	return nil
+}
+
+func actionTagValue(a ForeignKeyAction) string {
+	return strings.ToLower(strings.ReplaceAll(a.String(), SPACE, "_"))
+}
+
+var actionGoNames = [...]string{"NoAction", "Restrict", "Cascade", "SetNull", "SetDefault"}
+
+// Fields that can be NULL are pointers, except []byte
+func goType(f *Field) string {
+	var t string
+	switch f.fieldType {
+	case IntType:
+		t = "int64"
+	case StringType:
+		t = "string"
+	case BoolType:
+		t = "bool"
+	case FloatType:
+		t = "float64"
+	case ByteArrayType:
+		return "[]byte"
+	}
+	if !f.notNull && !f.pk {
+		t = "*" + t
+	}
+	return t
+}
+
+var goInitialisms = map[string]string{
+	"id":   "ID",
+	"json": "JSON",
+	"sql":  "SQL",
+	"url":  "URL",
+	"uuid": "UUID",
+}
+
+// person_address -> PersonAddress; address_id -> AddressID
+func goName(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var name string
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if initialism, ok := goInitialisms[strings.ToLower(w)]; ok {
+			name += initialism
+			continue
+		}
+		r := []rune(w)
+		name += string(unicode.ToUpper(r[0])) + string(r[1:])
+	}
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
@@ -0,0 +1,140 @@
+package dalkeeth
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const testModelJSON = `{"tables": [
+ {"name": "person_address",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "person_id", "type": "int", "notNull": true},
+             {"name": "address_id", "type": "int", "notNull": true}],
+  "indexes": [{"unique": true, "fields": ["person_id", "address_id"]}],
+  "foreignKeys": [{"field": "person_id", "table": "persons", "foreignKey": "id"}]},
+ {"name": "persons",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "age", "type": "int", "default": 99},
+             {"name": "name", "type": "string", "length": 64, "notNull": true, "default": "no-name"},
+             {"name": "photo", "type": "bytes"}]}
+]}`
+
+func TestReadModelJSON(t *testing.T) {
+	setupTest()
+	m, err := ReadModelJSON(strings.NewReader(testModelJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.frozen {
+		t.Fatal("Model should be frozen")
+	}
+
+	persons := m.TableByKey("persons")
+	if persons == nil || persons.pk == nil || persons.pk.name != "id" {
+		t.Fatal("persons should have pk id")
+	}
+	name := persons.Field("name")
+	if name.length != 64 || !name.notNull || name.defaultValue != "no-name" {
+		t.Fatalf("Wrong name field: %+v", name)
+	}
+	if persons.Field("age").defaultValue != "99" {
+		t.Fatal("Wrong age default")
+	}
+
+	pa := m.TableByKey("person_address")
+	if len(pa.foreignKeys) != 1 || pa.foreignKeys[0].foreignTable != persons {
+		t.Fatal("person_address should have foreign key to persons")
+	}
+	if len(pa.indexes) != 1 || !pa.indexes[0].unique {
+		t.Fatal("person_address should have unique index")
+	}
+
+	for name, s := range map[string]string{
+		"unknown type":          `{"tables": [{"name": "t", "fields": [{"name": "id", "type": "uint"}]}]}`,
+		"unknown attribute":     `{"tables": [{"name": "t", "fields": [{"name": "id", "type": "int", "primary": true}]}]}`,
+		"default type":          `{"tables": [{"name": "t", "fields": [{"name": "id", "type": "int", "default": 1.5}]}]}`,
+		"unknown foreign table": `{"tables": [{"name": "t", "fields": [{"name": "id", "type": "int"}], "foreignKeys": [{"field": "id", "table": "x", "foreignKey": "id"}]}]}`,
+		"no tables":             `{}`,
+	} {
+		if _, err = ReadModelJSON(strings.NewReader(s)); err == nil {
+			t.Fatal(name, ShouldHaveFailed)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	setupTest()
+	m, err := ReadModelJSON(strings.NewReader(testModelJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = Generate(&buf, m, "models"); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+	if _, err = parser.ParseFile(token.NewFileSet(), "models_gen.go", src, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"package models",
+		`TPersonAddress = "person_address"`,
+		`FPersonAddressPersonID  = "person_id"`,
+		"Age   *int64 `dalkeeth:\"age,default=99\"`",
+		"Name  string `dalkeeth:\"name,notnull,len=64,default=no-name\"`",
+		"Photo []byte `dalkeeth:\"photo\"`",
+		"PersonID  int64 `dalkeeth:\"person_id,notnull,fk=persons.id\"`",
+		"func PersonsAgeField(m *dalkeeth.Model) *dalkeeth.Field {",
+		"func NewPersonsRepo(sess *dalkeeth.Session) (*dalkeeth.Repo[Persons], error) {",
+		"if err = tbl.AddIndex(true, FPersonAddressPersonID, FPersonAddressAddressID); err != nil {",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Fatalf("Should contain:\n%s\nGot:\n%s", expected, src)
+		}
+	}
+
+	// Foreign table first
+	if strings.Index(src, "m.TableFromStruct(TPersons,") > strings.Index(src, "m.TableFromStruct(TPersonAddress,") {
+		t.Fatal("persons should be created before person_address")
+	}
+
+	if err = Generate(&buf, m, "bad name"); err == nil {
+		t.Fatal("Package name", ShouldHaveFailed)
+	}
+}
+
+func TestGenerateFromDB(t *testing.T) {
+	setupTest()
+	mdl0, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(mdl0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	var buf bytes.Buffer
+	if err = GenerateFromDB(&buf, sess.db, sess.dialect, "models"); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+
+	for _, expected := range []string{
+		`TAddresses     = "addresses"`,
+		"Street string `dalkeeth:\"street,notnull,len=64\"`",
+		"Name    *string  `dalkeeth:\"name,default=no-name\"`",
+		"Citizen *bool    `dalkeeth:\"citizen,default=true\"`",
+		"Weight  *float64 `dalkeeth:\"weight,default=1\"`",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Fatalf("Should contain:\n%s\nGot:\n%s", expected, src)
+		}
+	}
+}
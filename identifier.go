@@ -0,0 +1,78 @@
+package dalkeeth
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The SQL generated by the dialects quotes every table, field, index and constraint name with
+// Dialect.QuoteIdentifier, so that reserved words (i.e. a table called order or a field called group) and other
+// characters can be used as names. Names are checked with Dialect.ValidIdentifier and Dialect.ValidTableName.
+
+// Standard SQL identifier quote: SQLite and Postgres
+const ansiQuote = `"`
+
+// quoteIdentifier encloses the name in the quote character, doubling the quote characters in the name
+func quoteIdentifier(name, quote string) string {
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
+
+// validIdentifier checks the rules common to the dialects: a name is not empty, is UTF-8, has no NUL character,
+// and is at most maxLength bytes if maxLength > 0
+func validIdentifier(d Dialect, name string, maxLength int) error {
+	switch {
+	case len(name) == 0:
+		return fmt.Errorf("Invalid name for dialect %s: name is empty", d.DialectName())
+	case !utf8.ValidString(name):
+		return fmt.Errorf("Invalid name [%q] for dialect %s: not UTF-8", name, d.DialectName())
+	case strings.ContainsRune(name, 0):
+		return fmt.Errorf("Invalid name [%q] for dialect %s: has a NUL character", name, d.DialectName())
+	case maxLength > 0 && len(name) > maxLength:
+		return fmt.Errorf("Invalid name [%s] for dialect %s: longer than %d bytes", name, d.DialectName(), maxLength)
+	}
+	return nil
+}
+
+// quoteAll quotes the names and joins them with commas
+func quoteAll(d Dialect, names []string) string {
+	quoted := make([]string, len(names))
+	for i := 0; i < len(names); i++ {
+		quoted[i] = d.QuoteIdentifier(names[i])
+	}
+	return strings.Join(quoted, COMMA_SPACE)
+}
+
+// quoteFields quotes the names of the fields and joins them with commas
+func quoteFields(d Dialect, fields []*Field) string {
+	return quoteAll(d, fieldNames(fields))
+}
+
+// quoteField is the quoted table.field; field if it is not in a table. Unquoted without a dialect.
+func quoteField(d Dialect, f *Field) string {
+	if d == nil {
+		return f.qualifiedName()
+	}
+	if f.table == nil {
+		return d.QuoteIdentifier(f.name)
+	}
+	return d.QuoteIdentifier(f.table.name) + "." + d.QuoteIdentifier(f.name)
+}
+
+// validFields checks the names of the fields of the table
+func validFields(d Dialect, t *Table) error {
+	for i := 0; i < len(t.fields); i++ {
+		if err := d.ValidIdentifier(t.fields[i].name); err != nil {
+			return fmt.Errorf("Table %s: field: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+// nameOrExpression quotes s if it is a plain name; an SQL expression is used as is
+func nameOrExpression(d Dialect, s string) string {
+	if d == nil || !isIdentifier(s) {
+		return s
+	}
+	return d.QuoteIdentifier(s)
+}
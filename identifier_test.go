@@ -0,0 +1,285 @@
+package dalkeeth
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// Reserved words as table, field and index names
+const reservedWordsModelJSON = `{"tables": [
+ {"name": "order",
+  "fields": [{"name": "group", "type": "int", "pk": true},
+             {"name": "select", "type": "string", "length": 32, "notNull": true},
+             {"name": "from", "type": "float"}],
+  "indexes": [{"fields": ["select"]}]},
+ {"name": "where",
+  "fields": [{"name": "order", "type": "int", "pk": true},
+             {"name": "group", "type": "int"}],
+  "foreignKeys": [{"field": "group", "table": "order", "foreignKey": "group", "onDelete": "cascade"}]}
+]}`
+
+func TestQuoteIdentifier(t *testing.T) {
+	for _, c := range []struct {
+		d        Dialect
+		expected string
+	}{
+		{new(DialectSqlite3), `"a""b"`},
+		{new(DialectPostgres), `"a""b"`},
+		{new(DialectMySQL), "`a\"b`"},
+	} {
+		if s := c.d.QuoteIdentifier(`a"b`); s != c.expected {
+			t.Fatalf("%s: expected %s; got %s", c.d.DialectName(), c.expected, s)
+		}
+	}
+	if s := new(DialectMySQL).QuoteIdentifier("a`b"); s != "`a``b`" {
+		t.Fatal(s)
+	}
+}
+
+func TestValidIdentifier(t *testing.T) {
+	for _, d := range []Dialect{new(DialectSqlite3), new(DialectPostgres), new(DialectMySQL)} {
+		for _, name := range []string{"order", "group", "a b", `a"b`, "a`b", "名前"} {
+			if err := d.ValidIdentifier(name); err != nil {
+				t.Fatal(d.DialectName(), err)
+			}
+		}
+		for _, name := range []string{"", "a\x00b", "\xff"} {
+			if err := d.ValidIdentifier(name); err == nil {
+				t.Fatalf("%s: [%q] %s", d.DialectName(), name, ShouldHaveFailed)
+			}
+		}
+	}
+
+	long := strings.Repeat("x", 64)
+	if err := new(DialectSqlite3).ValidIdentifier(long); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(DialectPostgres).ValidIdentifier(long); err == nil {
+		t.Fatal("64 byte Postgres name", ShouldHaveFailed)
+	}
+	if err := new(DialectMySQL).ValidIdentifier(long + "x"); err == nil {
+		t.Fatal("65 byte MySQL name", ShouldHaveFailed)
+	}
+	if err := new(DialectMySQL).ValidIdentifier("trailing "); err == nil {
+		t.Fatal("MySQL name ending with a space", ShouldHaveFailed)
+	}
+	if err := new(DialectSqlite3).ValidTableName("SQLITE_master"); err == nil {
+		t.Fatal("sqlite_ table name", ShouldHaveFailed)
+	}
+
+	// Field names are checked by CreateTableSql
+	m := jsonTestModel(t, reservedWordsModelJSON)
+	tbl := m.TableByKey("order")
+	tbl.Field("from").name = strings.Repeat("f", 64)
+	if _, err := new(DialectPostgres).CreateTableSql(tbl); err == nil {
+		t.Fatal("64 byte Postgres field name", ShouldHaveFailed)
+	}
+}
+
+func TestDialectSqlite3_ReservedWordsSql(t *testing.T) {
+	m := jsonTestModel(t, reservedWordsModelJSON)
+	d := new(DialectSqlite3)
+	order := m.TableByKey("order")
+
+	s, err := d.CreateTableIndexSql(order.indexes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `CREATE  INDEX "idx_order_select" ON "order"("select")` {
+		t.Fatal(s)
+	}
+
+	rec := order.NewRecord()
+	if err = rec.SetValue("group", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.SetValue("select", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = d.UpsertSql(rec); err != nil {
+		t.Fatal(err)
+	}
+	if s != `INSERT INTO "order" ("group", "select") VALUES (?, ?) ON CONFLICT("group") DO UPDATE SET "select"=excluded."select"` {
+		t.Fatal(s)
+	}
+
+	q := NewQuery().Select(order.Field("select")).From(order).Where(W("from", GT, 1.5)).
+		OrderBy(NewOrderBy(order.Field("group"), DESC))
+	s, _, err = d.SelectQuerySql2(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `SELECT "order"."select" FROM "order" WHERE "from" > ? ORDER BY "order"."group" DESC` {
+		t.Fatal(s)
+	}
+
+	if s, err = order.indexes[0].CreateSql(d, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s != `CREATE INDEX IF NOT EXISTS "idx_order_0" ON "order"("select")` {
+		t.Fatal(s)
+	}
+}
+
+func TestSession_ReservedWords(t *testing.T) {
+	m := jsonTestModel(t, reservedWordsModelJSON)
+	db, err := openTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := OpenDB(m, db, new(DialectSqlite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	if err = sess.WriteModelTableSchemaToDB(); err != nil {
+		t.Fatal(err)
+	}
+
+	order := m.TableByKey("order")
+	where := m.TableByKey("where")
+	for i := int64(1); i <= 3; i++ {
+		rec := order.NewRecord()
+		if err = rec.SetValue("group", i); err != nil {
+			t.Fatal(err)
+		}
+		if err = rec.SetValue("select", "s"+string(rune('0'+i))); err != nil {
+			t.Fatal(err)
+		}
+		if err = rec.SetValue("from", float64(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err = sess.Save(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := where.NewRecord()
+	if err = rec.SetValue("order", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.SetValue("group", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Upsert(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sess.Get(order, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err = got.GetString("select", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "s2" {
+		t.Fatal("Expected s2; got", s)
+	}
+
+	if _, err = sess.UpdateWhere(order, W("from", GE, 2.0), NewValue(order.Field("select"), "big")); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := sess.ExecuteQuery(NewQuery().Select(order.Field("group")).From(order).
+		Join(order.Field("group"), where.Field("group")).Where(W(order.Field("select"), NE, "big")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var groups []int64
+	for rows.Next() {
+		var g int64
+		if err = rows.Scan(&g); err != nil {
+			t.Fatal(err)
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+	if len(groups) != 1 || groups[0] != 1 {
+		t.Fatal("Expected [1]; got", groups)
+	}
+
+	n, err := order.Count(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatal("Expected 3 records; got", n)
+	}
+	var f float64
+	ok, err := FindFieldValueById(order, sess.db, sess.dialect, 3, order.Field("from"), &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || f != 3 {
+		t.Fatal("Expected 3; got", ok, f)
+	}
+
+	// ON DELETE CASCADE
+	if _, err = sess.Delete(order, 1); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err = sess.Exists(where, 10); err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("Expected the record to be deleted with its foreign record")
+	}
+
+	// Extracted and migrated
+	diff, err := sess.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Fatal("Expected no differences; got\n", diff)
+	}
+	want := jsonTestModel(t, strings.Replace(reservedWordsModelJSON, `"type": "float"}`, `"type": "float"}, {"name": "limit", "type": "int", "unique": true}`, 1))
+	have, err := ExtractModel(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, err = DiffSchema(want, have); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := sess.dialect.MigrationPlan(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.ApplyMigration(context.Background(), plan); err != nil {
+		t.Fatal(plan, err)
+	}
+	if n, err = order.Count(sess.db, sess.dialect); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatal("Expected 2 records after the migration; got", n)
+	}
+}
+
+// The Table and Index SQL is quoted by the dialect
+func TestTable_QuotedSql(t *testing.T) {
+	m := jsonTestModel(t, reservedWordsModelJSON)
+	order := m.TableByKey("order")
+	d := new(DialectMySQL)
+
+	s, err := order.CreateTableSql(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "CREATE TABLE IF NOT EXISTS `order` (`group` INT PRIMARY KEY, `select` varchar(32) NOT NULL, `from` REAL)" {
+		t.Fatal(s)
+	}
+	if s, err = order.indexes[0].CreateSql(d, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s != "CREATE INDEX IF NOT EXISTS `idx_order_0` ON `order`(`select`)" {
+		t.Fatal(s)
+	}
+	if s = order.AllFields(d); s != "`group`, `select`, `from`" {
+		t.Fatal(s)
+	}
+
+	if _, err = order.Count(nil, nil); err == nil {
+		t.Fatal("Nil dialect", ShouldHaveFailed)
+	}
+}
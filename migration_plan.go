@@ -0,0 +1,57 @@
+package dalkeeth
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Ordered dialect SQL statements migrating a database to a model, made by Dialect.MigrationPlan from a SchemaDiff
+type MigrationPlan struct {
+	Statements []string
+}
+
+func (p *MigrationPlan) Empty() bool {
+	return len(p.Statements) == 0
+}
+
+// The SQL script of the plan, one statement per line
+func (p *MigrationPlan) String() string {
+	var s string
+	for i := 0; i < len(p.Statements); i++ {
+		s += strings.TrimSpace(p.Statements[i]) + ";\n"
+	}
+	return s
+}
+
+// PlanMigration returns the plan migrating the database to the session model
+func (sess *Session) PlanMigration() (*MigrationPlan, error) {
+	diff, err := sess.DiffSchema()
+	if err != nil {
+		return nil, err
+	}
+	return sess.dialect.MigrationPlan(diff)
+}
+
+// ApplyMigration runs the plan in one transaction; not possible when a transaction has been started
+func (sess *Session) ApplyMigration(ctx context.Context, plan *MigrationPlan) error {
+	if sess.readOnly {
+		return errors.New("ApplyMigration: session is read-only")
+	}
+	if plan == nil {
+		return errors.New("session.ApplyMigration: plan is nil")
+	}
+	if sess.tx != nil {
+		return errors.New("session.ApplyMigration: transaction already started")
+	}
+	if sess.db == nil {
+		return errors.New("session.ApplyMigration: db is nil")
+	}
+	if sess.dialect == nil {
+		return errors.New("session.ApplyMigration: dialect is nil")
+	}
+	if plan.Empty() {
+		return nil
+	}
+	return sess.dialect.ApplyMigration(ctx, sess.db, plan)
+}
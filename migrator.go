@@ -0,0 +1,473 @@
+package dalkeeth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultMigrationsTable = "schema_migrations"
+
+// Migrate to the most recent migration
+const LatestVersion int64 = -1
+
+// Version of the row inserted in the migrations table to lock it while a migration runs; never committed
+// on a dialect with transactional DDL
+const migrationLockVersion int64 = 0
+
+// A versioned schema migration, written as SQL or as Go funcs. Down is optional, but needed to migrate back.
+type Migration struct {
+	Version int64 // > 0
+	Name    string
+	UpSql   string
+	DownSql string
+	Up      func(ctx context.Context, tx *sql.Tx) error
+	Down    func(ctx context.Context, tx *sql.Tx) error
+}
+
+// Checksum of the up SQL, which must not change once the migration is applied; the down can be added or fixed.
+// Go funcs cannot be checksummed: only the version and name are.
+func (mig *Migration) Checksum() string {
+	h := sha256.New()
+	io.WriteString(h, strconv.FormatInt(mig.Version, 10)+"\n"+mig.Name+"\n")
+	if mig.Up == nil {
+		io.WriteString(h, mig.UpSql+"\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (mig *Migration) hasDown() bool {
+	return mig.Down != nil || strings.TrimSpace(mig.DownSql) != ""
+}
+
+// A migration to run, up or down
+type MigrationStep struct {
+	Migration *Migration
+	Up        bool
+}
+
+func (step MigrationStep) String() string {
+	direction := "down"
+	if step.Up {
+		direction = "up"
+	}
+	return fmt.Sprintf("%d_%s %s", step.Migration.Version, step.Migration.Name, direction)
+}
+
+// Migrator runs migrations and records the applied ones in a table of the database
+type Migrator struct {
+	sess       *Session
+	migrations []*Migration // By version
+	table      string
+	dryRun     io.Writer
+}
+
+type MigratorOption func(*Migrator) error
+
+// WithMigrationsTable sets the name of the table recording the applied migrations
+func WithMigrationsTable(name string) MigratorOption {
+	return func(mg *Migrator) error {
+		if !isIdentifier(name) {
+			return fmt.Errorf("WithMigrationsTable: invalid table name [%s]", name)
+		}
+		mg.table = name
+		return nil
+	}
+}
+
+// WithDryRun makes Migrate write the steps and their SQL to w instead of running them
+func WithDryRun(w io.Writer) MigratorOption {
+	return func(mg *Migrator) error {
+		if w == nil {
+			return errors.New("WithDryRun: writer is nil")
+		}
+		mg.dryRun = w
+		return nil
+	}
+}
+
+func NewMigrator(sess *Session, migrations []*Migration, opts ...MigratorOption) (*Migrator, error) {
+	if sess == nil {
+		return nil, errors.New("NewMigrator: session is nil")
+	}
+	if sess.db == nil {
+		return nil, errors.New("NewMigrator: db is nil")
+	}
+	if sess.dialect == nil {
+		return nil, errors.New("NewMigrator: dialect is nil")
+	}
+
+	mg := &Migrator{
+		sess:       sess,
+		migrations: make([]*Migration, 0, len(migrations)),
+		table:      DefaultMigrationsTable,
+	}
+	versions := make(map[int64]struct{}, len(migrations))
+	for i := 0; i < len(migrations); i++ {
+		mig := migrations[i]
+		if mig == nil {
+			return nil, fmt.Errorf("NewMigrator: migration %d is nil", i)
+		}
+		if mig.Version <= 0 {
+			return nil, fmt.Errorf("NewMigrator: migration %s: version <= 0: %d", mig.Name, mig.Version)
+		}
+		if _, ok := versions[mig.Version]; ok {
+			return nil, fmt.Errorf("NewMigrator: duplicate version %d", mig.Version)
+		}
+		versions[mig.Version] = struct{}{}
+		if mig.Up == nil && strings.TrimSpace(mig.UpSql) == "" {
+			return nil, fmt.Errorf("NewMigrator: migration %d has no up", mig.Version)
+		}
+		mg.migrations = append(mg.migrations, mig)
+	}
+	sort.Slice(mg.migrations, func(i, j int) bool {
+		return mg.migrations[i].Version < mg.migrations[j].Version
+	})
+
+	for i := 0; i < len(opts); i++ {
+		if opts[i] == nil {
+			continue
+		}
+		if err := opts[i](mg); err != nil {
+			return nil, err
+		}
+	}
+	return mg, nil
+}
+
+// MigrationsFromFS reads the SQL migrations in dir: <version>_<name>.up.sql and the optional <version>_<name>.down.sql
+func MigrationsFromFS(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	var migrations []*Migration
+	for i := 0; i < len(entries); i++ {
+		fileName := entries[i].Name()
+		if entries[i].IsDir() || !strings.HasSuffix(fileName, ".sql") {
+			continue
+		}
+		base := strings.TrimSuffix(fileName, ".sql")
+		up := strings.HasSuffix(base, ".up")
+		if !up && !strings.HasSuffix(base, ".down") {
+			return nil, fmt.Errorf("MigrationsFromFS: %s: need .up.sql or .down.sql", fileName)
+		}
+		base = strings.TrimSuffix(strings.TrimSuffix(base, ".up"), ".down")
+		v, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("MigrationsFromFS: %s: invalid version [%s]", fileName, v)
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{
+				Version: version,
+				Name:    name,
+			}
+			byVersion[version] = mig
+			migrations = append(migrations, mig)
+		} else if mig.Name != name {
+			return nil, fmt.Errorf("MigrationsFromFS: version %d has names %s and %s", version, mig.Name, name)
+		}
+		if up {
+			mig.UpSql = string(b)
+		} else {
+			mig.DownSql = string(b)
+		}
+	}
+	return migrations, nil
+}
+
+// Version returns the version of the most recent applied migration; 0 if none
+func (mg *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := mg.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return lastVersion(applied), nil
+}
+
+// Steps returns the migrations to run to go from the current version to the target version
+// (0 undoes all the migrations; LatestVersion applies all). The applied migrations are verified.
+func (mg *Migrator) Steps(ctx context.Context, target int64) ([]MigrationStep, error) {
+	applied, err := mg.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mg.steps(applied, target)
+}
+
+func (mg *Migrator) steps(applied map[int64]appliedMigration, target int64) ([]MigrationStep, error) {
+	if err := mg.verify(applied); err != nil {
+		return nil, err
+	}
+
+	if target == LatestVersion {
+		target = 0
+		if len(mg.migrations) > 0 {
+			target = mg.migrations[len(mg.migrations)-1].Version
+		}
+	}
+	if target != 0 && mg.migration(target) == nil {
+		return nil, fmt.Errorf("Migrator: unknown target version %d", target)
+	}
+
+	var steps []MigrationStep
+	for i := 0; i < len(mg.migrations); i++ {
+		mig := mg.migrations[i]
+		if _, ok := applied[mig.Version]; !ok && mig.Version <= target {
+			steps = append(steps, MigrationStep{Migration: mig, Up: true})
+		}
+	}
+	for i := len(mg.migrations) - 1; i >= 0; i-- {
+		mig := mg.migrations[i]
+		if _, ok := applied[mig.Version]; ok && mig.Version > target {
+			if !mig.hasDown() {
+				return nil, fmt.Errorf("Migrator: migration %d_%s has no down", mig.Version, mig.Name)
+			}
+			steps = append(steps, MigrationStep{Migration: mig, Up: false})
+		}
+	}
+	return steps, nil
+}
+
+// Migrate runs the migrations to the target version (see Steps), each in its own transaction with its record
+// in the migrations table. It stops at the first failing migration, which is rolled back.
+// Each transaction first locks the migrations table and then reads the applied migrations, so that
+// concurrent Migrates wait for each other (or fail, if the database does not wait) instead of running
+// the same migration twice.
+// A dry run does not change the database, so it can use a read-only session.
+func (mg *Migrator) Migrate(ctx context.Context, target int64) error {
+	if mg.sess.readOnly && mg.dryRun == nil {
+		return errors.New("Migrate: session is read-only")
+	}
+	if mg.sess.tx != nil {
+		return errors.New("Migrate: transaction already started")
+	}
+
+	steps, err := mg.Steps(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	if mg.dryRun != nil {
+		return mg.writeSteps(steps)
+	}
+
+	if err = mg.createTable(ctx); err != nil {
+		return err
+	}
+
+	for {
+		step, err := mg.runNext(ctx, target)
+		if err != nil {
+			return err
+		}
+		if step == nil {
+			return nil
+		}
+		mg.sess.logger.Println("Migrate:", *step)
+	}
+}
+
+// Runs the next step to the target version, if there is one
+func (mg *Migrator) runNext(ctx context.Context, target int64) (*MigrationStep, error) {
+	tx, err := mg.sess.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	step, err := mg.runNextTx(ctx, tx, target)
+	if err != nil || step == nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			mg.sess.logger.Println(rollbackErr)
+		}
+		return nil, err
+	}
+	return step, tx.Commit()
+}
+
+func (mg *Migrator) runNextTx(ctx context.Context, tx *sql.Tx, target int64) (*MigrationStep, error) {
+	if err := mg.lock(ctx, tx); err != nil {
+		return nil, fmt.Errorf("Migrate: unable to lock %s: %w", mg.table, err)
+	}
+	applied, err := mg.appliedRows(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := mg.steps(applied, target)
+	if err != nil || len(steps) == 0 {
+		return nil, err
+	}
+
+	if err = mg.runTx(ctx, tx, steps[0]); err != nil {
+		return nil, fmt.Errorf("Migrate: %s: %w", steps[0], err)
+	}
+	if err = mg.unlock(ctx, tx); err != nil {
+		return nil, err
+	}
+	return &steps[0], nil
+}
+
+// The lock row is held by the transaction until it ends: a concurrent insert waits for it, or fails
+func (mg *Migrator) lock(ctx context.Context, tx *sql.Tx) error {
+	d := mg.sess.dialect
+	_, err := tx.ExecContext(ctx, "INSERT INTO "+d.QuoteIdentifier(mg.table)+" (version, name, checksum, applied_at) VALUES ("+
+		d.Placeholder(1)+COMMA_SPACE+d.Placeholder(2)+COMMA_SPACE+d.Placeholder(3)+COMMA_SPACE+d.Placeholder(4)+")",
+		migrationLockVersion, "lock", "", time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func (mg *Migrator) unlock(ctx context.Context, tx *sql.Tx) error {
+	d := mg.sess.dialect
+	_, err := tx.ExecContext(ctx, "DELETE FROM "+d.QuoteIdentifier(mg.table)+" WHERE version="+d.Placeholder(1), migrationLockVersion)
+	return err
+}
+
+func (mg *Migrator) runTx(ctx context.Context, tx *sql.Tx, step MigrationStep) error {
+	mig := step.Migration
+	d := mg.sess.dialect
+
+	fn, sqlText := mig.Down, mig.DownSql
+	if step.Up {
+		fn, sqlText = mig.Up, mig.UpSql
+	}
+	if fn != nil {
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+	} else if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+		return err
+	}
+
+	if step.Up {
+		_, err := tx.ExecContext(ctx, "INSERT INTO "+d.QuoteIdentifier(mg.table)+" (version, name, checksum, applied_at) VALUES ("+
+			d.Placeholder(1)+COMMA_SPACE+d.Placeholder(2)+COMMA_SPACE+d.Placeholder(3)+COMMA_SPACE+d.Placeholder(4)+")",
+			mig.Version, mig.Name, mig.Checksum(), time.Now().UTC().Format(time.RFC3339))
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "DELETE FROM "+d.QuoteIdentifier(mg.table)+" WHERE version="+d.Placeholder(1), mig.Version)
+	return err
+}
+
+func (mg *Migrator) writeSteps(steps []MigrationStep) error {
+	for i := 0; i < len(steps); i++ {
+		mig := steps[i].Migration
+		fn, sqlText := mig.Down, mig.DownSql
+		if steps[i].Up {
+			fn, sqlText = mig.Up, mig.UpSql
+		}
+		if fn != nil {
+			sqlText = "-- Go func"
+		}
+		if _, err := fmt.Fprintf(mg.dryRun, "-- %s\n%s\n", steps[i], strings.TrimSpace(sqlText)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mg *Migrator) createTable(ctx context.Context) error {
+	_, err := mg.sess.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+mg.sess.dialect.QuoteIdentifier(mg.table)+
+		" (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, checksum VARCHAR(64) NOT NULL, applied_at VARCHAR(32) NOT NULL)")
+	return err
+}
+
+func (mg *Migrator) tableExists() (bool, error) {
+	names, err := mg.sess.dialect.TableNames(mg.sess.db)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < len(names); i++ {
+		if names[i] == mg.table {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type appliedMigration struct {
+	name     string
+	checksum string
+}
+
+// None if the migrations table does not exist yet
+func (mg *Migrator) applied(ctx context.Context) (map[int64]appliedMigration, error) {
+	exists, err := mg.tableExists()
+	if err != nil || !exists {
+		return make(map[int64]appliedMigration), err
+	}
+	return mg.appliedRows(ctx, mg.sess.db)
+}
+
+func (mg *Migrator) appliedRows(ctx context.Context, q dbExecutor) (map[int64]appliedMigration, error) {
+	applied := make(map[int64]appliedMigration)
+	rows, err := q.QueryContext(ctx, "SELECT version, name, checksum FROM "+mg.sess.dialect.QuoteIdentifier(mg.table)+
+		" WHERE version <> "+strconv.FormatInt(migrationLockVersion, 10))
+	defer closeRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var version int64
+		var am appliedMigration
+		if err := rows.Scan(&version, &am.name, &am.checksum); err != nil {
+			return nil, err
+		}
+		applied[version] = am
+	}
+	return applied, rows.Err()
+}
+
+// Every applied migration must be known and unchanged
+func (mg *Migrator) verify(applied map[int64]appliedMigration) error {
+	for version, am := range applied {
+		mig := mg.migration(version)
+		if mig == nil {
+			return fmt.Errorf("Migrator: applied migration %d_%s is unknown", version, am.name)
+		}
+		if mig.Checksum() != am.checksum {
+			return fmt.Errorf("Migrator: applied migration %d_%s has changed: checksum mismatch", version, am.name)
+		}
+	}
+	return nil
+}
+
+func (mg *Migrator) migration(version int64) *Migration {
+	i := sort.Search(len(mg.migrations), func(i int) bool {
+		return mg.migrations[i].Version >= version
+	})
+	if i < len(mg.migrations) && mg.migrations[i].Version == version {
+		return mg.migrations[i]
+	}
+	return nil
+}
+
+func lastVersion(applied map[int64]appliedMigration) int64 {
+	var last int64
+	for version := range applied {
+		if version > last {
+			last = version
+		}
+	}
+	return last
+}
@@ -0,0 +1,391 @@
+package dalkeeth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func migratorTestSession() (*Session, error) {
+	mdl0, err := testModel0()
+	if err != nil {
+		return nil, err
+	}
+	db, err := openTestDB()
+	if err != nil {
+		return nil, err
+	}
+	return OpenDB(mdl0, db, new(DialectSqlite3))
+}
+
+func migratorTestMigrations() []*Migration {
+	return []*Migration{
+		{
+			Version: 2,
+			Name:    "add_age",
+			UpSql:   "ALTER TABLE things ADD COLUMN age INT",
+			DownSql: "ALTER TABLE things DROP COLUMN age",
+		},
+		{
+			Version: 1,
+			Name:    "create_things",
+			UpSql:   "CREATE TABLE things (id INT PRIMARY KEY, name TEXT)",
+			DownSql: "DROP TABLE things",
+		},
+		{
+			Version: 3,
+			Name:    "insert_thing",
+			Up: func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO things (id, name, age) VALUES (1, 'one', 10)")
+				return err
+			},
+			Down: func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "DELETE FROM things WHERE id=1")
+				return err
+			},
+		},
+	}
+}
+
+func migratorTestColumns(sess *Session) (string, error) {
+	rows, err := sess.db.Query("SELECT name FROM pragma_table_info('things') ORDER BY cid")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return "", err
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ","), rows.Err()
+}
+
+func TestMigrator_Migrate(t *testing.T) {
+	setupTest()
+	sess, err := migratorTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	ctx := context.Background()
+
+	mg, err := NewMigrator(sess, migratorTestMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		target  int64
+		version int64
+		columns string
+	}{
+		{2, 2, "id,name,age"},
+		{LatestVersion, 3, "id,name,age"},
+		{1, 1, "id,name"},
+		{0, 0, ""},
+		{LatestVersion, 3, "id,name,age"},
+	} {
+		if err = mg.Migrate(ctx, step.target); err != nil {
+			t.Fatal(err)
+		}
+		version, err := mg.Version(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != step.version {
+			t.Fatal(fmt.Errorf("Target %d: version should be %d; is %d", step.target, step.version, version))
+		}
+		columns, err := migratorTestColumns(sess)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if columns != step.columns {
+			t.Fatal(fmt.Errorf("Target %d: columns should be [%s]; are [%s]", step.target, step.columns, columns))
+		}
+	}
+
+	var n int64
+	if err = sess.db.QueryRow("SELECT count(*) FROM things").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal(fmt.Errorf("Should have 1 record; have %d", n))
+	}
+
+	if err = mg.Migrate(ctx, 7); err == nil {
+		t.Fatal("Unknown target", ShouldHaveFailed)
+	}
+}
+
+func TestMigrator_Verify(t *testing.T) {
+	setupTest()
+	sess, err := migratorTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	ctx := context.Background()
+
+	mg, err := NewMigrator(sess, migratorTestMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mg.Migrate(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Down changed
+	changed := migratorTestMigrations()
+	changed[1].DownSql = "DROP TABLE IF EXISTS things"
+	mg, err = NewMigrator(sess, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mg.Migrate(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	changed = migratorTestMigrations()
+	changed[0].UpSql = "ALTER TABLE things ADD COLUMN age BIGINT"
+	mg, err = NewMigrator(sess, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mg.Migrate(ctx, LatestVersion); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatal("Changed migration", ShouldHaveFailed, err)
+	}
+
+	mg, err = NewMigrator(sess, migratorTestMigrations()[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mg.Migrate(ctx, LatestVersion); err == nil {
+		t.Fatal("Unknown applied migration", ShouldHaveFailed)
+	}
+}
+
+func TestMigrator_FailureRollsBack(t *testing.T) {
+	setupTest()
+	sess, err := migratorTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	ctx := context.Background()
+
+	migrations := append(migratorTestMigrations(), &Migration{
+		Version: 4,
+		Name:    "broken",
+		UpSql:   "INSERT INTO unknown VALUES (1)",
+	})
+	mg, err := NewMigrator(sess, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mg.Migrate(ctx, LatestVersion); err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+	version, err := mg.Version(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 3 {
+		t.Fatal(fmt.Errorf("Should stop at version 3; is %d", version))
+	}
+
+	// No down
+	if err = mg.Migrate(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err = mg.Migrate(ctx, LatestVersion); err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+	migrations[3].UpSql = "SELECT 1"
+	if mg, err = NewMigrator(sess, migrations); err != nil {
+		t.Fatal(err)
+	}
+	if err = mg.Migrate(ctx, LatestVersion); err != nil {
+		t.Fatal(err)
+	}
+	if err = mg.Migrate(ctx, 0); err == nil || !strings.Contains(err.Error(), "no down") {
+		t.Fatal("No down", ShouldHaveFailed, err)
+	}
+}
+
+// The second Migrate waits for the first one, or fails to lock the migrations table, but does not run the migration again
+func TestMigrator_Concurrent(t *testing.T) {
+	setupTest()
+	dsn := filepath.Join(t.TempDir(), "migrate.db")
+	ctx := context.Background()
+
+	var ups int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	migrations := []*Migration{
+		{
+			Version: 1,
+			Name:    "slow",
+			Up: func(ctx context.Context, tx *sql.Tx) error {
+				if atomic.AddInt32(&ups, 1) == 1 {
+					close(started)
+					<-release
+				}
+				_, err := tx.ExecContext(ctx, "CREATE TABLE things (id INT PRIMARY KEY)")
+				return err
+			},
+		},
+	}
+
+	var mgs []*Migrator
+	for i := 0; i < 2; i++ {
+		mdl0, err := testModel0()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := sql.Open(testSqliteDriver, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.SetMaxOpenConns(1)
+		sess, err := OpenDB(mdl0, db, new(DialectSqlite3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sess.Close()
+		mg, err := NewMigrator(sess, migrations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mgs = append(mgs, mg)
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- mgs[0].Migrate(ctx, LatestVersion)
+	}()
+	<-started
+	go func() {
+		errs <- mgs[1].Migrate(ctx, LatestVersion)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	<-errs
+	<-errs
+
+	if n := atomic.LoadInt32(&ups); n != 1 {
+		t.Fatal(fmt.Errorf("Migration should run once; ran %d times", n))
+	}
+	for i := 0; i < len(mgs); i++ {
+		version, err := mgs[i].Version(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != 1 {
+			t.Fatal(fmt.Errorf("Version should be 1; is %d", version))
+		}
+	}
+	if err := mgs[1].Migrate(ctx, LatestVersion); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrator_DryRunFromFS(t *testing.T) {
+	setupTest()
+	sess, err := migratorTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"migrations/0001_create_things.up.sql":   {Data: []byte("CREATE TABLE things (id INT PRIMARY KEY)\n")},
+		"migrations/0001_create_things.down.sql": {Data: []byte("DROP TABLE things\n")},
+		"migrations/0002_add_name.up.sql":        {Data: []byte("ALTER TABLE things ADD COLUMN name TEXT\n")},
+		"migrations/README":                      {Data: []byte("not a migration")},
+	}
+	migrations, err := MigrationsFromFS(fsys, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 {
+		t.Fatal(fmt.Errorf("Should read 2 migrations; read %d", len(migrations)))
+	}
+
+	var buf bytes.Buffer
+	mg, err := NewMigrator(sess, migrations, WithDryRun(&buf), WithMigrationsTable("versions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = mg.Migrate(ctx, LatestVersion); err != nil {
+		t.Fatal(err)
+	}
+	expected := "-- 1_create_things up\nCREATE TABLE things (id INT PRIMARY KEY)\n-- 2_add_name up\nALTER TABLE things ADD COLUMN name TEXT\n"
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+	version, err := mg.Version(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 {
+		t.Fatal("Dry run should not migrate")
+	}
+	names, err := sess.dialect.TableNames(sess.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Fatal("Dry run should not create tables; have", names)
+	}
+
+	// Read-only
+	buf.Reset()
+	sess.readOnly = true
+	if err = mg.Migrate(ctx, LatestVersion); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+	sess.readOnly = false
+
+	fsys["migrations/x.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1")}
+	if _, err = MigrationsFromFS(fsys, "migrations"); err == nil {
+		t.Fatal("Invalid version", ShouldHaveFailed)
+	}
+}
+
+func TestNewMigrator_Invalid(t *testing.T) {
+	setupTest()
+	sess, err := migratorTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	for name, migrations := range map[string][]*Migration{
+		"version 0": {{Version: 0, Name: "a", UpSql: "SELECT 1"}},
+		"duplicate": {{Version: 1, Name: "a", UpSql: "SELECT 1"}, {Version: 1, Name: "b", UpSql: "SELECT 1"}},
+		"no up":     {{Version: 1, Name: "a", DownSql: "SELECT 1"}},
+		"nil":       {nil},
+	} {
+		if _, err = NewMigrator(sess, migrations); err == nil {
+			t.Fatal(name, ShouldHaveFailed)
+		}
+	}
+	if _, err = NewMigrator(sess, nil, WithMigrationsTable("bad name")); err == nil {
+		t.Fatal("Table name", ShouldHaveFailed)
+	}
+}
@@ -1,9 +1,10 @@
 package dalkeeth
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"strings"
 )
 
 type Model struct {
@@ -48,7 +49,6 @@ func (m *Model) Freeze() error {
 		// fields
 		for i := 0; i < len(tbl.fields); i++ {
 			m.fieldTableMap[fieldTableMapKey(tbl.name, tbl.fields[i].name)] = tbl.fields[i]
-			log.Println("Adding table.field", tbl.name+"."+tbl.fields[i].name)
 			// TODO
 			// - verify the field default values are the right type (they are defined as strings)
 		}
@@ -58,6 +58,54 @@ func (m *Model) Freeze() error {
 	return nil
 }
 
+// ExtractModel returns the frozen model of the tables in the database, with the table names as keys
+func ExtractModel(db *sql.DB, d Dialect) (*Model, error) {
+	if d == nil {
+		return nil, errors.New("ExtractModel: dialect is nil")
+	}
+	names, err := d.TableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	m := NewModel()
+	for i := 0; i < len(names); i++ {
+		tbl, err := d.ExtractTable(db, names[i])
+		if err != nil {
+			return nil, err
+		}
+		if err = m.addTable(names[i], tbl); err != nil {
+			return nil, err
+		}
+	}
+
+	// Foreign tables and keys from ExtractTable only have names
+	for i := 0; i < len(m.tables); i++ {
+		tbl := m.tables[i]
+		for j := 0; j < len(tbl.foreignKeys); j++ {
+			fk := tbl.foreignKeys[j]
+			foreignTbl := m.TableByKey(fk.foreignTable.name)
+			if foreignTbl == nil {
+				return nil, fmt.Errorf("ExtractModel: table %s: unknown foreign table %s", tbl.name, fk.foreignTable.name)
+			}
+			for k := 0; k < len(fk.foreignFields); k++ {
+				name := fk.foreignFields[k].name
+				var foreignKey *Field
+				if name != "" {
+					foreignKey = foreignTbl.Field(name)
+				} else if len(fk.foreignFields) == len(foreignTbl.pks) {
+					foreignKey = foreignTbl.pks[k]
+				}
+				if foreignKey == nil {
+					return nil, fmt.Errorf("ExtractModel: table %s: unknown foreign key %s.%s", tbl.name, foreignTbl.name, name)
+				}
+				fk.foreignFields[k] = foreignKey
+			}
+			fk.foreignTable = foreignTbl
+		}
+	}
+	return m, m.Freeze()
+}
+
 func fieldTableMapKey(table, field string) string {
 	return table + "." + field
 }
@@ -110,7 +158,19 @@ func (m *Model) addTable(key string, tbl *Table) error {
 	return nil
 }
 
-func (mdl *Model) AddForeignKey(tbl *Table, field string, foreignTbl *Table, foreignKeyField string) error {
+// AddForeignKey adds a foreign key from field of tbl to foreignKeyField of foreignTbl
+func (mdl *Model) AddForeignKey(tbl *Table, field string, foreignTbl *Table, foreignKeyField string, opts ...ForeignKeyOption) error {
+	if field == "" {
+		return fmt.Errorf("manager.AddForeignKey: Field name is empty")
+	}
+	if foreignKeyField == "" {
+		return fmt.Errorf("manager.AddForeignKey: foreignKeyField is empty")
+	}
+	return mdl.AddCompositeForeignKey(tbl, []string{field}, foreignTbl, []string{foreignKeyField}, opts...)
+}
+
+// AddCompositeForeignKey adds a foreign key from fields of tbl to foreignKeyFields of foreignTbl, matched by position
+func (mdl *Model) AddCompositeForeignKey(tbl *Table, fields []string, foreignTbl *Table, foreignKeyFields []string, opts ...ForeignKeyOption) error {
 	if mdl.frozen {
 		return fmt.Errorf("Model is frozen: change")
 	}
@@ -122,46 +182,239 @@ func (mdl *Model) AddForeignKey(tbl *Table, field string, foreignTbl *Table, for
 		return fmt.Errorf("manager.AddForeignKey: Foreign table is nil")
 	}
 
-	if field == "" {
-		return fmt.Errorf("manager.AddForeignKey: Field name is empty")
+	if len(fields) == 0 {
+		return fmt.Errorf("manager.AddForeignKey: no fields")
 	}
-	if foreignKeyField == "" {
-		return fmt.Errorf("manager.AddForeignKey: foreignKeyField is empty")
+	if len(fields) != len(foreignKeyFields) {
+		return fmt.Errorf("manager.AddForeignKey: %d fields and %d foreign key fields", len(fields), len(foreignKeyFields))
+	}
+
+	fs := make([]*Field, len(fields))
+	fks := make([]*Field, len(fields))
+	for i := 0; i < len(fields); i++ {
+		f, ok := tbl.fieldsMap[fields[i]]
+		if !ok {
+			return fmt.Errorf("manager.AddForeignKey: Field %s does not exist in table %s", fields[i], tbl.name)
+		}
+		fk, ok := foreignTbl.fieldsMap[foreignKeyFields[i]]
+		if !ok {
+			return fmt.Errorf("manager.AddForeignKey: Foreign key field %s does not exist in table %s", foreignKeyFields[i], foreignTbl.name)
+		}
+		for j := 0; j < i; j++ {
+			if fs[j] == f {
+				return fmt.Errorf("manager.AddForeignKey: Field %s is repeated", f.name)
+			}
+		}
+		fs[i] = f
+		fks[i] = fk
 	}
 
-	if tbl.Field(field) == nil {
-		return fmt.Errorf("manager.AddForeignKey: Field %s does not exist in table %s", field, tbl.name)
+	fk, err := newForeignKey(tbl, fs, foreignTbl, fks, opts...)
+	if err != nil {
+		return err
 	}
+	if err = fk.validate(); err != nil {
+		return err
+	}
+	return tbl.addForeignKey(fk)
+}
 
-	if foreignTbl.Field(foreignKeyField) == nil {
-		return fmt.Errorf("manager.AddForeignKey: Foreign key field %s does not exist in table %s", foreignKeyField, foreignTbl.name)
+func (m *Model) VerifyQuery(q *Query) error {
+	if q == nil {
+		return errors.New("VerifyQuery: query is nil")
+	}
+	if !q.initialized {
+		return errors.New("VerifyQuery: query not initialized; use NewQuery")
 	}
 
-	f, ok := tbl.fieldsMap[field]
-	if !ok {
-		return fmt.Errorf("Field %s not found in table %s", field, tbl.name)
+	// fromTables
+	for i := 0; i < len(q.fromTables); i++ {
+		if !m.HasTable(q.fromTables[i]) {
+			return fmt.Errorf("VerifyQuery: from table %s is not in model", tableName(q.fromTables[i]))
+		}
 	}
 
-	fk, ok := foreignTbl.fieldsMap[foreignKeyField]
-	if !ok {
-		return fmt.Errorf("Field %s not found in table %s", foreignKeyField, foreignTbl.name)
-	}
-
-	return tbl.addForeignKey(f, foreignTbl, fk)
-}
-
-func (m *Model) VerifyQuery(*Query) error {
-	// check:
-	// - selectFields
-	// - fromTables
-	// - whereEquals
-	// - where
-	// - joins
-	// - joinsByName
-	// - groupBy
-	// - having
-	// - orderBy
-	// - offset
-	// - limit
-	return NotImplemented
+	// joins, joinsByName
+	for i := 0; i < len(q.joins); i++ {
+		if err := m.verifyJoin(q.joins[i]); err != nil {
+			return err
+		}
+	}
+	for name, j := range q.joinsByName {
+		if name == "" {
+			return errors.New("VerifyQuery: join name is empty string")
+		}
+		if err := m.verifyJoin(j); err != nil {
+			return err
+		}
+	}
+
+	tables := q.tables()
+	if len(tables) == 0 && len(q.fromRaw) == 0 {
+		return errors.New("VerifyQuery: no tables to select from")
+	}
+
+	// selectFields
+	if len(q.selectFields) == 0 && len(q.selectRaw) == 0 {
+		return errors.New("VerifyQuery: no fields selected")
+	}
+	for i := 0; i < len(q.selectFields); i++ {
+		if err := m.verifyQueryField(q, tables, q.selectFields[i], "select"); err != nil {
+			return err
+		}
+	}
+
+	// selectRaw: "table.field" or "field" must be known
+	for i := 0; i < len(q.selectRaw); i++ {
+		if err := m.verifyRawField(q, tables, q.selectRaw[i]); err != nil {
+			return err
+		}
+	}
+
+	// whereEquals
+	if len(q.whereEquals)%2 != 0 {
+		return fmt.Errorf("VerifyQuery: whereEquals needs pairs of fields; has %d fields", len(q.whereEquals))
+	}
+	for i := 0; i < len(q.whereEquals); i++ {
+		f, ok := q.whereEquals[i].(*Field)
+		if !ok {
+			continue
+		}
+		if err := m.verifyQueryField(q, tables, f, "where equals"); err != nil {
+			return err
+		}
+	}
+
+	// where
+	for i := 0; i < len(q.where); i++ {
+		if q.where[i] == nil {
+			return fmt.Errorf("VerifyQuery: where condition %d is nil", i)
+		}
+		if err := q.where[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	// groupBy
+	for i := 0; i < len(q.groupBy); i++ {
+		if err := m.verifyQueryField(q, tables, q.groupBy[i], "group by"); err != nil {
+			return err
+		}
+	}
+
+	// having
+	if q.having != nil {
+		if len(q.groupBy) == 0 {
+			return errors.New("VerifyQuery: having without group by")
+		}
+		if err := q.having.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// orderBy
+	for i := 0; i < len(q.orderBy); i++ {
+		ob := q.orderBy[i]
+		if ob == nil || ob.field == nil {
+			return fmt.Errorf("VerifyQuery: order by %d is nil", i)
+		}
+		if f, ok := ob.field.(*Field); ok {
+			if err := m.verifyQueryField(q, tables, f, "order by"); err != nil {
+				return err
+			}
+		}
+	}
+
+	// offset, limit: -1 is unset
+	if q.offset < -1 {
+		return fmt.Errorf("VerifyQuery: offset < 0: %d", q.offset)
+	}
+	if q.limit < -1 {
+		return fmt.Errorf("VerifyQuery: limit < 0: %d", q.limit)
+	}
+	return nil
+}
+
+func tableName(t *Table) string {
+	if t == nil {
+		return "<nil>"
+	}
+	return t.name
+}
+
+func (m *Model) verifyJoin(j *Join2) error {
+	if j == nil || j.f1 == nil || j.f2 == nil {
+		return errors.New("VerifyQuery: join field is nil")
+	}
+	if m.TableField(tableName(j.f1.table), j.f1.name) != j.f1 {
+		return fmt.Errorf("VerifyQuery: join field %s is not in model", j.f1.name)
+	}
+	if m.TableField(tableName(j.f2.table), j.f2.name) != j.f2 {
+		return fmt.Errorf("VerifyQuery: join field %s is not in model", j.f2.name)
+	}
+	if j.f1.table == j.f2.table {
+		return fmt.Errorf("VerifyQuery: join fields %s and %s are in the same table %s", j.f1.name, j.f2.name, j.f1.table.name)
+	}
+	if j.f1.fieldType != j.f2.fieldType {
+		return fmt.Errorf("VerifyQuery: join fields %s.%s and %s.%s have different types", j.f1.table.name, j.f1.name, j.f2.table.name, j.f2.name)
+	}
+	return nil
+}
+
+// Field must be in the model, and in one of the query tables unless the query has a raw from
+func (m *Model) verifyQueryField(q *Query, tables []*Table, f *Field, clause string) error {
+	if f == nil {
+		return fmt.Errorf("VerifyQuery: %s field is nil", clause)
+	}
+	if m.TableField(tableName(f.table), f.name) != f {
+		return fmt.Errorf("VerifyQuery: %s field %s is not in model", clause, f.name)
+	}
+	if len(q.fromRaw) > 0 {
+		return nil
+	}
+	for i := 0; i < len(tables); i++ {
+		if tables[i] == f.table {
+			return nil
+		}
+	}
+	return fmt.Errorf("VerifyQuery: %s field %s.%s is not in a query table", clause, f.table.name, f.name)
+}
+
+func (m *Model) verifyRawField(q *Query, tables []*Table, raw string) error {
+	if raw == "" {
+		return errors.New("VerifyQuery: select field name is empty string")
+	}
+	// Raw expressions, i.e. "COUNT(*)", are not verified
+	if !isIdentifier(strings.ReplaceAll(raw, ".", "")) {
+		return nil
+	}
+	if tblName, fieldName, ok := strings.Cut(raw, "."); ok {
+		if m.TableField(tblName, fieldName) == nil {
+			return fmt.Errorf("VerifyQuery: unknown field %s", raw)
+		}
+		return nil
+	}
+	// Tables of a raw from are not known
+	if len(q.fromRaw) > 0 {
+		return nil
+	}
+	for i := 0; i < len(tables); i++ {
+		if tables[i].Field(raw) != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("VerifyQuery: unknown field %s", raw)
+}
+
+func isIdentifier(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
 }
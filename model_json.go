@@ -0,0 +1,188 @@
+package dalkeeth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// JSON description of a model, read by ReadModelJSON:
+//
+//	{"tables": [
+//	  {"name": "persons",
+//	   "fields": [
+//	     {"name": "id", "type": "int", "pk": true},
+//	     {"name": "name", "type": "string", "length": 64, "notNull": true, "default": "none"}],
+//	   "indexes": [{"unique": true, "fields": ["name"]}],
+//	   "foreignKeys": [{"field": "address_id", "table": "addresses", "foreignKey": "id", "onDelete": "cascade"}]}]}
+//
+// A composite foreign key has "fields" and "foreignKeys" instead of "field" and "foreignKey".
+// Field types are int, string, bool, float and bytes. The table name is also its key in the model.
+type ModelJSON struct {
+	Tables []TableJSON `json:"tables"`
+}
+
+type TableJSON struct {
+	Name        string           `json:"name"`
+	Fields      []FieldJSON      `json:"fields"`
+	Indexes     []IndexJSON      `json:"indexes,omitempty"`
+	ForeignKeys []ForeignKeyJSON `json:"foreignKeys,omitempty"`
+}
+
+type FieldJSON struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	PK      bool   `json:"pk,omitempty"`
+	NotNull bool   `json:"notNull,omitempty"`
+	Unique  bool   `json:"unique,omitempty"`
+	Length  int    `json:"length,omitempty"`
+	Default any    `json:"default,omitempty"`
+}
+
+type IndexJSON struct {
+	Unique bool     `json:"unique,omitempty"`
+	Fields []string `json:"fields"`
+}
+
+type ForeignKeyJSON struct {
+	Field       string   `json:"field,omitempty"`
+	Fields      []string `json:"fields,omitempty"`
+	Table       string   `json:"table"`
+	ForeignKey  string   `json:"foreignKey,omitempty"`
+	ForeignKeys []string `json:"foreignKeys,omitempty"`
+	OnDelete    string   `json:"onDelete,omitempty"`
+	OnUpdate    string   `json:"onUpdate,omitempty"`
+	Deferrable  bool     `json:"deferrable,omitempty"`
+}
+
+var fieldTypeNames = map[string]FieldType{
+	"int":    IntType,
+	"string": StringType,
+	"bool":   BoolType,
+	"float":  FloatType,
+	"bytes":  ByteArrayType,
+}
+
+// ReadModelJSON returns the frozen model described by the JSON
+func ReadModelJSON(r io.Reader) (*Model, error) {
+	if r == nil {
+		return nil, errors.New("ReadModelJSON: reader is nil")
+	}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var mj ModelJSON
+	if err := dec.Decode(&mj); err != nil {
+		return nil, fmt.Errorf("ReadModelJSON: %w", err)
+	}
+	m, err := mj.Model()
+	if err != nil {
+		return nil, err
+	}
+	return m, m.Freeze()
+}
+
+// Model returns the (not frozen) model described
+func (mj *ModelJSON) Model() (*Model, error) {
+	if len(mj.Tables) == 0 {
+		return nil, errors.New("ModelJSON: no tables")
+	}
+	m := NewModel()
+
+	for i := 0; i < len(mj.Tables); i++ {
+		tj := mj.Tables[i]
+		tbl, err := m.NewTable(tj.Name)
+		if err != nil {
+			return nil, err
+		}
+		for j := 0; j < len(tj.Fields); j++ {
+			f, err := tj.Fields[j].field()
+			if err != nil {
+				return nil, fmt.Errorf("ModelJSON: table %s: %w", tj.Name, err)
+			}
+			if _, err = tbl.AddField(f); err != nil {
+				return nil, fmt.Errorf("ModelJSON: table %s: %w", tj.Name, err)
+			}
+		}
+		for j := 0; j < len(tj.Indexes); j++ {
+			if err = tbl.AddIndex(tj.Indexes[j].Unique, tj.Indexes[j].Fields...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Foreign tables can be declared after the table
+	for i := 0; i < len(mj.Tables); i++ {
+		tj := mj.Tables[i]
+		for j := 0; j < len(tj.ForeignKeys); j++ {
+			fk := tj.ForeignKeys[j]
+			foreignTbl := m.TableByKey(fk.Table)
+			if foreignTbl == nil {
+				return nil, fmt.Errorf("ModelJSON: table %s: unknown foreign table %s", tj.Name, fk.Table)
+			}
+			if err := fk.add(m, m.TableByKey(tj.Name), foreignTbl); err != nil {
+				return nil, fmt.Errorf("ModelJSON: table %s: %w", tj.Name, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (fkj *ForeignKeyJSON) add(m *Model, tbl, foreignTbl *Table) error {
+	var opts []ForeignKeyOption
+	onDelete, err := ParseForeignKeyAction(fkj.OnDelete)
+	if err != nil {
+		return err
+	}
+	onUpdate, err := ParseForeignKeyAction(fkj.OnUpdate)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, OnDelete(onDelete), OnUpdate(onUpdate))
+	if fkj.Deferrable {
+		opts = append(opts, Deferrable())
+	}
+
+	switch {
+	case fkj.Field != "" && len(fkj.Fields) == 0 && len(fkj.ForeignKeys) == 0:
+		return m.AddForeignKey(tbl, fkj.Field, foreignTbl, fkj.ForeignKey, opts...)
+	case fkj.Field == "" && fkj.ForeignKey == "":
+		return m.AddCompositeForeignKey(tbl, fkj.Fields, foreignTbl, fkj.ForeignKeys, opts...)
+	}
+	return errors.New("foreign key needs field and foreignKey, or fields and foreignKeys")
+}
+
+func (fj *FieldJSON) field() (*Field, error) {
+	fieldType, ok := fieldTypeNames[fj.Type]
+	if !ok {
+		return nil, fmt.Errorf("field %s: unknown type [%s]", fj.Name, fj.Type)
+	}
+
+	f := NewField(fj.Name, fieldType)
+	if fj.PK {
+		f.PK()
+	}
+	if fj.NotNull {
+		f.NotNull()
+	}
+	if fj.Unique {
+		f.Unique()
+	}
+	if fj.Length != 0 {
+		f.Length(fj.Length)
+	}
+	if fj.Default != nil {
+		def := fj.Default
+		// JSON numbers are float64
+		if n, ok := def.(float64); ok && fieldType == IntType {
+			if n != math.Trunc(n) {
+				return nil, fmt.Errorf("field %s: default %v is not an int", fj.Name, n)
+			}
+			def = int64(n)
+		}
+		f.Default(def)
+	}
+	return f, f.Err()
+}
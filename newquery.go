@@ -1,12 +1,31 @@
 package dalkeeth
 
+type JoinType int
+
+const (
+	InnerJoin JoinType = iota
+	LeftJoin
+)
+
+func (jt JoinType) String() string {
+	return [...]string{" JOIN ", " LEFT JOIN "}[jt]
+}
+
 type Join2 struct {
-	f1, f2 *Field
+	f1, f2   *Field
+	joinType JoinType
 }
 
 type OrderBy struct {
 	field    AField
-	ordering int // ASC, DESC
+	ordering Ordering // ASC, DESC
+}
+
+func NewOrderBy(field AField, ordering Ordering) *OrderBy {
+	return &OrderBy{
+		field:    field,
+		ordering: ordering,
+	}
 }
 
 type Query struct {
@@ -15,12 +34,12 @@ type Query struct {
 	fromTables   []*Table
 	fromRaw      string
 	whereEquals  []AField
-	where        []*Condition
+	where        []Condition
 	whereRaw     string
 	joins        []*Join2
 	joinsByName  map[string]*Join2
 	groupBy      []*Field // Can this be AField?
-	having       *Condition
+	having       Condition
 	orderBy      []*OrderBy
 	offset       int64
 	limit        int64
@@ -36,8 +55,9 @@ func queryInitialize() *Query {
 		selectFields: make([]*Field, 0),
 		selectRaw:    make([]string, 0),
 		whereEquals:  make([]AField, 0),
-		where:        make([]*Condition, 0),
+		where:        make([]Condition, 0),
 		joins:        make([]*Join2, 0),
+		joinsByName:  make(map[string]*Join2),
 		groupBy:      make([]*Field, 0),
 		orderBy:      make([]*OrderBy, 0),
 		offset:       -1,
@@ -72,9 +92,24 @@ func (q *Query) SelectByName(strs ...string) *Query {
 	return q
 }
 
+// Joins the table of jf2 on jf1 = jf2
 func (q *Query) Join(jf1, jf2 *Field) *Query {
-	q.joins = append(q.joins, &Join2{f1: jf1, f2: jf2})
-	return nil
+	q.joins = append(q.joins, &Join2{f1: jf1, f2: jf2, joinType: InnerJoin})
+	return q
+}
+
+// Left joins the table of jf2 on jf1 = jf2
+func (q *Query) LeftJoin(jf1, jf2 *Field) *Query {
+	q.joins = append(q.joins, &Join2{f1: jf1, f2: jf2, joinType: LeftJoin})
+	return q
+}
+
+// Join that can be referred to by name
+func (q *Query) NamedJoin(name string, jf1, jf2 *Field) *Query {
+	j := &Join2{f1: jf1, f2: jf2, joinType: InnerJoin}
+	q.joins = append(q.joins, j)
+	q.joinsByName[name] = j
+	return q
 }
 
 func (q *Query) WhereRaw(s string) *Query {
@@ -82,7 +117,7 @@ func (q *Query) WhereRaw(s string) *Query {
 	return q
 }
 
-func (q *Query) Where(cs ...*Condition) *Query {
+func (q *Query) Where(cs ...Condition) *Query {
 	for i := 0; i < len(cs); i++ {
 		q.where = append(q.where, cs[i])
 	}
@@ -101,7 +136,7 @@ func (q *Query) GroupBy(fields ...*Field) *Query {
 	return q
 }
 
-func (q *Query) Having(c *Condition) *Query {
+func (q *Query) Having(c Condition) *Query {
 	q.having = c
 	return q
 }
@@ -126,3 +161,81 @@ func (q *Query) Limit(limit int64) *Query {
 func (q *Query) Freeze() (*Query, error) {
 	return nil, NotImplemented
 }
+
+// Tables in the from clause: the from tables, or the first join table,
+// or the tables of the selected fields
+func (q *Query) baseTables() []*Table {
+	var tables []*Table
+
+	if len(q.fromTables) > 0 || len(q.fromRaw) > 0 {
+		tables = appendTable(tables, q.fromTables...)
+	} else if len(q.joins) > 0 {
+		if q.joins[0] != nil && q.joins[0].f1 != nil {
+			tables = appendTable(tables, q.joins[0].f1.table)
+		}
+	} else {
+		for i := 0; i < len(q.selectFields); i++ {
+			if q.selectFields[i] != nil {
+				tables = appendTable(tables, q.selectFields[i].table)
+			}
+		}
+	}
+	return tables
+}
+
+// All the tables in the query: the from tables followed by the joined tables
+func (q *Query) tables() []*Table {
+	tables := q.baseTables()
+	for i := 0; i < len(q.joins); i++ {
+		if q.joins[i] != nil && q.joins[i].f1 != nil && q.joins[i].f2 != nil {
+			tables = appendTable(tables, q.joins[i].f1.table, q.joins[i].f2.table)
+		}
+	}
+	return tables
+}
+
+// Appends tables not already in the slice
+func appendTable(tables []*Table, ts ...*Table) []*Table {
+	for i := 0; i < len(ts); i++ {
+		if ts[i] == nil {
+			continue
+		}
+		found := false
+		for j := 0; j < len(tables); j++ {
+			if tables[j] == ts[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			tables = append(tables, ts[i])
+		}
+	}
+	return tables
+}
+
+// Selected fields in result order; raw fields are StringFields
+func (q *Query) resultFields() []AField {
+	fields := make([]AField, 0, len(q.selectFields)+len(q.selectRaw))
+	for i := 0; i < len(q.selectFields); i++ {
+		fields = append(fields, q.selectFields[i])
+	}
+	for i := 0; i < len(q.selectRaw); i++ {
+		fields = append(fields, StringField{fieldName: q.selectRaw[i]})
+	}
+	return fields
+}
+
+// Table of the results if all selected fields are from one table; otherwise nil
+func (q *Query) resultTable() *Table {
+	if len(q.selectRaw) > 0 || len(q.selectFields) == 0 {
+		return nil
+	}
+	tbl := q.selectFields[0].table
+	for i := 1; i < len(q.selectFields); i++ {
+		if q.selectFields[i].table != tbl {
+			return nil
+		}
+	}
+	return tbl
+}
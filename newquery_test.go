@@ -1,6 +1,7 @@
 package dalkeeth
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -28,7 +29,7 @@ func TestNewQuery_T2(t *testing.T) {
 	// FIXXX
 	//q.rawFields = append(q.selectFields, f0)
 
-	sql, err := dialect.SelectQuerySql2(q)
+	sql, _, err := dialect.SelectQuerySql2(q)
 
 	t.Log(sql)
 	if err != nil {
@@ -44,26 +45,174 @@ func TestNewQuery_RawAll(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Make session
-	sess, err := NewSession(model)
+	// Make session, with the model tables
+	sess, err := writeTestModelSchema(model)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer sess.Close()
 	// Get SQL dialect
 	dialect := new(DialectSqlite3)
-	sess.dialect = dialect
 
-	//
-	err = sess.WriteModelTableSchemaToDB()
+	err = writeTestTableRecords(sess)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	q := NewQuery().SelectByName(FId, FName).FromRaw("persons").WhereRaw("name like 'S*'")
+	q := NewQuery().SelectByName(FId, FName).FromRaw("persons").WhereRaw("name like 'H%'")
 
-	sql, err := dialect.SelectQuerySql2(q)
+	sql, _, err := dialect.SelectQuerySql2(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != "SELECT id,name FROM persons WHERE name like 'H%'" {
+		t.Fatal(sql)
+	}
 
-	t.Log(sql)
-	t.Fatal(err)
+	rows, err := sess.ExecuteQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values[0] != VPersonID1 {
+			t.Fatal(fmt.Errorf("Wrong id: %v", values[0]))
+		}
+		n++
+	}
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+	if n != 1 {
+		t.Fatal(fmt.Errorf("Should have 1 row; have %d", n))
+	}
+}
+
+func TestNewQuery_FullSql(t *testing.T) {
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := model.TableByKey(TPerson)
+	personAddress := model.TableByKey(JTPersonName)
+	addresses := model.TableByKey(TAddress)
+
+	q := NewQuery().
+		Select(persons.Field(FName), addresses.Field(FCity)).
+		SelectByName("COUNT(*)").
+		From(persons).
+		Join(persons.Field(FId), personAddress.Field(FPersonId)).
+		LeftJoin(personAddress.Field(FAddressId), addresses.Field(FId)).
+		Where(W(persons.Field(FAge), GT, 20)).
+		WhereRaw("persons.weight > 1").
+		GroupBy(persons.Field(FName), addresses.Field(FCity)).
+		Having(W("COUNT(*)", GT, 1)).
+		OrderBy(NewOrderBy(persons.Field(FName), DESC)).
+		Limit(10).
+		Offset(5)
 
+	err = model.VerifyQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql, args, err := new(DialectSqlite3).SelectQuerySql2(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 2 || args[0] != int64(20) || args[1] != int64(1) {
+		t.Fatal(args)
+	}
+
+	expected := `SELECT "persons"."name","addresses"."city",COUNT(*) FROM "persons"` +
+		` JOIN "person_address" ON "persons"."id" = "person_address"."person_id"` +
+		` LEFT JOIN "addresses" ON "person_address"."address_id" = "addresses"."id"` +
+		` WHERE ("persons"."age" > ?) AND (persons.weight > 1)` +
+		` GROUP BY "persons"."name", "addresses"."city" HAVING COUNT(*) > ?` +
+		` ORDER BY "persons"."name" DESC LIMIT 10 OFFSET 5`
+	if sql != expected {
+		t.Log(expected)
+		t.Fatal(sql)
+	}
+}
+
+func TestNewQuery_Verify(t *testing.T) {
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := model.TableByKey(TPerson)
+	addresses := model.TableByKey(TAddress)
+
+	notInModel := &Field{name: "foo", fieldType: IntType}
+
+	tests := map[string]*Query{
+		"no fields":            NewQuery().From(persons),
+		"unknown raw field":    NewQuery().SelectByName("foo").From(persons),
+		"field not in model":   NewQuery().Select(notInModel).From(persons),
+		"field not in tables":  NewQuery().Select(addresses.Field(FCity)).From(persons),
+		"join different types": NewQuery().Select(persons.Field(FId)).Join(persons.Field(FId), addresses.Field(FCity)),
+		"having no group by":   NewQuery().Select(persons.Field(FId)).Having(W(persons.Field(FAge), GT, 1)),
+		"odd where equals":     &Query{initialized: true, selectFields: []*Field{persons.Field(FId)}, whereEquals: []AField{persons.Field(FId)}},
+		"negative limit":       NewQuery().Select(persons.Field(FId)).Limit(-5),
+		"not initialized":      &Query{},
+	}
+
+	for name, q := range tests {
+		if err := model.VerifyQuery(q); err == nil {
+			t.Error(name, ShouldHaveFailed)
+		}
+	}
+}
+
+func TestNewQuery_Execute(t *testing.T) {
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	err = writeTestTableRecords(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := sess.TableByKey(TPerson)
+
+	q := NewQuery().Select(persons.Field(FId), persons.Field(FName)).
+		Where(W(persons.Field(FAge), LT, 30)).
+		OrderBy(NewOrderBy(persons.Field(FId), ASC))
+
+	rows, err := sess.ExecuteQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatal(fmt.Errorf("No rows: %v", rows.Err()))
+	}
+	rec, err := rows.Record()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err = rec.GetString(FName, &name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Harry" {
+		t.Fatal(fmt.Errorf("Name should be Harry; is %s", name))
+	}
+	if rows.Next() {
+		t.Fatal("Should only be 1 row")
+	}
 }
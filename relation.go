@@ -0,0 +1,412 @@
+package dalkeeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type RelationKind int
+
+const (
+	BelongsTo  RelationKind = iota // The record references one target record
+	HasMany                        // Target records reference the record
+	ManyToMany                     // Through records reference the record and a target record
+)
+
+func (k RelationKind) String() string {
+	return [...]string{"BelongsTo", "HasMany", "ManyToMany"}[k]
+}
+
+// Relation is a named navigation, over foreign keys, from the records of a table to the related records of the
+// target table. Relations are added to the model with AddBelongsTo, AddHasMany and AddManyToMany, and loaded with
+// Session.Load and Session.Preload.
+type Relation struct {
+	name     string
+	kind     RelationKind
+	table    *Table
+	target   *Table
+	through  *Table
+	fk       *ForeignKey // BelongsTo: table -> target; HasMany: target -> table; ManyToMany: through -> table
+	targetFk *ForeignKey // ManyToMany: through -> target
+}
+
+func (r *Relation) Name() string {
+	return r.name
+}
+
+func (r *Relation) Kind() RelationKind {
+	return r.kind
+}
+
+func (r *Relation) Table() *Table {
+	return r.table
+}
+
+func (r *Relation) Target() *Table {
+	return r.target
+}
+
+// The join table of a ManyToMany relation; otherwise nil
+func (r *Relation) Through() *Table {
+	return r.through
+}
+
+// Fields of the table whose values select the related records
+func (r *Relation) keyFields() []*Field {
+	if r.kind == BelongsTo {
+		return r.fk.fields
+	}
+	return r.fk.foreignFields
+}
+
+// Fields matched with the values of the key fields: of the target, or of the through table
+func (r *Relation) matchFields() []*Field {
+	if r.kind == BelongsTo {
+		return r.fk.foreignFields
+	}
+	return r.fk.fields
+}
+
+// Relation of the table with the name; nil if there is none
+func (t *Table) Relation(name string) *Relation {
+	for i := 0; i < len(t.relations); i++ {
+		if t.relations[i].name == name {
+			return t.relations[i]
+		}
+	}
+	return nil
+}
+
+// AddBelongsTo adds a relation from tbl to the target record its foreign key references. fields selects the foreign
+// key when tbl has more than one to target.
+func (m *Model) AddBelongsTo(tbl *Table, name string, target *Table, fields ...string) error {
+	if err := m.checkRelation(tbl, name, target); err != nil {
+		return err
+	}
+	fk, err := relationForeignKey(tbl, target, fields)
+	if err != nil {
+		return err
+	}
+	tbl.relations = append(tbl.relations, &Relation{name: name, kind: BelongsTo, table: tbl, target: target, fk: fk})
+	return nil
+}
+
+// AddHasMany adds a relation from tbl to the target records referencing it. fields selects the foreign key of
+// target when it has more than one to tbl.
+func (m *Model) AddHasMany(tbl *Table, name string, target *Table, fields ...string) error {
+	if err := m.checkRelation(tbl, name, target); err != nil {
+		return err
+	}
+	fk, err := relationForeignKey(target, tbl, fields)
+	if err != nil {
+		return err
+	}
+	tbl.relations = append(tbl.relations, &Relation{name: name, kind: HasMany, table: tbl, target: target, fk: fk})
+	return nil
+}
+
+// AddManyToMany adds a relation from tbl to the target records referenced by the records of the through table
+// which reference tbl. fields selects the foreign key of through to tbl when it has more than one; the foreign key
+// to target is then the other one, so that a through table with two foreign keys to tbl relates tbl to itself.
+func (m *Model) AddManyToMany(tbl *Table, name string, target *Table, through *Table, fields ...string) error {
+	if err := m.checkRelation(tbl, name, target); err != nil {
+		return err
+	}
+	if !m.HasTable(through) {
+		return errors.New("Model.AddManyToMany: through table is not in the model")
+	}
+	fk, err := relationForeignKey(through, tbl, fields)
+	if err != nil {
+		return err
+	}
+	targetFk, err := relationForeignKey(through, target, nil, fk)
+	if err != nil {
+		return err
+	}
+	tbl.relations = append(tbl.relations, &Relation{name: name, kind: ManyToMany, table: tbl, target: target, through: through,
+		fk: fk, targetFk: targetFk})
+	return nil
+}
+
+func (m *Model) checkRelation(tbl *Table, name string, target *Table) error {
+	if m.frozen {
+		return fmt.Errorf("Model is frozen: change")
+	}
+	if !m.HasTable(tbl) {
+		return errors.New("Model.AddRelation: table is not in the model")
+	}
+	if !m.HasTable(target) {
+		return errors.New("Model.AddRelation: target table is not in the model")
+	}
+	if name == "" {
+		return errors.New("Model.AddRelation: name is empty")
+	}
+	if tbl.Relation(name) != nil {
+		return fmt.Errorf("Model.AddRelation: table %s already has relation %s", tbl.name, name)
+	}
+	return nil
+}
+
+// The foreign key of from to to, with the fields if there are any, other than the excluded ones
+func relationForeignKey(from, to *Table, fields []string, excluded ...*ForeignKey) (*ForeignKey, error) {
+	var found *ForeignKey
+	for i := 0; i < len(from.foreignKeys); i++ {
+		fk := from.foreignKeys[i]
+		if fk.foreignTable != to || (len(fields) > 0 && !sameNames(fieldNames(fk.fields), fields)) || isForeignKeyOf(fk, excluded) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("Table %s has more than one foreign key to table %s", from.name, to.name)
+		}
+		found = fk
+	}
+	if found == nil {
+		return nil, fmt.Errorf("Table %s has no foreign key %v to table %s", from.name, fields, to.name)
+	}
+	return found, nil
+}
+
+func isForeignKeyOf(fk *ForeignKey, fks []*ForeignKey) bool {
+	for i := 0; i < len(fks); i++ {
+		if fks[i] == fk {
+			return true
+		}
+	}
+	return false
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Related returns the records of the relation loaded by Session.Load or Session.Preload; nil if there are none or
+// they have not been loaded
+func (rec *InRecord) Related(relation string) []*InRecord {
+	return rec.related[relation]
+}
+
+// Loaded tells if the records of the relation have been loaded
+func (rec *InRecord) Loaded(relation string) bool {
+	_, ok := rec.related[relation]
+	return ok
+}
+
+func (rec *InRecord) setRelated(relation string, recs []*InRecord) {
+	if rec.related == nil {
+		rec.related = make(map[string][]*InRecord)
+	}
+	rec.related[relation] = recs
+}
+
+// Load returns the records related to rec by the relation of its table, ordered by primary key, and keeps them in
+// rec (see InRecord.Related)
+func (sess *Session) Load(rec *InRecord, relation string) ([]*InRecord, error) {
+	return sess.LoadContext(context.Background(), rec, relation)
+}
+
+func (sess *Session) LoadContext(ctx context.Context, rec *InRecord, relation string) ([]*InRecord, error) {
+	if err := sess.PreloadContext(ctx, []*InRecord{rec}, relation); err != nil {
+		return nil, err
+	}
+	return rec.Related(relation), nil
+}
+
+// Preload loads the records related to each of the records, which must be of the same table, by the relation,
+// with one query for every MaxInLength distinct keys. All the related records are loaded: the session select
+// limits do not apply. See InRecord.Related.
+func (sess *Session) Preload(recs []*InRecord, relation string) error {
+	return sess.PreloadContext(context.Background(), recs, relation)
+}
+
+func (sess *Session) PreloadContext(ctx context.Context, recs []*InRecord, relation string) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	if recs[0] == nil {
+		return errors.New("session.Preload: record is nil")
+	}
+	tbl := recs[0].table
+	r := tbl.Relation(relation)
+	if r == nil {
+		return fmt.Errorf("session.Preload: table %s has no relation %s", tbl.name, relation)
+	}
+
+	// Distinct keys; records with a NULL key have no related records
+	keys := make(map[string][]any)
+	var order []string
+	recKeys := make([]string, len(recs))
+	for i := 0; i < len(recs); i++ {
+		if recs[i] == nil || recs[i].table != tbl {
+			return fmt.Errorf("session.Preload: record %d is not a record of table %s", i, tbl.name)
+		}
+		values, err := recordKey(recs[i], r.keyFields())
+		if err != nil {
+			return err
+		}
+		if values == nil {
+			continue
+		}
+		k := fmt.Sprintf("%#v", values)
+		if _, ok := keys[k]; !ok {
+			keys[k] = values
+			order = append(order, k)
+		}
+		recKeys[i] = k
+	}
+
+	related := make(map[string][]*InRecord)
+	for start := 0; start < len(order); start += MaxInLength {
+		end := start + MaxInLength
+		if end > len(order) {
+			end = len(order)
+		}
+		chunk := make([][]any, end-start)
+		for i := start; i < end; i++ {
+			chunk[i-start] = keys[order[i]]
+		}
+		if err := sess.loadRelated(ctx, r, chunk, related); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < len(recs); i++ {
+		var rs []*InRecord
+		if recKeys[i] != "" {
+			rs = related[recKeys[i]]
+		}
+		recs[i].setRelated(relation, rs)
+	}
+	return nil
+}
+
+// Adds the target records matching the keys to related, by key
+func (sess *Session) loadRelated(ctx context.Context, r *Relation, keys [][]any, related map[string][]*InRecord) error {
+	cond, err := keysCondition(r.matchFields(), keys)
+	if err != nil {
+		return err
+	}
+	q := NewQuery().Select(r.target.fields...).From(r.target).Where(cond)
+	extra := 0
+	if r.kind == ManyToMany {
+		// The through key fields tell which record the target record is related to
+		q.Select(r.matchFields()...)
+		extra = len(r.matchFields())
+		for i := 0; i < len(r.targetFk.fields); i++ {
+			q.Join(r.targetFk.foreignFields[i], r.targetFk.fields[i])
+		}
+	}
+	for i := 0; i < len(r.target.pks); i++ {
+		q.OrderBy(NewOrderBy(r.target.pks[i], ASC))
+	}
+
+	// A select limit would drop the related records of some of the keys
+	rows, err := sess.executeQuery(ctx, q, false)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rec, values, err := rows.recordAndValues(r.target, extra)
+		if err != nil {
+			return err
+		}
+		if r.kind != ManyToMany {
+			if values, err = recordKey(rec, r.matchFields()); err != nil {
+				return err
+			}
+		}
+		k := fmt.Sprintf("%#v", values)
+		related[k] = append(related[k], rec)
+	}
+	return rows.Err()
+}
+
+// Values of the fields of the record; nil if one is NULL
+func recordKey(rec *InRecord, fields []*Field) ([]any, error) {
+	values := make([]any, len(fields))
+	for i := 0; i < len(fields); i++ {
+		v, err := rec.Get(fields[i].name)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, nil
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// fields IN the values for a single field; otherwise OR of the ANDs of fields = key values
+func keysCondition(fields []*Field, keys [][]any) (Condition, error) {
+	if len(fields) == 1 {
+		values := make([]any, len(keys))
+		for i := 0; i < len(keys); i++ {
+			values[i] = keys[i][0]
+		}
+		return fieldCondition(fields[0], In, values)
+	}
+
+	conds := make([]Condition, len(keys))
+	for i := 0; i < len(keys); i++ {
+		eqs := make([]Condition, len(fields))
+		for j := 0; j < len(fields); j++ {
+			var err error
+			if eqs[j], err = fieldCondition(fields[j], EQ, keys[i][j:j+1]); err != nil {
+				return nil, err
+			}
+		}
+		conds[i] = And(eqs[0], eqs[1], eqs[2:]...)
+	}
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return Or(conds[0], conds[1], conds[2:]...), nil
+}
+
+// Condition on the field with values of the field type
+func fieldCondition(f *Field, op Operator, values []any) (Condition, error) {
+	switch f.fieldType {
+	case IntType:
+		vs := make([]int64, len(values))
+		for i := 0; i < len(values); i++ {
+			v, ok := values[i].(int64)
+			if !ok {
+				return nil, fmt.Errorf("Field %s: value %v is not an int64", f.qualifiedName(), values[i])
+			}
+			vs[i] = v
+		}
+		return W(f, op, vs...), nil
+	case StringType:
+		vs := make([]string, len(values))
+		for i := 0; i < len(values); i++ {
+			v, ok := values[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("Field %s: value %v is not a string", f.qualifiedName(), values[i])
+			}
+			vs[i] = v
+		}
+		return W(f, op, vs...), nil
+	case FloatType:
+		vs := make([]float64, len(values))
+		for i := 0; i < len(values); i++ {
+			v, ok := values[i].(float64)
+			if !ok {
+				return nil, fmt.Errorf("Field %s: value %v is not a float64", f.qualifiedName(), values[i])
+			}
+			vs[i] = v
+		}
+		return W(f, op, vs...), nil
+	}
+	return nil, fmt.Errorf("Field %s: relation keys need int, string or float fields", f.qualifiedName())
+}
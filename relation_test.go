@@ -0,0 +1,324 @@
+package dalkeeth
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const relationTestModelJSON = `{"tables": [
+ {"name": "persons",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "name", "type": "string"}]},
+ {"name": "addresses",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "city", "type": "string"}]},
+ {"name": "person_address",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "person_id", "type": "int", "notNull": true},
+             {"name": "address_id", "type": "int", "notNull": true}],
+  "foreignKeys": [{"field": "person_id", "table": "persons", "foreignKey": "id"},
+                  {"field": "address_id", "table": "addresses", "foreignKey": "id"}]}
+]}`
+
+// Fred lives in Paris and Rome, Harry in Rome, Sally nowhere
+func relationTestSession() (*Session, error) {
+	mj, err := readTestModelJSON(relationTestModelJSON)
+	if err != nil {
+		return nil, err
+	}
+	m, err := mj.Model()
+	if err != nil {
+		return nil, err
+	}
+	persons := m.TableByKey("persons")
+	addresses := m.TableByKey("addresses")
+	personAddress := m.TableByKey("person_address")
+	if err = m.AddManyToMany(persons, "addresses", addresses, personAddress); err != nil {
+		return nil, err
+	}
+	if err = m.AddHasMany(persons, "person_addresses", personAddress); err != nil {
+		return nil, err
+	}
+	if err = m.AddBelongsTo(personAddress, "person", persons); err != nil {
+		return nil, err
+	}
+	if err = m.Freeze(); err != nil {
+		return nil, err
+	}
+
+	db, err := openTestDB()
+	if err != nil {
+		return nil, err
+	}
+	sess, err := OpenDB(m, db, new(DialectSqlite3))
+	if err != nil {
+		return nil, err
+	}
+	if err = sess.WriteModelTableSchemaToDB(); err != nil {
+		return nil, err
+	}
+
+	for _, s := range []string{
+		"INSERT INTO persons VALUES (1, 'Fred'), (2, 'Harry'), (3, 'Sally')",
+		"INSERT INTO addresses VALUES (10, 'Paris'), (20, 'Rome')",
+		"INSERT INTO person_address VALUES (100, 1, 20), (101, 1, 10), (102, 2, 20)",
+	} {
+		if _, err = sess.db.Exec(s); err != nil {
+			return nil, err
+		}
+	}
+	return sess, nil
+}
+
+// Model, not frozen, to add relations to
+func readTestModelJSON(s string) (*ModelJSON, error) {
+	mj := new(ModelJSON)
+	return mj, json.Unmarshal([]byte(s), mj)
+}
+
+func recordNames(t *testing.T, recs []*InRecord, field string) string {
+	var names []string
+	for i := 0; i < len(recs); i++ {
+		v, err := recs[i].Get(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, v.(string))
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSession_Preload_ManyToMany(t *testing.T) {
+	sess, err := relationTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey("persons")
+
+	var recs []*InRecord
+	for id := int64(1); id <= 3; id++ {
+		rec, err := sess.Get(persons, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+
+	if err = sess.Preload(recs, "addresses"); err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []string{"Paris,Rome", "Rome", ""} {
+		if !recs[i].Loaded("addresses") {
+			t.Fatal("addresses should be loaded")
+		}
+		if got := recordNames(t, recs[i].Related("addresses"), "city"); got != expected {
+			t.Fatalf("Person %d: expected addresses %s; got %s", i+1, expected, got)
+		}
+	}
+
+	if err = sess.Preload(recs, "unknown"); err == nil {
+		t.Fatal("Unknown relation", ShouldHaveFailed)
+	}
+}
+
+// The select limit of the target table does not apply
+func TestSession_Preload_SelectLimit(t *testing.T) {
+	sess, err := relationTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey("persons")
+	if err = WithSelectLimit(sess.TableByKey("addresses"), 1)(sess); err != nil {
+		t.Fatal(err)
+	}
+
+	var recs []*InRecord
+	for id := int64(1); id <= 2; id++ {
+		rec, err := sess.Get(persons, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	if err = sess.Preload(recs, "addresses"); err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []string{"Paris,Rome", "Rome"} {
+		if got := recordNames(t, recs[i].Related("addresses"), "city"); got != expected {
+			t.Fatalf("Person %d: expected addresses %s; got %s", i+1, expected, got)
+		}
+	}
+}
+
+func TestSession_Preload_SelfManyToMany(t *testing.T) {
+	mj, err := readTestModelJSON(`{"tables": [
+ {"name": "persons",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "name", "type": "string"}]},
+ {"name": "friendships",
+  "fields": [{"name": "person_id", "type": "int", "pk": true},
+             {"name": "friend_id", "type": "int", "pk": true}],
+  "foreignKeys": [{"field": "person_id", "table": "persons", "foreignKey": "id"},
+                  {"field": "friend_id", "table": "persons", "foreignKey": "id"}]}
+]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := mj.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := m.TableByKey("persons")
+	friendships := m.TableByKey("friendships")
+	if err = m.AddManyToMany(persons, "friends", persons, friendships); err == nil {
+		t.Fatal("Two foreign keys to persons", ShouldHaveFailed)
+	}
+	if err = m.AddManyToMany(persons, "friends", persons, friendships, "person_id"); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.AddManyToMany(persons, "friend_of", persons, friendships, "friend_id"); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Freeze(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := openTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := OpenDB(m, db, new(DialectSqlite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	if err = sess.WriteModelTableSchemaToDB(); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"INSERT INTO persons VALUES (1, 'Fred'), (2, 'Harry'), (3, 'Sally')",
+		"INSERT INTO friendships VALUES (1, 2), (1, 3), (3, 2)",
+	} {
+		if _, err = sess.db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	harry, err := sess.Get(persons, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fred, err := sess.Get(persons, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	friends, err := sess.Load(fred, "friends")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recordNames(t, friends, "name"); got != "Harry,Sally" {
+		t.Fatal("Fred should have friends Harry,Sally; got", got)
+	}
+	friendOf, err := sess.Load(harry, "friend_of")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recordNames(t, friendOf, "name"); got != "Fred,Sally" {
+		t.Fatal("Harry should be a friend of Fred,Sally; got", got)
+	}
+}
+
+func TestSession_Load(t *testing.T) {
+	sess, err := relationTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	fred, err := sess.Get(sess.TableByKey("persons"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pas, err := sess.Load(fred, "person_addresses")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pas) != 2 {
+		t.Fatal("Fred should have 2 person_addresses; has", len(pas))
+	}
+
+	// BelongsTo
+	person, err := sess.Load(pas[0], "person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recordNames(t, person, "name"); got != "Fred" {
+		t.Fatal("person_address should belong to Fred; got", got)
+	}
+}
+
+func TestModel_AddRelation_Invalid(t *testing.T) {
+	mj, err := readTestModelJSON(relationTestModelJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := mj.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := m.TableByKey("persons")
+	addresses := m.TableByKey("addresses")
+	personAddress := m.TableByKey("person_address")
+
+	if err = m.AddBelongsTo(persons, "address", addresses); err == nil {
+		t.Fatal("No foreign key", ShouldHaveFailed)
+	}
+	if err = m.AddHasMany(persons, "person_addresses", personAddress, "address_id"); err == nil {
+		t.Fatal("Wrong foreign key fields", ShouldHaveFailed)
+	}
+	if err = m.AddManyToMany(persons, "persons", persons, personAddress); err == nil {
+		t.Fatal("Self many-to-many", ShouldHaveFailed)
+	}
+	if err = m.AddHasMany(persons, "", personAddress); err == nil {
+		t.Fatal("Empty name", ShouldHaveFailed)
+	}
+	if err = m.AddHasMany(persons, "pa", personAddress); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.AddHasMany(persons, "pa", personAddress); err == nil {
+		t.Fatal("Duplicate name", ShouldHaveFailed)
+	}
+}
+
+func TestKeysCondition_Composite(t *testing.T) {
+	m := NewModel()
+	tbl, err := m.NewTable("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tbl.AddFields(NewField("a", IntType), NewField("b", StringType)); err != nil {
+		t.Fatal(err)
+	}
+
+	cond, err := keysCondition(tbl.fields, [][]any{{int64(1), "x"}, {int64(2), "y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cond.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s, args, err := Evaluate(cond, new(DialectSqlite3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `("t"."a" = ? AND "t"."b" = ?) OR ("t"."a" = ? AND "t"."b" = ?)` || len(args) != 4 {
+		t.Fatal(s, args)
+	}
+
+	if _, err = keysCondition(tbl.fields, [][]any{{"1", "x"}}); err == nil {
+		t.Fatal("Wrong key type", ShouldHaveFailed)
+	}
+}
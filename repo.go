@@ -0,0 +1,194 @@
+package dalkeeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var RecordNotFound = errors.New("Record not found")
+
+// Repo maps the records of a table to and from the struct T, using its dalkeeth tags (see TableFromStruct).
+// It uses the transaction of the session if one has been started.
+type Repo[T any] struct {
+	sess  *Session
+	table *Table
+}
+
+// NewRepo returns a Repo for the table; all the tagged fields of T must be fields of the table
+func NewRepo[T any](sess *Session, tbl *Table) (*Repo[T], error) {
+	if sess == nil {
+		return nil, errors.New("NewRepo: session is nil")
+	}
+	if tbl == nil {
+		return nil, errors.New("NewRepo: table is nil")
+	}
+	if !sess.model.HasTable(tbl) {
+		return nil, fmt.Errorf("NewRepo: table %s is not in the session model", tbl.name)
+	}
+
+	var v T
+	if reflect.TypeOf(v) == nil || reflect.TypeOf(v).Kind() != reflect.Struct {
+		return nil, fmt.Errorf("NewRepo: type %T is not a struct", v)
+	}
+	sfs, err := structFields(v)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(sfs); i++ {
+		if tbl.Field(sfs[i].name) == nil {
+			return nil, fmt.Errorf("NewRepo: field %s of %T is not in table %s", sfs[i].name, v, tbl.name)
+		}
+	}
+
+	return &Repo[T]{
+		sess:  sess,
+		table: tbl,
+	}, nil
+}
+
+func NewRepoS[T any](sess *Session, tblName string) (*Repo[T], error) {
+	if sess == nil {
+		return nil, errors.New("NewRepo: session is nil")
+	}
+	tbl := sess.model.TableByKey(tblName)
+	if tbl == nil {
+		return nil, fmt.Errorf("Unknown table name:[%s]", tblName)
+	}
+	return NewRepo[T](sess, tbl)
+}
+
+func (r *Repo[T]) Table() *Table {
+	return r.table
+}
+
+// Get returns RecordNotFound if there is no record with the id
+func (r *Repo[T]) Get(ctx context.Context, id int64) (T, error) {
+	if id < 0 {
+		var v T
+		return v, errors.New("Repo.Get: id < 0")
+	}
+	return r.GetKey(ctx, id)
+}
+
+// GetKey returns RecordNotFound if there is no record with the primary key values, in key order
+func (r *Repo[T]) GetKey(ctx context.Context, key ...any) (T, error) {
+	var v T
+	rec, err := r.sess.GetKeyContext(ctx, r.table, key...)
+	if err != nil {
+		return v, err
+	}
+	if rec == nil {
+		return v, RecordNotFound
+	}
+	err = rec.ToStruct(&v)
+	return v, err
+}
+
+func (r *Repo[T]) Save(ctx context.Context, v T) error {
+	rec, err := r.table.RecordFromStruct(v)
+	if err != nil {
+		return err
+	}
+	return r.sess.SaveContext(ctx, rec)
+}
+
+// Update updates the record with the primary key of v; returns the number of records updated.
+// Nil pointers and invalid sql.Null* values of v are not updated.
+func (r *Repo[T]) Update(ctx context.Context, v T) (int64, error) {
+	rec, err := r.table.RecordFromStruct(v)
+	if err != nil {
+		return 0, err
+	}
+	return r.sess.UpdateContext(ctx, rec)
+}
+
+func (r *Repo[T]) Delete(ctx context.Context, id int64) (int64, error) {
+	return r.sess.DeleteContext(ctx, r.table, id)
+}
+
+// DeleteKey deletes the record with the primary key values, in key order
+func (r *Repo[T]) DeleteKey(ctx context.Context, key ...any) (int64, error) {
+	return r.sess.DeleteKeyContext(ctx, r.table, key...)
+}
+
+// Find returns the records matching the condition, ordered by primary key; all records if cond is nil.
+// The session select limits do not apply.
+func (r *Repo[T]) Find(ctx context.Context, cond Condition) ([]T, error) {
+	var vs []T
+	err := r.Iterate(ctx, cond, func(v T) error {
+		vs = append(vs, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+// Count returns the number of records matching the condition; all records if cond is nil
+func (r *Repo[T]) Count(ctx context.Context, cond Condition) (int64, error) {
+	q := r.sess.NewSelectQuery().Select(NewFunctionField(COUNT)).WithContext(ctx)
+	q.From = []*Table{r.table}
+	q.Where = cond
+
+	rows, err := q.Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var n int64
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("Repo.Count: no rows")
+	}
+	if err = rows.Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, rows.Err()
+}
+
+// Iterate calls fn for each record matching the condition, ordered by primary key, without loading them all;
+// all records if cond is nil. Stops at the first error from fn, which is returned. The session select limits do not apply.
+// The rows keep their connection while fn runs, so fn should not use the session: with a database limited to
+// one connection (see sql.DB.SetMaxOpenConns), a query in fn waits until its context is done.
+func (r *Repo[T]) Iterate(ctx context.Context, cond Condition, fn func(T) error) error {
+	if fn == nil {
+		return errors.New("Repo.Iterate: func is nil")
+	}
+	q := r.sess.NewSelectQuery().WithContext(ctx)
+	for i := 0; i < len(r.table.fields); i++ {
+		q.Select(r.table.fields[i])
+	}
+	q.From = []*Table{r.table}
+	q.Where = cond
+	q.noSelectLimits = true
+	if len(r.table.pks) > 0 {
+		q.GlobalOrdering = ASC
+	}
+
+	rows, err := q.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rec, err := rows.Record()
+		if err != nil {
+			return err
+		}
+		var v T
+		if err = rec.ToStruct(&v); err != nil {
+			return err
+		}
+		if err = fn(v); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,187 @@
+package dalkeeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func repoTestSession() (*Repo[structTestPerson], error) {
+	model, err := structTestModel()
+	if err != nil {
+		return nil, err
+	}
+	sess, err := writeTestModelSchema(model)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := NewRepoS[structTestPerson](sess, "people")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		age := 20 + i
+		p := structTestPerson{
+			ID:   int64(i),
+			Name: fmt.Sprintf("person_%d", i),
+			Age:  &age,
+		}
+		if err = repo.Save(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return repo, nil
+}
+
+func TestRepo(t *testing.T) {
+	setupTest()
+	repo, err := repoTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.sess.Close()
+	ctx := context.Background()
+	age := repo.Table().Field("age")
+
+	p, err := repo.Get(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "person_3" || *p.Age != 23 {
+		t.Fatalf("Wrong record: %+v", p)
+	}
+
+	_, err = repo.Get(ctx, 100)
+	if !errors.Is(err, RecordNotFound) {
+		t.Fatal(fmt.Errorf("Should be RecordNotFound: %v", err))
+	}
+
+	p.Name = "renamed"
+	n, err := repo.Update(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal(fmt.Errorf("Should update 1 record; updated %d", n))
+	}
+	if p, err = repo.Get(ctx, 3); err != nil || p.Name != "renamed" {
+		t.Fatal(fmt.Errorf("Should be renamed: %+v %v", p, err))
+	}
+
+	n, err = repo.Delete(ctx, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal(fmt.Errorf("Should delete 1 record; deleted %d", n))
+	}
+
+	n, err = repo.Count(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 9 {
+		t.Fatal(fmt.Errorf("Should count 9 records; counted %d", n))
+	}
+
+	n, err = repo.Count(ctx, W(age, GE, 25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatal(fmt.Errorf("Should count 4 records; counted %d", n))
+	}
+
+	people, err := repo.Find(ctx, W(age, LT, 23))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(people) != 3 || people[0].ID != 0 || people[2].ID != 2 {
+		t.Fatal(fmt.Errorf("Wrong records: %+v", people))
+	}
+
+	stop := errors.New("stop")
+	var ids []int64
+	err = repo.Iterate(ctx, nil, func(p structTestPerson) error {
+		ids = append(ids, p.ID)
+		if len(ids) == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatal(fmt.Errorf("Should return func error: %v", err))
+	}
+	if fmt.Sprint(ids) != "[0 1 2 3 4]" {
+		t.Fatal(fmt.Errorf("Wrong ids: %v", ids))
+	}
+}
+
+func TestRepo_SelectLimit(t *testing.T) {
+	setupTest()
+	repo, err := repoTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.sess.Close()
+	repo.sess.selectLimits[repo.Table()] = 3
+
+	ps, err := repo.Find(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps) != 10 {
+		t.Fatal(fmt.Errorf("Find should ignore the select limit and return 10 records; returned %d", len(ps)))
+	}
+}
+
+// The test database has one connection, held by the rows of Iterate
+func TestRepo_Iterate_SameSession(t *testing.T) {
+	setupTest()
+	repo, err := repoTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.sess.Close()
+
+	err = repo.Iterate(context.Background(), nil, func(p structTestPerson) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := repo.Get(ctx, p.ID)
+		return err
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(fmt.Errorf("Should be context.DeadlineExceeded: %v", err))
+	}
+}
+
+func TestNewRepo_Invalid(t *testing.T) {
+	setupTest()
+	repo, err := repoTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.sess.Close()
+	sess := repo.sess
+
+	if _, err = NewRepoS[structTestPerson](sess, "unknown"); err == nil {
+		t.Fatal("Unknown table", ShouldHaveFailed)
+	}
+	if _, err = NewRepoS[structTestPerson](sess, "addresses"); err == nil {
+		t.Fatal("Fields not in table", ShouldHaveFailed)
+	}
+	if _, err = NewRepoS[*structTestPerson](sess, "people"); err == nil {
+		t.Fatal("Pointer type", ShouldHaveFailed)
+	}
+	other, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewRepo[structTestAddress](sess, other.TableByKey(TAddress)); err == nil {
+		t.Fatal("Table not in model", ShouldHaveFailed)
+	}
+}
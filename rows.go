@@ -0,0 +1,116 @@
+package dalkeeth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Rows is a streaming iterator over the results of a SelectQuery or Query
+type Rows struct {
+	rows   *sql.Rows
+	fields []AField
+	table  *Table // Table of records returned by Record
+}
+
+func (r *Rows) Next() bool {
+	return r.rows.Next()
+}
+
+func (r *Rows) Err() error {
+	return r.rows.Err()
+}
+
+func (r *Rows) Close() error {
+	return r.rows.Close()
+}
+
+func (r *Rows) Scan(dest ...any) error {
+	return r.rows.Scan(dest...)
+}
+
+// Record of the current row; only possible when all the selected fields are fields of a single table
+func (r *Rows) Record() (*InRecord, error) {
+	rec, _, err := r.recordAndValues(r.table, 0)
+	return rec, err
+}
+
+// Record of the current row from the selected fields of tbl, followed by the values of the last n selected fields
+func (r *Rows) recordAndValues(tbl *Table, n int) (*InRecord, []any, error) {
+	if tbl == nil {
+		return nil, nil, errors.New("Rows.Record: no table for records; use Rows.Values")
+	}
+	if n < 0 || n > len(r.fields) {
+		return nil, nil, fmt.Errorf("Rows.Record: %d values of %d fields", n, len(r.fields))
+	}
+	rec := tbl.NewRecord()
+	for i := 0; i < len(rec.values); i++ {
+		rec.values[i].isWanted = false
+	}
+
+	nRecord := len(r.fields) - n
+	dest := make([]any, len(r.fields))
+	for i := 0; i < nRecord; i++ {
+		f, ok := r.fields[i].(*Field)
+		if !ok || f.table != tbl {
+			return nil, nil, fmt.Errorf("Rows.Record: field %d is not a field of table %s; use Rows.Values", i, tbl.name)
+		}
+		v := rec.valuesMap[f.name]
+		var err error
+		if v.value, err = makeValue(f.fieldType); err != nil {
+			return nil, nil, err
+		}
+		v.isWanted = true
+		dest[i] = v.value
+	}
+	for i := nRecord; i < len(r.fields); i++ {
+		dest[i] = new(any)
+		if f, ok := r.fields[i].(*Field); ok {
+			v, err := makeValue(f.fieldType)
+			if err != nil {
+				return nil, nil, err
+			}
+			dest[i] = v
+		}
+	}
+
+	if err := r.rows.Scan(dest...); err != nil {
+		return nil, nil, err
+	}
+
+	for i := 0; i < len(rec.values); i++ {
+		if rec.values[i].isWanted {
+			actual(rec.values[i])
+		}
+	}
+	values := make([]any, n)
+	for i := 0; i < n; i++ {
+		values[i] = actualValue(dest[nRecord+i])
+	}
+	return rec, values, nil
+}
+
+// Values of the current row, in the order of the selected fields
+func (r *Rows) Values() ([]any, error) {
+	dest := make([]any, len(r.fields))
+	for i := 0; i < len(r.fields); i++ {
+		if f, ok := r.fields[i].(*Field); ok {
+			v, err := makeValue(f.fieldType)
+			if err != nil {
+				return nil, err
+			}
+			dest[i] = v
+		} else {
+			dest[i] = new(any)
+		}
+	}
+
+	if err := r.rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(dest); i++ {
+		dest[i] = actualValue(dest[i])
+	}
+	return dest, nil
+}
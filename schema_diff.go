@@ -0,0 +1,279 @@
+package dalkeeth
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type SchemaChangeKind int
+
+const (
+	Added SchemaChangeKind = iota
+	Removed
+	Changed
+)
+
+func (k SchemaChangeKind) String() string {
+	return [...]string{"added", "removed", "changed"}[k]
+}
+
+type SchemaObject int
+
+const (
+	TableObject SchemaObject = iota
+	FieldObject
+	IndexObject
+	ForeignKeyObject
+)
+
+func (o SchemaObject) String() string {
+	return [...]string{"table", "field", "index", "foreign key"}[o]
+}
+
+// A difference between the model (wanted) and the database (have)
+type SchemaChange struct {
+	Kind   SchemaChangeKind
+	Object SchemaObject
+	Table  string
+	Name   string // Field; fields of an index; field of a foreign key. Empty for tables
+	Detail string
+
+	want, have *Table
+	index      *Index      // Added: wanted; removed: have
+	field      *Field      // Added or changed: wanted; removed: have
+	foreignKey *ForeignKey // Added: wanted; removed: have
+}
+
+func (c *SchemaChange) String() string {
+	s := c.Kind.String() + " " + c.Object.String() + " " + c.Table
+	if c.Name != "" {
+		s += "." + c.Name
+	}
+	if c.Detail != "" {
+		s += ": " + c.Detail
+	}
+	return s
+}
+
+type SchemaDiff struct {
+	Changes []*SchemaChange
+
+	want, have *Model
+}
+
+func (diff *SchemaDiff) Empty() bool {
+	return len(diff.Changes) == 0
+}
+
+// One change per line
+func (diff *SchemaDiff) String() string {
+	var s string
+	for i := 0; i < len(diff.Changes); i++ {
+		s += diff.Changes[i].String() + "\n"
+	}
+	return s
+}
+
+// Changes of the table, in order
+func (diff *SchemaDiff) tableChanges(table string) []*SchemaChange {
+	var changes []*SchemaChange
+	for i := 0; i < len(diff.Changes); i++ {
+		if diff.Changes[i].Table == table {
+			changes = append(changes, diff.Changes[i])
+		}
+	}
+	return changes
+}
+
+// DiffSchema lists the tables, fields, indexes and foreign keys added, removed or changed in want compared to have.
+// Tables are matched by name.
+func DiffSchema(want, have *Model) (*SchemaDiff, error) {
+	if want == nil || have == nil {
+		return nil, errors.New("DiffSchema: model is nil")
+	}
+	diff := &SchemaDiff{
+		want: want,
+		have: have,
+	}
+
+	haveTables := tablesByName(have)
+	wantTables := tablesByName(want)
+
+	for i := 0; i < len(want.tables); i++ {
+		w := want.tables[i]
+		h, ok := haveTables[w.name]
+		if !ok {
+			diff.Changes = append(diff.Changes, &SchemaChange{
+				Kind:   Added,
+				Object: TableObject,
+				Table:  w.name,
+				want:   w,
+			})
+			continue
+		}
+		diffTable(diff, w, h)
+	}
+
+	for i := 0; i < len(have.tables); i++ {
+		h := have.tables[i]
+		if _, ok := wantTables[h.name]; !ok {
+			diff.Changes = append(diff.Changes, &SchemaChange{
+				Kind:   Removed,
+				Object: TableObject,
+				Table:  h.name,
+				have:   h,
+			})
+		}
+	}
+	return diff, nil
+}
+
+// DiffSchema compares the session model with the database
+func (sess *Session) DiffSchema() (*SchemaDiff, error) {
+	if sess.dialect == nil {
+		return nil, errors.New("session.DiffSchema: dialect is nil")
+	}
+	have, err := ExtractModel(sess.db, sess.dialect)
+	if err != nil {
+		return nil, err
+	}
+	return DiffSchema(sess.model, have)
+}
+
+func tablesByName(m *Model) map[string]*Table {
+	tables := make(map[string]*Table, len(m.tables))
+	for i := 0; i < len(m.tables); i++ {
+		tables[m.tables[i].name] = m.tables[i]
+	}
+	return tables
+}
+
+func diffTable(diff *SchemaDiff, w, h *Table) {
+	change := func(kind SchemaChangeKind, object SchemaObject, name, detail string) *SchemaChange {
+		c := &SchemaChange{
+			Kind:   kind,
+			Object: object,
+			Table:  w.name,
+			Name:   name,
+			Detail: detail,
+			want:   w,
+			have:   h,
+		}
+		diff.Changes = append(diff.Changes, c)
+		return c
+	}
+
+	for i := 0; i < len(w.fields); i++ {
+		wf := w.fields[i]
+		hf := h.Field(wf.name)
+		if hf == nil {
+			change(Added, FieldObject, wf.name, fieldDescription(wf)).field = wf
+			continue
+		}
+		if wd, hd := fieldDescription(wf), fieldDescription(hf); wd != hd {
+			change(Changed, FieldObject, wf.name, hd+" -> "+wd).field = wf
+		}
+	}
+	for i := 0; i < len(h.fields); i++ {
+		if w.Field(h.fields[i].name) == nil {
+			change(Removed, FieldObject, h.fields[i].name, fieldDescription(h.fields[i])).field = h.fields[i]
+		}
+	}
+
+	haveIndexes := indexesByKey(h)
+	wantIndexes := indexesByKey(w)
+	for i := 0; i < len(w.indexes); i++ {
+		if _, ok := haveIndexes[indexKey(w.indexes[i])]; !ok {
+			change(Added, IndexObject, indexFields(w.indexes[i]), indexDescription(w.indexes[i])).index = w.indexes[i]
+		}
+	}
+	for i := 0; i < len(h.indexes); i++ {
+		if _, ok := wantIndexes[indexKey(h.indexes[i])]; !ok {
+			change(Removed, IndexObject, indexFields(h.indexes[i]), indexDescription(h.indexes[i])).index = h.indexes[i]
+		}
+	}
+
+	haveFks := foreignKeysByKey(h)
+	wantFks := foreignKeysByKey(w)
+	for i := 0; i < len(w.foreignKeys); i++ {
+		fk := w.foreignKeys[i]
+		if _, ok := haveFks[foreignKeyReference(fk)]; !ok {
+			change(Added, ForeignKeyObject, strings.Join(fieldNames(fk.fields), ","), foreignKeyReference(fk)).foreignKey = fk
+		}
+	}
+	for i := 0; i < len(h.foreignKeys); i++ {
+		fk := h.foreignKeys[i]
+		if _, ok := wantFks[foreignKeyReference(fk)]; !ok {
+			change(Removed, ForeignKeyObject, strings.Join(fieldNames(fk.fields), ","), foreignKeyReference(fk)).foreignKey = fk
+		}
+	}
+}
+
+// Dialect independent description of the field definition, used to compare fields
+func fieldDescription(f *Field) string {
+	s := f.fieldType.String()
+	if f.length != 0 {
+		s += "(" + strconv.Itoa(f.length) + ")"
+	}
+	if f.pk {
+		s += " PK"
+	}
+	if f.notNull {
+		s += " NOT NULL"
+	}
+	if f.unique {
+		s += " UNIQUE"
+	}
+	if f.defaultValue != "" {
+		s += " DEFAULT " + f.defaultValue
+	}
+	return s
+}
+
+func indexFields(idx *Index) string {
+	names := make([]string, len(idx.fields))
+	for i := 0; i < len(idx.fields); i++ {
+		names[i] = idx.fields[i].name
+	}
+	return strings.Join(names, ",")
+}
+
+func indexKey(idx *Index) string {
+	return strconv.FormatBool(idx.unique) + ":" + indexFields(idx)
+}
+
+func indexDescription(idx *Index) string {
+	if idx.unique {
+		return "UNIQUE (" + indexFields(idx) + ")"
+	}
+	return "(" + indexFields(idx) + ")"
+}
+
+func indexesByKey(t *Table) map[string]*Index {
+	indexes := make(map[string]*Index, len(t.indexes))
+	for i := 0; i < len(t.indexes); i++ {
+		indexes[indexKey(t.indexes[i])] = t.indexes[i]
+	}
+	return indexes
+}
+
+// fields -> table(fields) and the actions; deferrable is not compared as it cannot always be extracted
+func foreignKeyReference(fk *ForeignKey) string {
+	s := strings.Join(fieldNames(fk.fields), ",") + " -> " + fk.foreignTable.name + "(" + strings.Join(fieldNames(fk.foreignFields), ",") + ")"
+	if fk.onDelete != NoAction {
+		s += " ON DELETE " + fk.onDelete.String()
+	}
+	if fk.onUpdate != NoAction {
+		s += " ON UPDATE " + fk.onUpdate.String()
+	}
+	return s
+}
+
+func foreignKeysByKey(t *Table) map[string]*ForeignKey {
+	fks := make(map[string]*ForeignKey, len(t.foreignKeys))
+	for i := 0; i < len(t.foreignKeys); i++ {
+		fks[foreignKeyReference(t.foreignKeys[i])] = t.foreignKeys[i]
+	}
+	return fks
+}
@@ -0,0 +1,199 @@
+package dalkeeth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const schemaDiffHaveJSON = `{"tables": [
+ {"name": "persons",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "name", "type": "string", "length": 32},
+             {"name": "nickname", "type": "string"}],
+  "indexes": [{"fields": ["name"]}]},
+ {"name": "addresses",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "street", "type": "string"}],
+  "indexes": [{"fields": ["street"]}]},
+ {"name": "old",
+  "fields": [{"name": "id", "type": "int", "pk": true}]}
+]}`
+
+const schemaDiffWantJSON = `{"tables": [
+ {"name": "persons",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "name", "type": "string", "length": 64, "notNull": true, "default": "none"},
+             {"name": "age", "type": "int", "default": 0}],
+  "indexes": [{"unique": true, "fields": ["name"]}]},
+ {"name": "addresses",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "street", "type": "string"},
+             {"name": "city", "type": "string"}],
+  "indexes": [{"unique": true, "fields": ["street"]}]},
+ {"name": "person_address",
+  "fields": [{"name": "id", "type": "int", "pk": true},
+             {"name": "person_id", "type": "int", "notNull": true},
+             {"name": "address_id", "type": "int", "notNull": true}],
+  "foreignKeys": [{"field": "person_id", "table": "persons", "foreignKey": "id"},
+                  {"field": "address_id", "table": "addresses", "foreignKey": "id"}]}
+]}`
+
+func schemaDiffTestSession() (*Session, *Model, error) {
+	have, err := ReadModelJSON(strings.NewReader(schemaDiffHaveJSON))
+	if err != nil {
+		return nil, nil, err
+	}
+	want, err := ReadModelJSON(strings.NewReader(schemaDiffWantJSON))
+	if err != nil {
+		return nil, nil, err
+	}
+	sess, err := writeTestModelSchema(have)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, s := range []string{
+		"INSERT INTO persons (id, name, nickname) VALUES (1, 'Fred', 'F'), (2, 'Wilma', NULL)",
+		"INSERT INTO addresses (id, street) VALUES (1, 'Main')",
+	} {
+		if _, err = sess.db.Exec(s); err != nil {
+			return nil, nil, err
+		}
+	}
+	// The session model is the wanted model
+	sess.model = want
+	return sess, have, nil
+}
+
+func TestDiffSchema(t *testing.T) {
+	setupTest()
+	sess, have, err := schemaDiffTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	// No changes
+	diff, err := DiffSchema(have, have)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Fatal("Should be empty:", diff)
+	}
+
+	// The extracted schema of the model is the same
+	extracted, err := ExtractModel(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, err = DiffSchema(have, extracted); err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Fatal("Should be empty:", diff)
+	}
+
+	diff, err = sess.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `changed field persons.name: StringType(32) -> StringType(64) NOT NULL DEFAULT none
+added field persons.age: IntType DEFAULT 0
+removed field persons.nickname: StringType
+added index persons.name: UNIQUE (name)
+removed index persons.name: (name)
+added field addresses.city: StringType
+added index addresses.street: UNIQUE (street)
+removed index addresses.street: (street)
+added table person_address
+removed table old
+`
+	if diff.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, diff)
+	}
+}
+
+func TestSession_PlanMigration(t *testing.T) {
+	setupTest()
+	sess, _, err := schemaDiffTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	plan, err := sess.PlanMigration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE TABLE IF NOT EXISTS "person_address" ("id" INT PRIMARY KEY, "person_id" INT NOT NULL, "address_id" INT NOT NULL,` +
+		` FOREIGN KEY("person_id") REFERENCES "persons"("id"), FOREIGN KEY("address_id") REFERENCES "addresses"("id"));` + "\n" +
+		`CREATE TABLE IF NOT EXISTS "dalkeeth_new_persons" ("id" INT PRIMARY KEY, "name" varchar(64) NOT NULL DEFAULT 'none', "age" INT DEFAULT 0);` + "\n" +
+		`INSERT INTO "dalkeeth_new_persons" ("id", "name") SELECT "id", "name" FROM "persons";` + "\n" +
+		`DROP TABLE "persons";` + "\n" +
+		`ALTER TABLE "dalkeeth_new_persons" RENAME TO "persons";` + "\n" +
+		`CREATE UNIQUE INDEX "idx_persons_name" ON "persons"("name");` + "\n" +
+		`DROP INDEX "idx_addresses_street";` + "\n" +
+		`ALTER TABLE "addresses" ADD COLUMN "city" TEXT;` + "\n" +
+		`CREATE UNIQUE INDEX "idx_addresses_street" ON "addresses"("street");` + "\n" +
+		`DROP TABLE "old";` + "\n"
+	if plan.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, plan)
+	}
+
+	if err = sess.ApplyMigration(context.Background(), plan); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := sess.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Fatal("Should be migrated:", diff)
+	}
+
+	persons := sess.TableByKey("persons")
+	rec, err := sess.Get(persons, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec == nil {
+		t.Fatal("Record should be copied")
+	}
+	var name string
+	if err = rec.GetString("name", &name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Wilma" {
+		t.Fatal(fmt.Errorf("Wrong name: %s", name))
+	}
+}
+
+func TestSession_ApplyMigration_Rollback(t *testing.T) {
+	setupTest()
+	sess, _, err := schemaDiffTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	plan := &MigrationPlan{
+		Statements: []string{
+			"ALTER TABLE addresses ADD COLUMN city TEXT",
+			"DROP TABLE unknown",
+		},
+	}
+	if err = sess.ApplyMigration(context.Background(), plan); err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+
+	extracted, err := ExtractModel(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extracted.TableByKey("addresses").Field("city") != nil {
+		t.Fatal("Migration should be rolled back")
+	}
+}
@@ -1,5 +1,11 @@
 package dalkeeth
 
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
 type Ordering int
 
 const (
@@ -12,6 +18,14 @@ func (ord Ordering) String() string {
 	return [...]string{"", "ASC", "DESC"}[ord]
 }
 
+// Ordering in the opposite direction; NoOrdering is treated as ASC
+func (ord Ordering) reverse() Ordering {
+	if ord == DESC {
+		return ASC
+	}
+	return DESC
+}
+
 type SelectQuery struct {
 	Distinct       bool
 	Fields         []AField
@@ -25,10 +39,13 @@ type SelectQuery struct {
 	Limit          int64
 	Offset         int64
 	OrderByFields  []*FieldOrdered
-	GlobalOrdering Ordering
+	GlobalOrdering Ordering // Used for OrderByFields with NoOrdering, and for the primary key if there are no OrderByFields
 	SelectLimit    int64
 	//
-	validated bool
+	validated      bool
+	sess           *Session
+	ctx            context.Context
+	noSelectLimits bool // The session select limits do not apply
 }
 
 type FieldOrdered struct {
@@ -43,52 +60,296 @@ func (q *SelectQuery) Validated() bool {
 }
 
 func (q *SelectQuery) Validate(m *Model) error {
-	if err := zeroLength(q.Fields, "SelectQuery.fields"); err != nil {
-		return err
+	if m == nil {
+		return errors.New("SelectQuery.Validate: model is nil")
 	}
 
-	err := rawFieldsToFields(q, m)
+	err := rawFromTablesToTables(q, m)
 	if err != nil {
 		return err
 	}
 
-	err = rawFromTablesToTables(q, m)
+	err = rawFieldsToFields(q, m)
 	if err != nil {
 		return err
 	}
 
+	if err := zeroLength(q.Fields, "SelectQuery.fields"); err != nil {
+		return err
+	}
+
+	// No tables: use the table of the first field
+	if len(q.From) == 0 {
+		for i := 0; i < len(q.Fields); i++ {
+			if f := fieldOf(q.Fields[i]); f != nil && f.table != nil {
+				q.From = append(q.From, f.table)
+				break
+			}
+		}
+	}
+	if err := zeroLength(q.From, "SelectQuery.From"); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(q.From); i++ {
+		if !m.HasTable(q.From[i]) {
+			return fmt.Errorf("SelectQuery.Validate: table %s is not in model", q.From[i].name)
+		}
+	}
+
+	for i := 0; i < len(q.Fields); i++ {
+		if f := fieldOf(q.Fields[i]); f != nil && !q.fromTable(f.table) {
+			return fmt.Errorf("SelectQuery.Validate: field %s is not in a From table", f.name)
+		}
+	}
+
+	for i := 0; i < len(q.GroupBy); i++ {
+		if !q.fromTable(q.GroupBy[i].table) {
+			return fmt.Errorf("SelectQuery.Validate: group by field %s is not in a From table", q.GroupBy[i].name)
+		}
+	}
+
+	if len(q.Pks) > 0 {
+		if err := validPks(q.Pks); err != nil {
+			return err
+		}
+		if len(q.From) != 1 {
+			return errors.New("SelectQuery.Validate: Pks need exactly one From table")
+		}
+		if q.From[0].pk == nil {
+			return fmt.Errorf("SelectQuery.Validate: table %s has no single field primary key", q.From[0].name)
+		}
+	}
+
+	if q.Limit < 0 {
+		return fmt.Errorf("SelectQuery.Validate: limit < 0: %d", q.Limit)
+	}
+	if q.Offset < 0 {
+		return fmt.Errorf("SelectQuery.Validate: offset < 0: %d", q.Offset)
+	}
+	if q.SelectLimit < 0 {
+		return fmt.Errorf("SelectQuery.Validate: select limit < 0: %d", q.SelectLimit)
+	}
+
 	q.validated = true
 	return nil
 }
 
+func (q *SelectQuery) fromTable(tbl *Table) bool {
+	for i := 0; i < len(q.From); i++ {
+		if q.From[i] == tbl {
+			return true
+		}
+	}
+	return false
+}
+
+// The *Field behind an AField; nil if there is none (i.e. functions)
+func fieldOf(af AField) *Field {
+	switch f := af.(type) {
+	case *Field:
+		return f
+	case *FieldAs:
+		return f.field
+	}
+	return nil
+}
+
+// Smallest limit > 0; 0 if none
+func minLimit(limits ...int64) int64 {
+	var min int64
+	for i := 0; i < len(limits); i++ {
+		if limits[i] > 0 && (min == 0 || limits[i] < min) {
+			min = limits[i]
+		}
+	}
+	return min
+}
+
 // ////////////////////////////////////
 // Run
+
+// Validated copy of the query with the session select limits applied, which can be modified
+func (q *SelectQuery) runnable() (*SelectQuery, error) {
+	if q.sess == nil {
+		return nil, errors.New("SelectQuery: not bound to a session; use Session.NewSelectQuery")
+	}
+	if q.sess.dialect == nil {
+		return nil, errors.New("SelectQuery: session dialect is nil")
+	}
+	if !q.validated {
+		if err := q.Validate(q.sess.model); err != nil {
+			return nil, err
+		}
+	}
+
+	rq := *q
+	limits := []int64{q.Limit, q.SelectLimit}
+	for i := 0; !q.noSelectLimits && i < len(q.From); i++ {
+		limits = append(limits, q.sess.SelectLimit(q.From[i]))
+	}
+	rq.Limit = minLimit(limits...)
+	rq.SelectLimit = 0
+
+	return &rq, nil
+}
+
+func (q *SelectQuery) hasOrdering() bool {
+	return len(q.OrderByFields) > 0 || q.GlobalOrdering != NoOrdering
+}
+
+func (q *SelectQuery) reverseOrdering() {
+	if !q.hasOrdering() {
+		q.GlobalOrdering = DESC
+		return
+	}
+
+	reversed := make([]*FieldOrdered, len(q.OrderByFields))
+	for i := 0; i < len(q.OrderByFields); i++ {
+		fo := *q.OrderByFields[i]
+		if fo.ordering == NoOrdering {
+			fo.ordering = q.GlobalOrdering
+		}
+		fo.ordering = fo.ordering.reverse()
+		reversed[i] = &fo
+	}
+	q.OrderByFields = reversed
+	q.GlobalOrdering = q.GlobalOrdering.reverse()
+}
+
+func (q *SelectQuery) query() (*Rows, error) {
+	s, args, err := q.sess.dialect.SelectQuerySql(q)
+	if err != nil {
+		return nil, err
+	}
+	q.sess.logger.Println(s, args)
+
+	ctx := q.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rows, err := q.sess.query(ctx, s, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{
+		rows:   rows,
+		fields: q.Fields,
+		table:  q.From[0],
+	}, nil
+}
+
+func (q *SelectQuery) single() (*InRecord, error) {
+	q.Limit = 1
+	rows, err := q.query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return rows.Record()
+}
+
+// First record of the query ordering, or by ascending primary key if there is no ordering; nil if there are no records
 func (q *SelectQuery) First() (*InRecord, error) {
-	return nil, nil
+	rq, err := q.runnable()
+	if err != nil {
+		return nil, err
+	}
+	if !rq.hasOrdering() {
+		rq.GlobalOrdering = ASC
+	}
+	return rq.single()
 }
+
+// Last record of the query ordering, or by ascending primary key if there is no ordering; nil if there are no records.
+// The query cannot have a Limit or Offset.
 func (q *SelectQuery) Last() (*InRecord, error) {
-	return nil, nil
+	if q.Limit > 0 || q.Offset > 0 {
+		return nil, fmt.Errorf("SelectQuery.Last: cannot be used with limit %d or offset %d", q.Limit, q.Offset)
+	}
+	rq, err := q.runnable()
+	if err != nil {
+		return nil, err
+	}
+	rq.reverseOrdering()
+	return rq.single()
 }
 
+// Rows must be closed by the caller
 func (q *SelectQuery) Rows() (*Rows, error) {
-	return nil, nil
+	rq, err := q.runnable()
+	if err != nil {
+		return nil, err
+	}
+	return rq.query()
 }
 
 func (q *SelectQuery) Exists() (bool, error) {
-	return false, nil
+	rq, err := q.runnable()
+	if err != nil {
+		return false, err
+	}
+	rq.Limit = 1
+
+	rows, err := rq.query()
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return true, nil
+	}
+	return false, rows.Err()
 }
 
+// Values of the single field of the query
 func (q *SelectQuery) Pluck() ([]any, error) {
-	return nil, nil
-}
+	if len(q.Fields)+len(q.rawFields) != 1 {
+		return nil, fmt.Errorf("SelectQuery.Pluck: need exactly 1 field; have %d", len(q.Fields)+len(q.rawFields))
+	}
+	rows, err := q.Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-type Rows struct { //temporary
+	var values []any
+	for rows.Next() {
+		v, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v[0])
+	}
+	return values, rows.Err()
 }
 
 //////////////////// ex2 NEW
 
-func (q *SelectQuery) Select(fields ...AField) {
+func (q *SelectQuery) Select(fields ...AField) *SelectQuery {
 	for i := 0; i < len(fields); i++ {
-		//q.fields = append(q.fields, fields[i])
+		q.Fields = append(q.Fields, fields[i])
 	}
+	q.validated = false
+	return q
+}
+
+// Fields by name: "table.field" or "field"
+func (q *SelectQuery) SelectByName(names ...string) *SelectQuery {
+	for i := 0; i < len(names); i++ {
+		q.rawFields = append(q.rawFields, names[i])
+	}
+	q.validated = false
+	return q
+}
+
+// The context used by First, Last, Rows, Exists and Pluck
+func (q *SelectQuery) WithContext(ctx context.Context) *SelectQuery {
+	q.ctx = ctx
+	return q
 }
@@ -1,6 +1,7 @@
 package dalkeeth
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -43,3 +44,350 @@ func TestSelectQuery_FullStruct(t *testing.T) {
 	}
 
 }
+
+func TestSelectQuery_Sql(t *testing.T) {
+	setupTest()
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := model.TableByKey(TPerson)
+	ageField := persons.Field(FAge)
+	nameField := persons.Field(FName)
+
+	q := SelectQuery{
+		Distinct:       true,
+		Fields:         []AField{nameField, NewFunctionField(COUNT)},
+		Pks:            []int64{54, 767},
+		Where:          W(ageField, GT, 20),
+		GroupBy:        []*Field{nameField},
+		Having:         W("COUNT(*)", GT, 1),
+		Offset:         1200,
+		Limit:          100,
+		GlobalOrdering: DESC,
+		OrderByFields:  []*FieldOrdered{nameField.Asc(), ageField.Asc()},
+	}
+	q.OrderByFields[1].ordering = NoOrdering
+
+	if err := q.Validate(model); err != nil {
+		t.Fatal(err)
+	}
+
+	sql, args, err := new(DialectSqlite3).SelectQuerySql(&q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `SELECT DISTINCT "persons"."name", COUNT(*) FROM "persons" WHERE ("persons"."id" IN (?, ?)) AND ("persons"."age" > ?)` +
+		` GROUP BY "persons"."name" HAVING COUNT(*) > ? ORDER BY "persons"."name" ASC, "persons"."age" DESC LIMIT 100 OFFSET 1200`
+	if sql != expected {
+		t.Log(expected)
+		t.Fatal(sql)
+	}
+	if len(args) != 4 || args[0] != int64(54) || args[1] != int64(767) || args[2] != int64(20) || args[3] != int64(1) {
+		t.Fatal(args)
+	}
+}
+
+func TestSelectQuery_Sql_OffsetNoLimit(t *testing.T) {
+	setupTest()
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := SelectQuery{
+		Fields:  []AField{model.TableField(TPerson, FId)},
+		Offset:  10,
+		FromRaw: []string{TPerson},
+	}
+	if err := q.Validate(model); err != nil {
+		t.Fatal(err)
+	}
+
+	sql, _, err := new(DialectSqlite3).SelectQuerySql(&q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != `SELECT "persons"."id" FROM "persons" LIMIT -1 OFFSET 10` {
+		t.Fatal(sql)
+	}
+}
+
+func TestSelectQuery_Validate_UnknownRawField(t *testing.T) {
+	setupTest()
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := SelectQuery{
+		FromRaw: []string{TPerson},
+	}
+	q.SelectByName("not_a_field")
+	if err := q.Validate(model); err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
+
+func TestSelectQuery_NoSession(t *testing.T) {
+	setupTest()
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := SelectQuery{
+		Fields: []AField{model.TableField(TPerson, FId)},
+	}
+	_, err = q.First()
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
+
+func TestSelectQuery_Run(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	// First
+	rec, err := sess.NewSelectQuery().SelectByName(TPerson+"."+FId, TPerson+"."+FName).First()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id int64
+	if err = rec.GetInt(FId, &id); err != nil {
+		t.Fatal(err)
+	}
+	if id != 0 {
+		t.Fatal(fmt.Errorf("First id should be 0; is %d", id))
+	}
+
+	// Last
+	rec, err = sess.NewSelectQuery().Select(persons.Field(FId), persons.Field(FName)).Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.GetInt(FId, &id); err != nil {
+		t.Fatal(err)
+	}
+	if id != 9 {
+		t.Fatal(fmt.Errorf("Last id should be 9; is %d", id))
+	}
+	var name string
+	if err = rec.GetString(FName, &name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Fred_9" {
+		t.Fatal(fmt.Errorf("Last name should be Fred_9; is %s", name))
+	}
+
+	// Last with ordering
+	q := sess.NewSelectQuery().Select(persons.Field(FId))
+	q.OrderByFields = []*FieldOrdered{persons.Field(FId).Desc()}
+	rec, err = q.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.GetInt(FId, &id); err != nil {
+		t.Fatal(err)
+	}
+	if id != 0 {
+		t.Fatal(fmt.Errorf("Last id descending should be 0; is %d", id))
+	}
+	q.Offset = 2
+	if _, err = q.Last(); err == nil {
+		t.Fatal("Last with offset", ShouldHaveFailed)
+	}
+	q.Offset = 0
+	q.Limit = 5
+	if _, err = q.Last(); err == nil {
+		t.Fatal("Last with limit", ShouldHaveFailed)
+	}
+
+	// Exists
+	q = sess.NewSelectQuery().Select(persons.Field(FId))
+	q.Where = W(persons.Field(FId), EQ, 5)
+	exists, err := q.Exists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("Record with id=5 should exist")
+	}
+
+	q = sess.NewSelectQuery().Select(persons.Field(FId))
+	q.Pks = []int64{5000}
+	exists, err = q.Exists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("Record with id=5000 should not exist")
+	}
+
+	// Pluck
+	q = sess.NewSelectQuery().Select(persons.Field(FId))
+	q.Pks = []int64{2, 4, 6}
+	q.GlobalOrdering = DESC
+	ids, err := q.Pluck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[0] != int64(6) || ids[2] != int64(2) {
+		t.Fatal(fmt.Errorf("Wrong pluck values: %v", ids))
+	}
+
+	// Rows
+	q = sess.NewSelectQuery().Select(persons.Field(FId))
+	q.Offset = 2
+	q.Limit = 3
+	q.GlobalOrdering = ASC
+	rows, err := q.Rows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	n := int64(2)
+	for rows.Next() {
+		rec, err := rows.Record()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = rec.GetInt(FId, &id); err != nil {
+			t.Fatal(err)
+		}
+		if id != n {
+			t.Fatal(fmt.Errorf("Row id should be %d; is %d", n, id))
+		}
+		n++
+	}
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+	if n != 5 {
+		t.Fatal(fmt.Errorf("Should have read 3 rows; read %d", n-2))
+	}
+}
+
+// id is in both tables
+func TestSelectQuery_TwoTables(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+	addresses := sess.TableByKey(TAddress)
+
+	rec := addresses.NewRecord()
+	if err = rec.SetValue(FId, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.SetValue(FStreet, "Main"); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.SetValue(FCity, "Ottawa"); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	q := sess.NewSelectQuery().Select(persons.Field(FId), addresses.Field(FId))
+	q.From = []*Table{persons, addresses}
+	q.Where = W(persons.Field(FId), EQ, 2)
+	q.OrderByFields = []*FieldOrdered{addresses.Field(FId).Asc()}
+	rows, err := q.Rows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	values, err := rows.Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[0] != int64(2) || values[1] != int64(7) {
+		t.Fatal(fmt.Errorf("Values should be [2 7]; are %v", values))
+	}
+	if rows.Next() {
+		t.Fatal("Should have one row")
+	}
+}
+
+func TestSelectQuery_SessionSelectLimit(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+	sess.selectLimits[persons] = 4
+
+	ids, err := sess.NewSelectQuery().Select(persons.Field(FId)).Pluck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 4 {
+		t.Fatal(fmt.Errorf("Session select limit is 4; got %d", len(ids)))
+	}
+}
+
+func TestSelectQuery_Values(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	q := sess.NewSelectQuery().Select(NewFunctionField(COUNT))
+	q.From = []*Table{persons}
+	rows, err := q.Rows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	if _, err = rows.Record(); err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+	values, err := rows.Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != int64(10) {
+		t.Fatal(fmt.Errorf("Count should be 10; is %v", values[0]))
+	}
+}
+
+func selectQueryTestSession(n int) (*Session, error) {
+	mdl0, err := testModel0()
+	if err != nil {
+		return nil, err
+	}
+	sess, err := writeTestModelSchema(mdl0)
+	if err != nil {
+		return nil, err
+	}
+	records, err := nPersonRecords(sess.TableByKey(TPerson), n)
+	if err != nil {
+		return nil, err
+	}
+	return sess, sess.Batch(records)
+}
@@ -1,16 +1,59 @@
 package dalkeeth
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Converts raw fields into Fields
+// Raw fields are either "table.field" or "field"; a bare field must be in exactly one of the From tables
 func rawFieldsToFields(q *SelectQuery, m *Model) error {
-	return NotImplemented
+	for i := 0; i < len(q.rawFields); i++ {
+		raw := q.rawFields[i]
+		var field *Field
+
+		if tableName, fieldName, ok := strings.Cut(raw, "."); ok {
+			field = m.TableField(tableName, fieldName)
+		} else {
+			for j := 0; j < len(q.From); j++ {
+				f := q.From[j].Field(raw)
+				if f == nil {
+					continue
+				}
+				if field != nil {
+					return fmt.Errorf("Field %s is ambiguous: in tables %s and %s", raw, field.table.name, q.From[j].name)
+				}
+				field = f
+			}
+		}
+		if field == nil {
+			return fmt.Errorf("Unknown field: %s", raw)
+		}
+		q.Fields = append(q.Fields, field)
+	}
+	q.rawFields = nil
+	return nil
 }
 
 // Converts raw tables into Tables
 func rawFromTablesToTables(q *SelectQuery, m *Model) error {
-	return NotImplemented
+	for i := 0; i < len(q.FromRaw); i++ {
+		tbl := m.TableByKey(q.FromRaw[i])
+		if tbl == nil {
+			return fmt.Errorf("Unknown table: %s", q.FromRaw[i])
+		}
+		q.From = append(q.From, tbl)
+	}
+	q.FromRaw = nil
+	return nil
 }
 
-// Make sure Pks are valid (>0)
+// Make sure Pks are valid (>=0)
 func validPks(pks []int64) error {
-	return NotImplemented
+	for i := 0; i < len(pks); i++ {
+		if pks[i] < 0 {
+			return fmt.Errorf("Primary key id is < 0: %d", pks[i])
+		}
+	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package dalkeeth
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -15,18 +16,109 @@ type Session struct {
 	dialect       Dialect
 	fieldTableMap map[string]*Field // "key=tablename.fieldname", value=*Field
 	readOnly      bool
+	logger        *log.Logger
+	savepoints    []string
 }
 
 func NewSession(model *Model) (*Session, error) {
+	if model == nil {
+		return nil, errors.New("Model is nil")
+	}
 	if !model.frozen {
 		return nil, fmt.Errorf("Model needs to be frozen before using it")
 	}
 	m := new(Session)
 	m.model = model
 	m.selectLimits = make(map[*Table]int64)
+	m.logger = log.Default()
 	return m, nil
 }
 
+// Open opens the database with driverName and dataSourceName and returns a
+// Session using it with the dialect. The database is closed by Session.Close.
+// With DialectSqlite3 foreign keys are turned on for every connection (see SqliteConnector).
+func Open(model *Model, driverName, dataSourceName string, dialect Dialect, opts ...SessionOption) (*Session, error) {
+	if driverName == "" {
+		return nil, errors.New("Open: driver name is empty")
+	}
+	var db *sql.DB
+	if _, ok := dialect.(*DialectSqlite3); ok {
+		connector, err := SqliteConnector(driverName, dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+		db = sql.OpenDB(connector)
+	} else {
+		var err error
+		if db, err = sql.Open(driverName, dataSourceName); err != nil {
+			return nil, err
+		}
+	}
+
+	sess, err := OpenDB(model, db, dialect, opts...)
+	if err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			log.Println(closeErr)
+		}
+		return nil, err
+	}
+	return sess, nil
+}
+
+// OpenDB returns a Session using an already opened database with the dialect, which initializes it
+// (see Dialect.InitDB). Session.Close closes db.
+func OpenDB(model *Model, db *sql.DB, dialect Dialect, opts ...SessionOption) (*Session, error) {
+	if db == nil {
+		return nil, errors.New("OpenDB: db is nil")
+	}
+	if dialect == nil {
+		return nil, errors.New("OpenDB: dialect is nil")
+	}
+
+	sess, err := NewSession(model)
+	if err != nil {
+		return nil, err
+	}
+	sess.db = db
+	sess.dialect = dialect
+
+	for i := 0; i < len(opts); i++ {
+		if opts[i] == nil {
+			continue
+		}
+		if err = opts[i](sess); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = db.Ping(); err != nil {
+		return nil, err
+	}
+	if err = dialect.InitDB(db); err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+func (sess *Session) Dialect() Dialect {
+	return sess.dialect
+}
+
+func (sess *Session) DB() *sql.DB {
+	return sess.db
+}
+
+func (sess *Session) ReadOnly() bool {
+	return sess.readOnly
+}
+
+// SelectLimit returns the maximum number of records a select on tbl returns;
+// 0 is no limit
+func (sess *Session) SelectLimit(tbl *Table) int64 {
+	return sess.selectLimits[tbl]
+}
+
 func (sess *Session) Close() error {
 	if sess.db == nil {
 		return fmt.Errorf("Trying to close nil db")
@@ -38,21 +130,18 @@ func (sess *Session) TableByKey(key string) *Table {
 	return sess.model.TableByKey(key)
 }
 
-func (sess *Session) WriteModelTableSchemaToDB() error {
-	if sess.readOnly {
-		return errors.New("Session.WriteModelTableSchemaToDB: session is read-only")
-	}
-	return NotImplemented
-}
-
 func (sess *Session) createTablesSQL() ([]string, error) {
 	if sess.dialect == nil {
 		return nil, fmt.Errorf("Dialect is nil")
 	}
+	tables, err := tablesInDependencyOrder(sess.model.tables)
+	if err != nil {
+		return nil, err
+	}
 	var sql []string
 
-	for i := 0; i < len(sess.model.tables); i++ {
-		s, err := sess.dialect.CreateTableSql(sess.model.tables[i])
+	for i := 0; i < len(tables); i++ {
+		s, err := sess.dialect.CreateTableSql(tables[i])
 		if err != nil {
 			return nil, err
 		}
@@ -81,65 +170,167 @@ func (sess *Session) createTableIndexesSQL() ([]string, error) {
 	return sql, nil
 }
 
-// Creates new tx and commits at end
-func (sess *Session) BatchChannel(chunkSize int) (chan *InRecord, error) {
-	if sess.readOnly {
-		return nil, errors.New("BatchChannel: session is read-only")
-	}
-	return nil, NotImplemented
+// Saves all the records in one transaction. If a transaction has been started the records
+// are saved in it and it is not committed; otherwise a new transaction is created and committed at end.
+// On error the records are rolled back.
+func (sess *Session) Batch(recs []*InRecord) error {
+	return sess.BatchContext(context.Background(), recs)
 }
 
-// Creates new tx and commits at end
-func (sess *Session) Batch(recs []*InRecord) error {
+func (sess *Session) BatchContext(ctx context.Context, recs []*InRecord) error {
 	if sess.readOnly {
 		return errors.New("Batch: session is read-only")
 	}
-	var err error
-	if sess.tx != nil {
-		return errors.New("session.Save: tx is not nil")
+	if len(recs) == 0 {
+		return errors.New("session.Batch: no records")
 	}
-	err = sess.Begin()
-	if err != nil {
-		return err
+	if sess.dialect == nil {
+		return errors.New("session.Batch: dialect is nil")
 	}
 
-	saveSql, err := sess.dialect.SaveSql(recs[0])
+	ownTx := sess.tx == nil
+	if ownTx {
+		if err := sess.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+	} else {
+		// Only roll back the batch, not the rest of the transaction
+		if err := sess.Savepoint(batchSavepoint); err != nil {
+			return err
+		}
+	}
+
+	err := sess.batch(ctx, recs)
 	if err != nil {
-		//FIXXX: roll back
+		sess.logger.Println("session.Batch: error")
+		sess.logger.Println(err)
+		var rollbackErr error
+		if ownTx {
+			rollbackErr = sess.Rollback()
+		} else {
+			rollbackErr = sess.RollbackToSavepoint(batchSavepoint)
+			if rollbackErr == nil {
+				rollbackErr = sess.ReleaseSavepoint(batchSavepoint)
+			}
+		}
+		if rollbackErr != nil {
+			sess.logger.Println(rollbackErr)
+		}
 		return err
 	}
 
-	stmt, err := sess.tx.Prepare(saveSql)
-	if err != nil {
-		//FIXXX: roll back
-		log.Fatal(err)
+	if ownTx {
+		return sess.Commit()
 	}
-	defer stmt.Close() // danger!
+	return sess.ReleaseSavepoint(batchSavepoint)
+}
+
+const batchSavepoint = "dalkeeth_batch"
+
+// Statements are prepared once for each distinct insert SQL, as records may have different fields set
+func (sess *Session) batch(ctx context.Context, recs []*InRecord) error {
+	stmts := make(map[string]*sql.Stmt)
+	defer func() {
+		for _, stmt := range stmts {
+			if err := stmt.Close(); err != nil {
+				sess.logger.Println(err)
+			}
+		}
+	}()
 
 	for i := 0; i < len(recs); i++ {
+		if recs[i] == nil {
+			return fmt.Errorf("session.Batch: record %d is nil", i)
+		}
+		saveSql, err := sess.dialect.SaveSql(recs[i])
+		if err != nil {
+			return err
+		}
+
+		stmt, ok := stmts[saveSql]
+		if !ok {
+			stmt, err = sess.tx.PrepareContext(ctx, saveSql)
+			if err != nil {
+				return err
+			}
+			stmts[saveSql] = stmt
+		}
+
 		rawValues := rawValues(recs[i].values)
-		_, err = stmt.Exec(rawValues...)
+		_, err = stmt.ExecContext(ctx, rawValues...)
 		if err != nil {
-			log.Println("session.Batch: error")
-			log.Println(err)
-			//FIXXX: roll back
 			return err
 		}
 	}
-	err = sess.Commit()
+	return nil
+}
+
+// Updates the set fields of the record by primary key; uses the transaction if one has been started.
+// Returns the number of records updated.
+func (sess *Session) Update(r *InRecord) (int64, error) {
+	return sess.UpdateContext(context.Background(), r)
+}
+
+func (sess *Session) UpdateContext(ctx context.Context, r *InRecord) (int64, error) {
+	if sess.readOnly {
+		return 0, errors.New("Update: session is read-only")
+	}
+	if r == nil {
+		return 0, errors.New("session.Update: record is nil")
+	}
+	if sess.dialect == nil {
+		return 0, errors.New("session.Update: dialect is nil")
+	}
+
+	updateSql, args, err := sess.dialect.UpdateSql(r)
 	if err != nil {
-		//FIXXX: roll back
-		return err
+		return 0, err
 	}
+	sess.logger.Println(updateSql, args)
 
-	return nil
+	result, err := sess.exec(ctx, updateSql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (sess *Session) Update(r *InRecord) error {
+// Updates the values of all the records in the table matching the condition; uses the transaction if one has been started.
+// Returns the number of records updated.
+func (sess *Session) UpdateWhere(tbl *Table, cond Condition, values ...*Value) (int64, error) {
+	return sess.UpdateWhereContext(context.Background(), tbl, cond, values...)
+}
+
+func (sess *Session) UpdateWhereContext(ctx context.Context, tbl *Table, cond Condition, values ...*Value) (int64, error) {
 	if sess.readOnly {
-		return errors.New("Update: session is read-only")
+		return 0, errors.New("UpdateWhere: session is read-only")
 	}
-	return NotImplemented
+	if tbl == nil {
+		return 0, errors.New("session.UpdateWhere: table is nil")
+	}
+	if sess.dialect == nil {
+		return 0, errors.New("session.UpdateWhere: dialect is nil")
+	}
+
+	updateSql, args, err := sess.dialect.UpdateWhereSql(tbl, cond, values)
+	if err != nil {
+		return 0, err
+	}
+	sess.logger.Println(updateSql, args)
+
+	result, err := sess.exec(ctx, updateSql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (sess *Session) UpdateWhereS(tblName string, cond Condition, values ...*Value) (int64, error) {
+	tbl := sess.model.TableByKey(tblName)
+	if tbl == nil {
+		return 0, fmt.Errorf("Unknown table name:[%s]", tblName)
+	}
+	return sess.UpdateWhere(tbl, cond, values...)
 }
 
 func (sess *Session) GetNamed(tableName string, id int64) (*InRecord, error) {
@@ -159,10 +350,28 @@ func (sess *Session) GetS(tblName string, id int64) (*InRecord, error) {
 	return sess.Get(tbl, id)
 }
 
+// Uses the transaction if one has been started
 func (sess *Session) Get(tbl *Table, id int64) (*InRecord, error) {
+	return sess.GetContext(context.Background(), tbl, id)
+}
+
+func (sess *Session) GetContext(ctx context.Context, tbl *Table, id int64) (*InRecord, error) {
 	if id < 0 {
 		return nil, errors.New("session.Get: id < 0: ")
 	}
+	return sess.GetKeyContext(ctx, tbl, id)
+}
+
+// GetKey returns the record with the primary key values, in key order; nil if there is none.
+// Uses the transaction if one has been started.
+func (sess *Session) GetKey(tbl *Table, key ...any) (*InRecord, error) {
+	return sess.GetKeyContext(context.Background(), tbl, key...)
+}
+
+func (sess *Session) GetKeyContext(ctx context.Context, tbl *Table, key ...any) (*InRecord, error) {
+	if tbl == nil {
+		return nil, errors.New("session.Get: table is nil")
+	}
 	if sess.db == nil {
 		return nil, errors.New("session.Get: db is nil")
 	}
@@ -172,19 +381,18 @@ func (sess *Session) Get(tbl *Table, id int64) (*InRecord, error) {
 
 	rec := tbl.NewRecord()
 
-	query, err := sess.dialect.GetSingleRecordSql(rec, id)
+	query, args, err := sess.dialect.GetSingleRecordSql(rec, key)
 	if err != nil {
 		return nil, err
 	}
-	log.Println(query)
+	sess.logger.Println(query, args)
 
 	values, err := rawWantedValues(rec.values)
 	if err != nil {
 		return nil, err
 	}
-	log.Println("-------------------rawWantedValues", values)
 
-	row := sess.db.QueryRow(query)
+	row := sess.executor().QueryRowContext(ctx, query, args...)
 	err = row.Scan(values...)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -197,7 +405,6 @@ func (sess *Session) Get(tbl *Table, id int64) (*InRecord, error) {
 		v := rec.values[i]
 		//if v.isWanted {
 		actual(v)
-		log.Println("Session", i)
 		//}
 	}
 
@@ -218,8 +425,12 @@ func (sess *Session) SaveFields(tbl *Table, values []*Value) error {
 	return NotImplemented
 }
 
-// Using db, not tx
+// Uses the transaction if one has been started
 func (sess *Session) Save(r *InRecord) error {
+	return sess.SaveContext(context.Background(), r)
+}
+
+func (sess *Session) SaveContext(ctx context.Context, r *InRecord) error {
 	if sess.readOnly {
 		return errors.New("Save: session is read-only")
 	}
@@ -240,10 +451,14 @@ func (sess *Session) Save(r *InRecord) error {
 		return err
 	}
 
-	log.Println(saveSql)
+	sess.logger.Println(saveSql)
+
+	if sess.returnsKey() {
+		return sess.execReturningKey(ctx, sess.executor(), saveSql, r)
+	}
 
 	rawValues := rawValues(r.values)
-	_, err = sess.db.Exec(saveSql, rawValues...)
+	_, err = sess.exec(ctx, saveSql, rawValues...)
 
 	if err != nil {
 		return err
@@ -251,7 +466,44 @@ func (sess *Session) Save(r *InRecord) error {
 	return nil
 }
 
+// Upsert saves the record, or updates its set fields if there is a record with its primary key.
+// Uses the transaction if one has been started.
+func (sess *Session) Upsert(r *InRecord) error {
+	return sess.UpsertContext(context.Background(), r)
+}
+
+func (sess *Session) UpsertContext(ctx context.Context, r *InRecord) error {
+	if sess.readOnly {
+		return errors.New("Upsert: session is read-only")
+	}
+	if r == nil {
+		return errors.New("session.Upsert: record is nil")
+	}
+	if r.table == nil {
+		return errors.New("session.Upsert: record table is nil")
+	}
+	if sess.dialect == nil {
+		return errors.New("session.Upsert: dialect is nil")
+	}
+	upsertSql, err := sess.dialect.UpsertSql(r)
+	if err != nil {
+		return err
+	}
+
+	sess.logger.Println(upsertSql)
+
+	if sess.returnsKey() {
+		return sess.execReturningKey(ctx, sess.executor(), upsertSql, r)
+	}
+	_, err = sess.exec(ctx, upsertSql, rawValues(r.values)...)
+	return err
+}
+
 func (sess *Session) SaveTx(r *InRecord) error {
+	return sess.SaveTxContext(context.Background(), r)
+}
+
+func (sess *Session) SaveTxContext(ctx context.Context, r *InRecord) error {
 	if sess.readOnly {
 		return errors.New("SaveTx: session is read-only")
 	}
@@ -266,20 +518,30 @@ func (sess *Session) SaveTx(r *InRecord) error {
 		return err
 	}
 
-	log.Println(saveSql)
+	sess.logger.Println(saveSql)
+
+	if sess.returnsKey() {
+		return sess.execReturningKey(ctx, sess.tx, saveSql, r)
+	}
 
 	rawValues := rawValues(r.values)
-	_, err = sess.tx.Exec(saveSql, rawValues...)
+	_, err = sess.tx.ExecContext(ctx, saveSql, rawValues...)
 
 	if err != nil {
-		log.Println(saveSql)
-		log.Println(err)
+		sess.logger.Println(saveSql)
+		sess.logger.Println(err)
 		return err
 	}
 	return nil
 }
 
 func (sess *Session) Begin() error {
+	return sess.BeginTx(context.Background(), nil)
+}
+
+// BeginTx starts a transaction with the options (isolation level, read-only); opts may be nil.
+// The context is used until the transaction is committed or rolled back.
+func (sess *Session) BeginTx(ctx context.Context, opts *sql.TxOptions) error {
 	if sess.db == nil {
 		return errors.New("DB is nil")
 	}
@@ -287,13 +549,11 @@ func (sess *Session) Begin() error {
 	if sess.tx != nil {
 		return errors.New("Already in transaction")
 	}
-	var err error
-
-	sess.tx, err = sess.db.Begin()
-
+	tx, err := sess.db.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
+	sess.tx = tx
 
 	return nil
 }
@@ -303,77 +563,277 @@ func (sess *Session) Commit() error {
 		return errors.New("No transaction started")
 	}
 
-	defer func() {
-		sess.tx = nil
-	}()
+	// The transaction is finished even if the commit fails: it cannot be rolled back
+	defer sess.endTx()
 
 	err := sess.tx.Commit()
 	if err != nil {
-		log.Println("Commit error")
-		log.Println(err)
-		rollbackErr := sess.tx.Rollback()
-		if rollbackErr != nil {
-			log.Println("Rollback error")
-			log.Println(rollbackErr)
-			return rollbackErr
-		}
+		sess.logger.Println("Commit error")
+		sess.logger.Println(err)
 		return err
 	}
 	return nil
 }
 
 func (sess *Session) Rollback() error {
+	if sess.tx == nil {
+		return errors.New("No transaction started")
+	}
+
+	defer sess.endTx()
+
+	err := sess.tx.Rollback()
+	if err != nil {
+		sess.logger.Println("Rollback error")
+		sess.logger.Println(err)
+		return err
+	}
 	return nil
 }
 
-// Using db, not tx
-func (sess *Session) Delete(tbl *Table, id int64) error {
+func (sess *Session) endTx() {
+	sess.tx = nil
+	sess.savepoints = nil
+}
+
+// Deletes the record by primary key; uses the transaction if one has been started.
+// Returns the number of records deleted.
+func (sess *Session) Delete(tbl *Table, id int64) (int64, error) {
+	return sess.DeleteContext(context.Background(), tbl, id)
+}
+
+func (sess *Session) DeleteContext(ctx context.Context, tbl *Table, id int64) (int64, error) {
+	if id < 0 {
+		return 0, errors.New("session.Delete: id < 0")
+	}
+	return sess.DeleteKeyContext(ctx, tbl, id)
+}
+
+// DeleteKey deletes the record with the primary key values, in key order; returns the number of records deleted.
+// Uses the transaction if one has been started.
+func (sess *Session) DeleteKey(tbl *Table, key ...any) (int64, error) {
+	return sess.DeleteKeyContext(context.Background(), tbl, key...)
+}
+
+func (sess *Session) DeleteKeyContext(ctx context.Context, tbl *Table, key ...any) (int64, error) {
 	if sess.readOnly {
-		return errors.New("Save: session is read-only")
+		return 0, errors.New("Delete: session is read-only")
 	}
 	if tbl == nil {
-		return errors.New("session.Delete: table is nil")
-	}
-	if id < 0 {
-		return errors.New("session.Delete: id < 0")
+		return 0, errors.New("session.Delete: table is nil")
 	}
 	if sess.dialect == nil {
-		return errors.New("session.Save: dialext is nil")
+		return 0, errors.New("session.Delete: dialect is nil")
 	}
 
-	deleteSql, err := sess.dialect.DeleteSql(tbl, id)
+	deleteSql, args, err := sess.dialect.DeleteSql(tbl, key)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	sess.logger.Println(deleteSql, args)
+
+	result, err := sess.exec(ctx, deleteSql, args...)
+	if err != nil {
+		return 0, err
 	}
+	return result.RowsAffected()
+}
 
-	log.Println(deleteSql)
+func (sess *Session) DeleteS(tblName string, id int64) (int64, error) {
+	tbl := sess.model.TableByKey(tblName)
+	if tbl == nil {
+		return 0, fmt.Errorf("Unknown table name:[%s]", tblName)
+	}
+	return sess.Delete(tbl, id)
+}
 
-	_, err = sess.db.Exec(deleteSql, id)
+// Deletes all the records in the table matching the condition; uses the transaction if one has been started.
+// Returns the number of records deleted.
+func (sess *Session) DeleteWhere(tbl *Table, cond Condition) (int64, error) {
+	return sess.DeleteWhereContext(context.Background(), tbl, cond)
+}
 
+func (sess *Session) DeleteWhereContext(ctx context.Context, tbl *Table, cond Condition) (int64, error) {
+	if sess.readOnly {
+		return 0, errors.New("DeleteWhere: session is read-only")
+	}
+	if tbl == nil {
+		return 0, errors.New("session.DeleteWhere: table is nil")
+	}
+	if sess.dialect == nil {
+		return 0, errors.New("session.DeleteWhere: dialect is nil")
+	}
+
+	deleteSql, args, err := sess.dialect.DeleteWhereSql(tbl, cond)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+
+	sess.logger.Println(deleteSql, args)
+
+	result, err := sess.exec(ctx, deleteSql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (sess *Session) DeleteWhereS(tblName string, cond Condition) (int64, error) {
+	tbl := sess.model.TableByKey(tblName)
+	if tbl == nil {
+		return 0, fmt.Errorf("Unknown table name:[%s]", tblName)
+	}
+	return sess.DeleteWhere(tbl, cond)
 }
 
 func (sess *Session) NewSelectQuery() *SelectQuery {
 	q := SelectQuery{
 		Fields: make([]AField, 0),
+		sess:   sess,
 	}
 	return &q
 }
 
+// The transaction if one has been started, otherwise the db
+func (sess *Session) executor() dbExecutor {
+	if sess.tx != nil {
+		return sess.tx
+	}
+	return sess.db
+}
+
+func (sess *Session) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if sess.db == nil {
+		return nil, errors.New("session.exec: db is nil")
+	}
+	return sess.executor().ExecContext(ctx, query, args...)
+}
+
+// The insert returns the primary key (RETURNING)
+func (sess *Session) returnsKey() bool {
+	return sess.dialect.Capabilities().Returning
+}
+
+// Runs the insert and scans the primary key it returns into the record, i.e. a generated key.
+// An upsert that changed nothing returns no key; the record is unchanged.
+func (sess *Session) execReturningKey(ctx context.Context, ex dbExecutor, query string, r *InRecord) error {
+	if sess.db == nil {
+		return errors.New("session.execReturningKey: db is nil")
+	}
+	if len(r.table.pks) == 0 {
+		_, err := ex.ExecContext(ctx, query, rawValues(r.values)...)
+		return err
+	}
+	keys := make([]*Value, len(r.table.pks))
+	dest := make([]any, len(r.table.pks))
+	for i := 0; i < len(r.table.pks); i++ {
+		keys[i] = r.valuesMap[r.table.pks[i].name]
+		var err error
+		if dest[i], err = makeValue(r.table.pks[i].fieldType); err != nil {
+			return err
+		}
+	}
+
+	err := ex.QueryRowContext(ctx, query, rawValues(r.values)...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(keys); i++ {
+		keys[i].value = actualValue(dest[i])
+		keys[i].isSet = true
+	}
+	return nil
+}
+
+func (sess *Session) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if sess.db == nil {
+		return nil, errors.New("session.query: db is nil")
+	}
+	return sess.executor().QueryContext(ctx, query, args...)
+}
+
+// Uses the transaction if one has been started
 func (sess *Session) Exists(t *Table, id int64) (bool, error) {
-	return recordExists(sess.db, t.name, id)
+	return sess.ExistsContext(context.Background(), t, id)
+}
+
+func (sess *Session) ExistsContext(ctx context.Context, t *Table, id int64) (bool, error) {
+	if id < 0 {
+		return false, fmt.Errorf("Primary key id is < 0: %d", id)
+	}
+	return sess.ExistsKeyContext(ctx, t, id)
+}
+
+// ExistsKey tells if there is a record with the primary key values, in key order.
+// Uses the transaction if one has been started.
+func (sess *Session) ExistsKey(t *Table, key ...any) (bool, error) {
+	return sess.ExistsKeyContext(context.Background(), t, key...)
+}
+
+func (sess *Session) ExistsKeyContext(ctx context.Context, t *Table, key ...any) (bool, error) {
+	if sess.db == nil {
+		return false, errors.New("session.Exists: db is nil")
+	}
+	if t == nil {
+		return false, errors.New("session.Exists: table is nil")
+	}
+	if sess.dialect == nil {
+		return false, errors.New("session.Exists: dialect is nil")
+	}
+	return recordExists(ctx, sess.executor(), sess.dialect, t, key...)
+}
+
+// Rows must be closed by the caller. The session select limits of the query tables apply.
+func (sess *Session) ExecuteQuery(q *Query) (*Rows, error) {
+	return sess.ExecuteQueryContext(context.Background(), q)
+}
 
+func (sess *Session) ExecuteQueryContext(ctx context.Context, q *Query) (*Rows, error) {
+	return sess.executeQuery(ctx, q, true)
 }
 
-func (sess *Session) ExecuteQuery(q *Query) error {
+// The query limit is lowered to the select limits of its tables if selectLimits
+func (sess *Session) executeQuery(ctx context.Context, q *Query, selectLimits bool) (*Rows, error) {
+	if sess.dialect == nil {
+		return nil, errors.New("session.ExecuteQuery: dialect is nil")
+	}
 	err := sess.model.VerifyQuery(q)
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	tables := q.tables()
+	rq := *q
+	limits := []int64{}
+	if q.limit >= 0 {
+		limits = append(limits, q.limit)
+	}
+	for i := 0; selectLimits && i < len(tables); i++ {
+		limits = append(limits, sess.SelectLimit(tables[i]))
+	}
+	if limit := minLimit(limits...); limit > 0 {
+		rq.limit = limit
+	}
+
+	s, args, err := sess.dialect.SelectQuerySql2(&rq)
+	if err != nil {
+		return nil, err
+	}
+	sess.logger.Println(s, args)
+
+	rows, err := sess.query(ctx, s, args...)
+	if err != nil {
+		return nil, err
 	}
-	return NotImplemented
 
+	return &Rows{
+		rows:   rows,
+		fields: q.resultFields(),
+		table:  q.resultTable(),
+	}, nil
 }
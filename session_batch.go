@@ -0,0 +1,251 @@
+package dalkeeth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Sent by BatchChannel after each chunk is committed, and once with Err when saving fails
+type BatchResult struct {
+	Committed int64 // Total number of records committed
+	Err       error
+}
+
+const maxBatchChannelBuffer = 1024
+
+func (sess *Session) BatchChannel(chunkSize int) (chan<- *InRecord, <-chan BatchResult, error) {
+	return sess.BatchChannelContext(context.Background(), chunkSize)
+}
+
+// BatchChannelContext saves the records sent on the returned input channel, committing every chunkSize records.
+// It uses its own transactions, not the one started in the session. Closing the input channel commits the
+// remaining records and then closes the result channel. On error the uncommitted records are rolled back and the
+// rest of the input is discarded until it is closed or the context is done.
+// Results never block saving: a result that has not been read is replaced by the next one, so the last result
+// read is the final total or the error.
+func (sess *Session) BatchChannelContext(ctx context.Context, chunkSize int) (chan<- *InRecord, <-chan BatchResult, error) {
+	if sess.readOnly {
+		return nil, nil, errors.New("BatchChannel: session is read-only")
+	}
+	if chunkSize < 1 {
+		return nil, nil, fmt.Errorf("session.BatchChannel: chunkSize < 1: %d", chunkSize)
+	}
+	if ctx == nil {
+		return nil, nil, errors.New("session.BatchChannel: context is nil")
+	}
+	if sess.db == nil {
+		return nil, nil, errors.New("session.BatchChannel: db is nil")
+	}
+	if sess.dialect == nil {
+		return nil, nil, errors.New("session.BatchChannel: dialect is nil")
+	}
+
+	bufferSize := chunkSize
+	if bufferSize > maxBatchChannelBuffer {
+		bufferSize = maxBatchChannelBuffer
+	}
+	in := make(chan *InRecord, bufferSize)
+	results := make(chan BatchResult, 1)
+
+	b := &batchIngester{
+		sess:      sess,
+		ctx:       ctx,
+		chunkSize: chunkSize,
+		results:   results,
+		stmts:     make(map[string]*sql.Stmt),
+	}
+	go b.ingest(in)
+
+	return in, results, nil
+}
+
+type batchIngester struct {
+	sess      *Session
+	ctx       context.Context
+	chunkSize int
+	results   chan BatchResult
+	tx        *sql.Tx
+	// Prepared on the db once for each distinct insert SQL (table and fields set); nil until prepared
+	stmts map[string]*sql.Stmt
+	// stmts bound to tx
+	txStmts   map[string]*sql.Stmt
+	n         int // Records saved in tx
+	committed int64
+}
+
+func (b *batchIngester) ingest(in <-chan *InRecord) {
+	defer close(b.results)
+	defer b.closeStmts()
+
+	err := b.run(in)
+	if err == nil {
+		return
+	}
+
+	b.sess.logger.Println("session.BatchChannel: error")
+	b.sess.logger.Println(err)
+	b.rollback()
+	b.send(BatchResult{Committed: b.committed, Err: err})
+
+	// Discard so the sender is not blocked
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case _, ok := <-in:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// Replaces the result not yet read, if any; b is the only sender
+func (b *batchIngester) send(result BatchResult) {
+	for {
+		select {
+		case b.results <- result:
+			return
+		default:
+		}
+		select {
+		case <-b.results:
+		default:
+		}
+	}
+}
+
+func (b *batchIngester) run(in <-chan *InRecord) error {
+	for {
+		select {
+		case <-b.ctx.Done():
+			return b.ctx.Err()
+		case rec, ok := <-in:
+			if !ok {
+				return b.commit()
+			}
+			if err := b.save(rec); err != nil {
+				return err
+			}
+			if b.n == b.chunkSize {
+				if err := b.commit(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (b *batchIngester) save(rec *InRecord) error {
+	if rec == nil {
+		return errors.New("session.BatchChannel: record is nil")
+	}
+	saveSql, err := b.sess.dialect.SaveSql(rec)
+	if err != nil {
+		return err
+	}
+
+	if b.tx == nil {
+		if _, ok := b.stmts[saveSql]; !ok {
+			b.stmts[saveSql] = nil
+		}
+		if err = b.begin(); err != nil {
+			return err
+		}
+	}
+
+	stmt, err := b.txStmt(saveSql)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(b.ctx, rawValues(rec.values)...)
+	if err != nil {
+		return err
+	}
+	b.n++
+	return nil
+}
+
+// The statements are prepared on the db before the transaction begins, as the db may only have one connection
+func (b *batchIngester) begin() error {
+	for saveSql, stmt := range b.stmts {
+		if stmt != nil {
+			continue
+		}
+		stmt, err := b.sess.db.PrepareContext(b.ctx, saveSql)
+		if err != nil {
+			return err
+		}
+		b.stmts[saveSql] = stmt
+	}
+
+	tx, err := b.sess.db.BeginTx(b.ctx, nil)
+	if err != nil {
+		return err
+	}
+	b.tx = tx
+	b.txStmts = make(map[string]*sql.Stmt)
+	return nil
+}
+
+// Statement of the insert SQL bound to tx; an SQL first seen in tx is prepared in tx, and on the db before the next one
+func (b *batchIngester) txStmt(saveSql string) (*sql.Stmt, error) {
+	if stmt, ok := b.txStmts[saveSql]; ok {
+		return stmt, nil
+	}
+
+	var stmt *sql.Stmt
+	if dbStmt := b.stmts[saveSql]; dbStmt != nil {
+		stmt = b.tx.StmtContext(b.ctx, dbStmt)
+	} else {
+		b.stmts[saveSql] = nil
+		var err error
+		stmt, err = b.tx.PrepareContext(b.ctx, saveSql)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b.txStmts[saveSql] = stmt
+	return stmt, nil
+}
+
+// The statements bound to tx are closed by Commit and Rollback
+func (b *batchIngester) commit() error {
+	if b.tx == nil {
+		return nil
+	}
+	err := b.tx.Commit()
+	b.tx = nil
+	if err != nil {
+		return err
+	}
+
+	b.committed += int64(b.n)
+	b.n = 0
+	b.send(BatchResult{Committed: b.committed})
+	return nil
+}
+
+func (b *batchIngester) rollback() {
+	if b.tx == nil {
+		return
+	}
+	if err := b.tx.Rollback(); err != nil {
+		b.sess.logger.Println(err)
+	}
+	b.tx = nil
+	b.n = 0
+}
+
+func (b *batchIngester) closeStmts() {
+	for _, stmt := range b.stmts {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil {
+			b.sess.logger.Println(err)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package dalkeeth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSession_BatchChannel(t *testing.T) {
+	setupTest()
+	mdl0, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(mdl0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	records, err := nPersonRecords(persons, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, results, err := sess.BatchChannel(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for i := 0; i < len(records); i++ {
+			in <- records[i]
+		}
+		close(in)
+	}()
+
+	// Results not read in time are replaced by the next one
+	var committed []int64
+	for result := range results {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		if len(committed) > 0 && result.Committed <= committed[len(committed)-1] {
+			t.Fatal(fmt.Errorf("Wrong progress: %v then %d", committed, result.Committed))
+		}
+		committed = append(committed, result.Committed)
+	}
+	if len(committed) == 0 || committed[len(committed)-1] != 25 {
+		t.Fatal(fmt.Errorf("Wrong progress: %v", committed))
+	}
+
+	n, err := persons.Count(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 25 {
+		t.Fatal(fmt.Errorf("Should have 25 records; have %d", n))
+	}
+}
+
+func TestSession_BatchChannel_Error(t *testing.T) {
+	setupTest()
+	mdl0, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(mdl0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	records, err := nPersonRecords(persons, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Duplicate primary key in the second chunk
+	records[15] = records[12]
+
+	in, results, err := sess.BatchChannel(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for i := 0; i < len(records); i++ {
+			in <- records[i]
+		}
+		close(in)
+	}()
+
+	var last BatchResult
+	for result := range results {
+		last = result
+	}
+	if last.Err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+	if last.Committed != 10 {
+		t.Fatal(fmt.Errorf("Should have committed 10 records; committed %d", last.Committed))
+	}
+
+	n, err := persons.Count(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Fatal(fmt.Errorf("Should have 10 records; have %d", n))
+	}
+}
+
+// Results are only read after all the records are sent
+func TestSession_BatchChannel_OneGoroutine(t *testing.T) {
+	setupTest()
+	mdl0, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(mdl0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	records, err := nPersonRecords(persons, 45)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, results, err := sess.BatchChannel(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(records); i++ {
+		in <- records[i]
+	}
+	close(in)
+
+	var last BatchResult
+	for result := range results {
+		last = result
+	}
+	if last.Err != nil {
+		t.Fatal(last.Err)
+	}
+	if last.Committed != 45 {
+		t.Fatal(fmt.Errorf("Should have committed 45 records; committed %d", last.Committed))
+	}
+}
+
+// The input is never closed after the error
+func TestSession_BatchChannel_ErrorCancel(t *testing.T) {
+	setupTest()
+	mdl0, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(mdl0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	records, err := nPersonRecords(persons, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in, results, err := sess.BatchChannelContext(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in <- records[0]
+	in <- records[0]
+	if result := <-results; result.Err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+
+	cancel()
+	for range results {
+	}
+}
+
+func TestSession_BatchChannel_Invalid(t *testing.T) {
+	setupTest()
+	mdl0, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(mdl0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	_, _, err = sess.BatchChannel(0)
+	if err == nil {
+		t.Fatal("chunkSize 0", ShouldHaveFailed)
+	}
+
+	sess.readOnly = true
+	_, _, err = sess.BatchChannel(10)
+	if err == nil {
+		t.Fatal("Read-only", ShouldHaveFailed)
+	}
+}
@@ -0,0 +1,48 @@
+package dalkeeth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// SessionOption configures a Session created by Open or OpenDB
+type SessionOption func(*Session) error
+
+// WithReadOnly makes the session refuse all writes to the database
+func WithReadOnly() SessionOption {
+	return func(sess *Session) error {
+		sess.readOnly = true
+		return nil
+	}
+}
+
+// WithSelectLimit sets the maximum number of records returned by a select on tbl
+func WithSelectLimit(tbl *Table, limit int64) SessionOption {
+	return func(sess *Session) error {
+		if tbl == nil {
+			return errors.New("WithSelectLimit: table is nil")
+		}
+		if !sess.model.HasTable(tbl) {
+			return fmt.Errorf("WithSelectLimit: table %s is not in the model", tbl.name)
+		}
+		if limit < 0 {
+			return fmt.Errorf("WithSelectLimit: limit < 0: %d", limit)
+		}
+		sess.selectLimits[tbl] = limit
+		return nil
+	}
+}
+
+// WithLogger sets the logger the session writes its SQL and diagnostics to;
+// nil discards all session logging
+func WithLogger(logger *log.Logger) SessionOption {
+	return func(sess *Session) error {
+		if logger == nil {
+			logger = log.New(io.Discard, "", 0)
+		}
+		sess.logger = logger
+		return nil
+	}
+}
@@ -0,0 +1,138 @@
+package dalkeeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type schemaOptions struct {
+	ifNotExists  bool
+	dropExisting bool
+}
+
+// SchemaOption is an option of WriteModelTableSchemaToDB and SchemaPlan
+type SchemaOption func(*schemaOptions) error
+
+// WithIfNotExists skips the model tables, and their indexes, which already exist in the database
+func WithIfNotExists() SchemaOption {
+	return func(o *schemaOptions) error {
+		if o.dropExisting {
+			return errors.New("WithIfNotExists: not possible with WithDropExisting")
+		}
+		o.ifNotExists = true
+		return nil
+	}
+}
+
+// WithDropExisting drops the model tables which already exist in the database before creating them; their records are lost
+func WithDropExisting() SchemaOption {
+	return func(o *schemaOptions) error {
+		if o.ifNotExists {
+			return errors.New("WithDropExisting: not possible with WithIfNotExists")
+		}
+		o.dropExisting = true
+		return nil
+	}
+}
+
+// WriteModelTableSchemaToDB creates the tables of the model in foreign key dependency order, then their indexes,
+// in one transaction. Without options it is an error if a model table already exists.
+func (sess *Session) WriteModelTableSchemaToDB(opts ...SchemaOption) error {
+	return sess.WriteModelTableSchemaToDBContext(context.Background(), opts...)
+}
+
+func (sess *Session) WriteModelTableSchemaToDBContext(ctx context.Context, opts ...SchemaOption) error {
+	if sess.readOnly {
+		return errors.New("Session.WriteModelTableSchemaToDB: session is read-only")
+	}
+	if sess.tx != nil {
+		return errors.New("Session.WriteModelTableSchemaToDB: transaction already started")
+	}
+	plan, err := sess.SchemaPlan(opts...)
+	if err != nil {
+		return err
+	}
+	if plan.Empty() {
+		return nil
+	}
+	return sess.dialect.ApplyMigration(ctx, sess.db, plan)
+}
+
+// SchemaPlan returns the statements WriteModelTableSchemaToDB would run, without running them; plan.String() is
+// the SQL script
+func (sess *Session) SchemaPlan(opts ...SchemaOption) (*MigrationPlan, error) {
+	if sess.db == nil {
+		return nil, errors.New("Session.SchemaPlan: db is nil")
+	}
+	if sess.dialect == nil {
+		return nil, errors.New("Session.SchemaPlan: dialect is nil")
+	}
+	o := new(schemaOptions)
+	for i := 0; i < len(opts); i++ {
+		if err := opts[i](o); err != nil {
+			return nil, err
+		}
+	}
+
+	tables, err := tablesInDependencyOrder(sess.model.tables)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := sess.dialect.TableNames(sess.db)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]struct{}, len(names))
+	for i := 0; i < len(names); i++ {
+		existing[names[i]] = struct{}{}
+	}
+
+	plan := new(MigrationPlan)
+	var create []*Table
+	for i := 0; i < len(tables); i++ {
+		if _, ok := existing[tables[i].name]; ok {
+			switch {
+			case o.ifNotExists:
+				continue
+			case !o.dropExisting:
+				return nil, fmt.Errorf("Session.SchemaPlan: table %s already exists", tables[i].name)
+			}
+		}
+		create = append(create, tables[i])
+	}
+
+	if o.dropExisting {
+		for i := len(tables) - 1; i >= 0; i-- {
+			if _, ok := existing[tables[i].name]; !ok {
+				continue
+			}
+			s, err := sess.dialect.DropTableSql(tables[i])
+			if err != nil {
+				return nil, err
+			}
+			plan.Statements = append(plan.Statements, s)
+		}
+	}
+
+	for i := 0; i < len(create); i++ {
+		s, err := sess.dialect.CreateTableSql(create[i])
+		if err != nil {
+			return nil, err
+		}
+		plan.Statements = append(plan.Statements, s)
+	}
+
+	for i := 0; i < len(create); i++ {
+		for j := 0; j < len(create[i].indexes); j++ {
+			s, err := sess.dialect.CreateTableIndexSql(create[i].indexes[j])
+			if err != nil {
+				return nil, err
+			}
+			plan.Statements = append(plan.Statements, s)
+		}
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,139 @@
+package dalkeeth
+
+import (
+	"testing"
+)
+
+// Model with the referencing table added before the table it references
+func schemaTestModel(cycle bool) (*Model, error) {
+	mdl := NewModel()
+	pets, err := mdl.NewTable("pets")
+	if err != nil {
+		return nil, err
+	}
+	if err = pets.AddFields(NewField("id", IntType).PK(), NewField("owner_id", IntType)); err != nil {
+		return nil, err
+	}
+	if err = pets.AddIndex(false, "owner_id"); err != nil {
+		return nil, err
+	}
+	owners, err := mdl.NewTable("owners")
+	if err != nil {
+		return nil, err
+	}
+	if err = owners.AddFields(NewField("id", IntType).PK(), NewField("pet_id", IntType)); err != nil {
+		return nil, err
+	}
+	if err = mdl.AddForeignKey(pets, "owner_id", owners, "id"); err != nil {
+		return nil, err
+	}
+	if cycle {
+		if err = mdl.AddForeignKey(owners, "pet_id", pets, "id"); err != nil {
+			return nil, err
+		}
+	}
+	return mdl, mdl.Freeze()
+}
+
+func schemaTestSession(cycle bool) (*Session, error) {
+	mdl, err := schemaTestModel(cycle)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openTestDB()
+	if err != nil {
+		return nil, err
+	}
+	return OpenDB(mdl, db, new(DialectSqlite3))
+}
+
+func TestSession_SchemaPlan(t *testing.T) {
+	sess, err := schemaTestSession(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	plan, err := sess.SchemaPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE TABLE IF NOT EXISTS "owners" ("id" INT PRIMARY KEY, "pet_id" INT);` + "\n" +
+		`CREATE TABLE IF NOT EXISTS "pets" ("id" INT PRIMARY KEY, "owner_id" INT, FOREIGN KEY("owner_id") REFERENCES "owners"("id"));` + "\n" +
+		`CREATE  INDEX "idx_pets_owner_id" ON "pets"("owner_id");` + "\n"
+	if plan.String() != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, plan.String())
+	}
+
+	// Dry run does not touch the database
+	names, err := sess.dialect.TableNames(sess.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Fatal("SchemaPlan should not create tables:", names)
+	}
+
+	if _, err = sess.SchemaPlan(WithIfNotExists(), WithDropExisting()); err == nil {
+		t.Fatal("WithIfNotExists and WithDropExisting", ShouldHaveFailed)
+	}
+}
+
+func TestSession_WriteModelTableSchemaToDB(t *testing.T) {
+	sess, err := schemaTestSession(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	if err = sess.WriteModelTableSchemaToDB(); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := sess.DiffSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Fatal("Database should match the model:", diff)
+	}
+
+	owners := sess.TableByKey("owners")
+	rec := owners.NewRecord()
+	if err = rec.SetValue("id", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tables exist
+	if err = sess.WriteModelTableSchemaToDB(); err == nil {
+		t.Fatal("Existing tables", ShouldHaveFailed)
+	}
+
+	if err = sess.WriteModelTableSchemaToDB(WithIfNotExists()); err != nil {
+		t.Fatal(err)
+	}
+	if rec, err = sess.Get(owners, 1); err != nil || rec == nil {
+		t.Fatal("WithIfNotExists should keep the records", err)
+	}
+
+	if err = sess.WriteModelTableSchemaToDB(WithDropExisting()); err != nil {
+		t.Fatal(err)
+	}
+	if rec, err = sess.Get(owners, 1); err != nil || rec != nil {
+		t.Fatal("WithDropExisting should recreate the tables", err)
+	}
+}
+
+func TestSession_WriteModelTableSchemaToDB_Cycle(t *testing.T) {
+	sess, err := schemaTestSession(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	if err = sess.WriteModelTableSchemaToDB(); err == nil {
+		t.Fatal("Foreign key cycle", ShouldHaveFailed)
+	}
+}
@@ -1,10 +1,12 @@
 package dalkeeth
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
+	"io"
 	"log"
 	"testing"
 )
@@ -164,7 +166,7 @@ func Test_Session_Session_SaveTx(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	valid, err := recordExists(sess.db, rec.table.name, pk)
+	valid, err := recordExists(context.Background(), sess.db, sess.dialect, rec.table, pk)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -215,7 +217,7 @@ func Test_Session_Session_Save(t *testing.T) {
 	}
 
 	// See if we can read the record that was just written
-	valid, err := recordExists(sess.db, rec.table.name, pk)
+	valid, err := recordExists(context.Background(), sess.db, sess.dialect, rec.table, pk)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -254,14 +256,14 @@ func Test_Session_Session_Batch(t *testing.T) {
 	}
 
 	// See if the 2 records added are readable
-	valid, err := recordExists(sess.db, records[0].table.name, VPersonID0)
+	valid, err := recordExists(context.Background(), sess.db, sess.dialect, records[0].table, VPersonID0)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if !valid {
 		t.Fatal(errors.New("Value not in database"))
 	}
-	valid, err = recordExists(sess.db, records[1].table.name, VPersonID1)
+	valid, err = recordExists(context.Background(), sess.db, sess.dialect, records[1].table, VPersonID1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -360,7 +362,7 @@ func Test_Session_Session_BatchMany(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	valid, err := recordExists(sess.db, records[0].table.name, VPersonID0)
+	valid, err := recordExists(context.Background(), sess.db, sess.dialect, records[0].table, VPersonID0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -369,7 +371,7 @@ func Test_Session_Session_BatchMany(t *testing.T) {
 	}
 	log.Println("Found", VPersonID0, "in DB")
 
-	valid, err = recordExists(sess.db, records[1].table.name, VPersonID1)
+	valid, err = recordExists(context.Background(), sess.db, sess.dialect, records[1].table, VPersonID1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -489,7 +491,7 @@ func Test_Session_Session_Get(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	valid, err := recordExists(sess.db, records[0].table.name, VPersonID0)
+	valid, err := recordExists(context.Background(), sess.db, sess.dialect, records[0].table, VPersonID0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -498,7 +500,7 @@ func Test_Session_Session_Get(t *testing.T) {
 	}
 	log.Println("Found", VPersonID0, "in DB")
 
-	valid, err = recordExists(sess.db, records[1].table.name, VPersonID1)
+	valid, err = recordExists(context.Background(), sess.db, sess.dialect, records[1].table, VPersonID1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -547,7 +549,13 @@ func TestSession_InstantiateModel_ReadOnly(t *testing.T) {
 }
 
 func openTestDB() (*sql.DB, error) {
-	return sql.Open("sqlite3", ":memory:")
+	db, err := sql.Open(testSqliteDriver, ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// Each connection to :memory: is a different database
+	db.SetMaxOpenConns(1)
+	return db, nil
 }
 func addForeignKey_Setup() (*Model, *Table, *Table, error) {
 	model, err := testModel0()
@@ -571,3 +579,461 @@ func addForeignKey_Setup() (*Model, *Table, *Table, error) {
 	return model, persons, addresses, nil
 
 }
+
+func TestSession_Open(t *testing.T) {
+	setupTest()
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := model.TableByKey(TPerson)
+
+	sess, err := Open(model, testSqliteDriver, ":memory:", new(DialectSqlite3), WithSelectLimit(persons, 10), WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	if sess.DB() == nil {
+		t.Fatal(errors.New("Session db is nil"))
+	}
+	if sess.Dialect() == nil {
+		t.Fatal(errors.New("Session dialect is nil"))
+	}
+	if sess.SelectLimit(persons) != 10 {
+		t.Fatal(fmt.Errorf("Select limit should be 10; is %d", sess.SelectLimit(persons)))
+	}
+}
+
+// WithLogger(nil) silences the session; nothing goes to the standard logger either
+func TestSession_Open_NoLogging(t *testing.T) {
+	setupTest()
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(io.Discard)
+
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	persons := model.TableByKey(TPerson)
+	db, err := openTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := OpenDB(model, db, new(DialectSqlite3), WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	if err = sess.WriteModelTableSchemaToDB(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := persons.NewRecord()
+	if err = rec.SetValue(FId, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sess.Get(persons, 1); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := sess.ExecuteQuery(NewQuery().Select(persons.Field(FId)).From(persons).Where(W(persons.Field(FId), EQ, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	if out.Len() != 0 {
+		t.Fatal("Should not log:", out.String())
+	}
+}
+
+func TestSession_Open_ReadOnly(t *testing.T) {
+	setupTest()
+	model, persons, _, err := addForeignKey_Setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := Open(model, testSqliteDriver, ":memory:", new(DialectSqlite3), WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	if !sess.ReadOnly() {
+		t.Fatal(errors.New("Session should be read-only"))
+	}
+
+	rec := persons.NewRecord()
+	err = rec.SetValue(FId, 23)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.Save(rec)
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
+
+func TestSession_Open_UnknownDriver(t *testing.T) {
+	setupTest()
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Open(model, "not-a-driver", ":memory:", new(DialectSqlite3))
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
+
+func TestSession_OpenDB_NilDialect(t *testing.T) {
+	setupTest()
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = OpenDB(model, db, nil)
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
+
+func TestSession_OpenDB_SelectLimitUnknownTable(t *testing.T) {
+	setupTest()
+	model, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openTestDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tbl, err := NewTable2("not_in_model")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenDB(model, db, new(DialectSqlite3), WithSelectLimit(tbl, 10))
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
+
+func TestSession_Update(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	rec := persons.NewRecord()
+	if err = rec.SetValue(FId, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.SetValue(FName, "Sally"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := sess.Update(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal(fmt.Errorf("Should update 1 record; updated %d", n))
+	}
+
+	rec, err = sess.Get(persons, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err = rec.GetString(FName, &name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Sally" {
+		t.Fatal(fmt.Errorf("Name should be Sally; is %s", name))
+	}
+	// Fields not set are not updated
+	var age int64
+	if err = rec.GetInt(FAge, &age); err != nil {
+		t.Fatal(err)
+	}
+	if age != 54 {
+		t.Fatal(fmt.Errorf("Age should be 54; is %d", age))
+	}
+
+	// Unknown id
+	rec = persons.NewRecord()
+	if err = rec.SetValue(FId, 5000); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.SetValue(FName, "Sally"); err != nil {
+		t.Fatal(err)
+	}
+	n, err = sess.Update(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal(fmt.Errorf("Should update 0 records; updated %d", n))
+	}
+}
+
+func TestSession_Update_NoPK(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	rec := sess.TableByKey(TPerson).NewRecord()
+	if err = rec.SetValue(FName, "Sally"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = sess.Update(rec)
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
+
+func TestSession_UpdateWhere(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	err = sess.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := sess.UpdateWhere(persons, W(persons.Field(FId), GE, 7), NewValue(persons.Field(FAge), 12), NewValue(persons.Field(FName), "Young"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatal(fmt.Errorf("Should update 3 records; updated %d", n))
+	}
+	err = sess.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := sess.NewSelectQuery().Select(persons.Field(FId))
+	q.Where = And(W(persons.Field(FAge), EQ, 12), W(persons.Field(FName), EQ, "Young"))
+	ids, err := q.Pluck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 {
+		t.Fatal(fmt.Errorf("Should be 3 updated records; are %d", len(ids)))
+	}
+
+	n, err = sess.UpdateWhereS(TPerson, W(persons.Field(FId), EQ, 0), NewValue(persons.Field(FAge), 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal(fmt.Errorf("Should update 1 record; updated %d", n))
+	}
+}
+
+func TestSession_UpdateWhere_Invalid(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+	addresses := sess.TableByKey(TAddress)
+	cond := W(persons.Field(FId), EQ, 0)
+
+	if _, err = sess.UpdateWhere(persons, nil, NewValue(persons.Field(FAge), 1)); err == nil {
+		t.Error("nil condition", ShouldHaveFailed)
+	}
+	if _, err = sess.UpdateWhere(persons, cond); err == nil {
+		t.Error("no values", ShouldHaveFailed)
+	}
+	if _, err = sess.UpdateWhere(persons, cond, NewValue(addresses.Field(FCity), "x")); err == nil {
+		t.Error("field of other table", ShouldHaveFailed)
+	}
+	if _, err = sess.UpdateWhere(persons, cond, NewValue(persons.Field(FAge), "x")); err == nil {
+		t.Error("wrong value type", ShouldHaveFailed)
+	}
+	sess.readOnly = true
+	if _, err = sess.UpdateWhere(persons, cond, NewValue(persons.Field(FAge), 1)); err == nil {
+		t.Error("read-only", ShouldHaveFailed)
+	}
+}
+
+func TestSession_Delete(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	n, err := sess.Delete(persons, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal(fmt.Errorf("Should delete 1 record; deleted %d", n))
+	}
+	exists, err := sess.Exists(persons, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("Record 4 should be deleted")
+	}
+
+	n, err = sess.DeleteS(TPerson, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal(fmt.Errorf("Should delete 0 records; deleted %d", n))
+	}
+
+	if _, err = sess.DeleteS("not-a-table", 4); err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
+
+func TestSession_DeleteWhere(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	err = sess.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := sess.DeleteWhere(persons, Or(W(persons.Field(FId), LT, 2), W(persons.Field(FName), In, "Fred_8", "Fred_9")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatal(fmt.Errorf("Should delete 4 records; deleted %d", n))
+	}
+	err = sess.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := persons.Count(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 {
+		t.Fatal(fmt.Errorf("Should be 6 records left; are %d", count))
+	}
+
+	n, err = sess.DeleteWhereS(TPerson, W(persons.Field(FAge), EQ, 54))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Fatal(fmt.Errorf("Should delete 6 records; deleted %d", n))
+	}
+}
+
+func TestSession_DeleteWhere_Invalid(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	if _, err = sess.DeleteWhere(persons, nil); err == nil {
+		t.Error("nil condition", ShouldHaveFailed)
+	}
+	if _, err = sess.DeleteWhere(nil, W(persons.Field(FId), EQ, 0)); err == nil {
+		t.Error("nil table", ShouldHaveFailed)
+	}
+	sess.readOnly = true
+	if _, err = sess.DeleteWhere(persons, W(persons.Field(FId), EQ, 0)); err == nil {
+		t.Error("read-only", ShouldHaveFailed)
+	}
+	if _, err = sess.Delete(persons, 0); err == nil {
+		t.Error("read-only", ShouldHaveFailed)
+	}
+}
+
+func TestSession_Upsert(t *testing.T) {
+	sess, err := relationTestSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey("persons")
+
+	for _, name := range []string{"Frederick", "Freddy"} {
+		rec := persons.NewRecord()
+		if err = rec.SetValue("id", 1); err != nil {
+			t.Fatal(err)
+		}
+		if err = rec.SetValue("name", name); err != nil {
+			t.Fatal(err)
+		}
+		if err = sess.Upsert(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := persons.NewRecord()
+	if err = rec.SetValue("id", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Upsert(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	fred, err := sess.Get(persons, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recordNames(t, []*InRecord{fred}, "name"); got != "Freddy" {
+		t.Fatal("Expected name Freddy; got", got)
+	}
+	n, err := persons.Count(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatal("Expected 4 persons; got", n)
+	}
+
+	sess.readOnly = true
+	if err = sess.Upsert(rec); err == nil {
+		t.Error("read-only", ShouldHaveFailed)
+	}
+}
@@ -0,0 +1,155 @@
+package dalkeeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Savepoint starts a savepoint in the current transaction
+func (sess *Session) Savepoint(name string) error {
+	if sess.tx == nil {
+		return errors.New("Savepoint: no transaction started")
+	}
+	if !isIdentifier(name) {
+		return fmt.Errorf("Savepoint: invalid name [%s]", name)
+	}
+	if sess.savepointIndex(name) >= 0 {
+		return fmt.Errorf("Savepoint: %s already exists", name)
+	}
+
+	if _, err := sess.tx.Exec("SAVEPOINT " + name); err != nil {
+		return err
+	}
+	sess.savepoints = append(sess.savepoints, name)
+	return nil
+}
+
+// ReleaseSavepoint keeps the changes made since the savepoint; the savepoint and the savepoints started after it are removed
+func (sess *Session) ReleaseSavepoint(name string) error {
+	i, err := sess.activeSavepoint(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sess.tx.Exec("RELEASE SAVEPOINT " + name); err != nil {
+		return err
+	}
+	sess.savepoints = sess.savepoints[:i]
+	return nil
+}
+
+// RollbackToSavepoint undoes the changes made since the savepoint; the savepoints started after it are removed
+func (sess *Session) RollbackToSavepoint(name string) error {
+	i, err := sess.activeSavepoint(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sess.tx.Exec("ROLLBACK TO SAVEPOINT " + name); err != nil {
+		return err
+	}
+	sess.savepoints = sess.savepoints[:i+1]
+	return nil
+}
+
+func (sess *Session) activeSavepoint(name string) (int, error) {
+	if sess.tx == nil {
+		return -1, errors.New("Savepoint: no transaction started")
+	}
+	i := sess.savepointIndex(name)
+	if i < 0 {
+		return -1, fmt.Errorf("Savepoint: unknown savepoint %s", name)
+	}
+	return i, nil
+}
+
+func (sess *Session) savepointIndex(name string) int {
+	for i := 0; i < len(sess.savepoints); i++ {
+		if sess.savepoints[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// WithTx runs fn in a transaction: committed if fn returns nil, rolled back if fn returns an error or panics.
+// If a transaction has already been started, fn is run in a savepoint of it, which is released or rolled back.
+func (sess *Session) WithTx(ctx context.Context, fn func(*Session) error) (err error) {
+	if fn == nil {
+		return errors.New("WithTx: func is nil")
+	}
+	if sess.tx != nil {
+		return sess.withSavepoint(fn)
+	}
+	if sess.db == nil {
+		return errors.New("WithTx: db is nil")
+	}
+
+	if err = sess.BeginTx(ctx, nil); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			if sess.tx != nil {
+				if rollbackErr := sess.Rollback(); rollbackErr != nil {
+					sess.logger.Println(rollbackErr)
+				}
+			}
+			panic(p)
+		}
+	}()
+
+	if err = fn(sess); err != nil {
+		if sess.tx != nil {
+			if rollbackErr := sess.Rollback(); rollbackErr != nil {
+				sess.logger.Println(rollbackErr)
+			}
+		}
+		return err
+	}
+
+	if sess.tx == nil {
+		return errors.New("WithTx: transaction ended by func")
+	}
+	return sess.Commit()
+}
+
+func (sess *Session) withSavepoint(fn func(*Session) error) (err error) {
+	name := "dalkeeth_sp_" + strconv.Itoa(len(sess.savepoints))
+	if err = sess.Savepoint(name); err != nil {
+		return err
+	}
+
+	rollback := func() {
+		if sess.tx == nil || sess.savepointIndex(name) < 0 {
+			return
+		}
+		if rollbackErr := sess.RollbackToSavepoint(name); rollbackErr != nil {
+			sess.logger.Println(rollbackErr)
+			return
+		}
+		if releaseErr := sess.ReleaseSavepoint(name); releaseErr != nil {
+			sess.logger.Println(releaseErr)
+		}
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(sess); err != nil {
+		rollback()
+		return err
+	}
+
+	if sess.tx == nil {
+		return errors.New("WithTx: transaction ended by func")
+	}
+	return sess.ReleaseSavepoint(name)
+}
@@ -0,0 +1,382 @@
+package dalkeeth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSession_Rollback(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	err = sess.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sess.Delete(persons, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := sess.Exists(persons, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("Delete should have been rolled back")
+	}
+
+	// No transaction
+	err = sess.Rollback()
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+}
+
+func TestSession_Batch_RollbackOnError(t *testing.T) {
+	setupTest()
+	mdl0, err := testModel0()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(mdl0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	records, err := nPersonRecords(persons, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Duplicate primary key
+	records = append(records, records[0])
+
+	err = sess.Batch(records)
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+	if sess.tx != nil {
+		t.Fatal("Transaction should be finished")
+	}
+
+	n, err := persons.Count(sess.db, sess.dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal(fmt.Errorf("Batch should be rolled back; %d records", n))
+	}
+}
+
+func TestSession_Batch_InTransaction(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	err = sess.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sess.Delete(persons, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fails: only the batch is rolled back
+	records, err := nPersonRecords(persons, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = records[0].SetValue(FId, 100); err != nil {
+		t.Fatal(err)
+	}
+	err = sess.Batch(records)
+	if err == nil {
+		t.Fatal(ShouldHaveFailed)
+	}
+	if sess.tx == nil {
+		t.Fatal("Transaction should not be finished")
+	}
+
+	// A later batch in the same transaction
+	records, err = nPersonRecords(persons, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = records[0].SetValue(FId, 200); err != nil {
+		t.Fatal(err)
+	}
+	err = sess.Batch(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sess.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, expected := range map[int64]bool{0: false, 1: true, 100: false, 200: true} {
+		exists, err := sess.Exists(persons, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != expected {
+			t.Fatal(fmt.Errorf("Record %d exists should be %t", id, expected))
+		}
+	}
+}
+
+func TestSession_Savepoints(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	err = sess.Savepoint("sp1")
+	if err == nil {
+		t.Fatal("No transaction", ShouldHaveFailed)
+	}
+
+	err = sess.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Savepoint("sp1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sess.Delete(persons, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Savepoint("sp2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = sess.Delete(persons, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Savepoint("sp1"); err == nil {
+		t.Fatal("Duplicate savepoint", ShouldHaveFailed)
+	}
+	if err = sess.Savepoint("bad name"); err == nil {
+		t.Fatal("Invalid savepoint name", ShouldHaveFailed)
+	}
+
+	// Undo delete of 2
+	if err = sess.RollbackToSavepoint("sp2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.ReleaseSavepoint("sp1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sess.savepoints) != 0 {
+		t.Fatal(fmt.Errorf("All savepoints should be released: %v", sess.savepoints))
+	}
+	if err = sess.ReleaseSavepoint("sp2"); err == nil {
+		t.Fatal("Released savepoint", ShouldHaveFailed)
+	}
+	if err = sess.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, expected := range map[int64]bool{1: false, 2: true} {
+		exists, err := sess.Exists(persons, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != expected {
+			t.Fatal(fmt.Errorf("Record %d exists should be %t", id, expected))
+		}
+	}
+}
+
+func TestSession_WithTx(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+
+	// Committed
+	err = sess.WithTx(ctx, func(tx *Session) error {
+		_, err := tx.Delete(persons, 1)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rolled back
+	err = sess.WithTx(ctx, func(tx *Session) error {
+		if _, err := tx.Delete(persons, 2); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if err != errFailed {
+		t.Fatal(fmt.Errorf("Should return func error: %v", err))
+	}
+
+	// Nested: inner rolled back, outer committed
+	err = sess.WithTx(ctx, func(tx *Session) error {
+		if _, err := tx.Delete(persons, 3); err != nil {
+			return err
+		}
+		innerErr := tx.WithTx(ctx, func(tx *Session) error {
+			if _, err := tx.Delete(persons, 4); err != nil {
+				return err
+			}
+			return errFailed
+		})
+		if innerErr != errFailed {
+			return fmt.Errorf("Should return inner func error: %v", innerErr)
+		}
+		return tx.WithTx(ctx, func(tx *Session) error {
+			_, err := tx.Delete(persons, 5)
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Panic rolled back
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Should have panicked")
+			}
+		}()
+		sess.WithTx(ctx, func(tx *Session) error {
+			if _, err := tx.Delete(persons, 6); err != nil {
+				return err
+			}
+			panic("panic in tx")
+		})
+	}()
+	if sess.tx != nil {
+		t.Fatal("Transaction should be finished")
+	}
+
+	for id, expected := range map[int64]bool{1: false, 2: true, 3: false, 4: true, 5: false, 6: true} {
+		exists, err := sess.Exists(persons, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != expected {
+			t.Fatal(fmt.Errorf("Record %d exists should be %t", id, expected))
+		}
+	}
+}
+
+func TestSession_Context_Canceled(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = sess.GetContext(ctx, persons, 1)
+	if err == nil {
+		t.Fatal("Get", ShouldHaveFailed)
+	}
+	_, err = sess.ExistsContext(ctx, persons, 1)
+	if err == nil {
+		t.Fatal("Exists", ShouldHaveFailed)
+	}
+	_, err = sess.DeleteContext(ctx, persons, 1)
+	if err == nil {
+		t.Fatal("Delete", ShouldHaveFailed)
+	}
+	err = sess.BeginTx(ctx, nil)
+	if err == nil {
+		t.Fatal("BeginTx", ShouldHaveFailed)
+	}
+	if sess.tx != nil {
+		t.Fatal("Transaction should not be started")
+	}
+	records, err := nPersonRecords(persons, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = records[0].SetValue(FId, 100); err != nil {
+		t.Fatal(err)
+	}
+	err = sess.BatchContext(ctx, records)
+	if err == nil {
+		t.Fatal("Batch", ShouldHaveFailed)
+	}
+	_, err = sess.NewSelectQuery().Select(persons.fields[0]).WithContext(ctx).First()
+	if err == nil {
+		t.Fatal("SelectQuery.First", ShouldHaveFailed)
+	}
+
+	exists, err := sess.Exists(persons, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("Record should not have been deleted")
+	}
+}
+
+func TestSession_BeginTx_Options(t *testing.T) {
+	setupTest()
+	sess, err := selectQueryTestSession(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	persons := sess.TableByKey(TPerson)
+
+	err = sess.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.BeginTx(context.Background(), nil); err == nil {
+		t.Fatal("Already in transaction", ShouldHaveFailed)
+	}
+	if _, err = sess.DeleteContext(context.Background(), persons, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := sess.Exists(persons, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("Record should have been deleted")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build cgo
+
+package dalkeeth
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	testSqliteDrivers = append([]string{SqliteCgoDriver}, testSqliteDrivers...)
+}
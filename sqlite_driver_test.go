@@ -0,0 +1,11 @@
+package dalkeeth
+
+import (
+	_ "modernc.org/sqlite"
+)
+
+// SQLite drivers of the tests; the cgo driver is added when built with cgo
+var testSqliteDrivers = []string{SqlitePureGoDriver}
+
+// Driver of the current run of the tests; see TestMain
+var testSqliteDriver string
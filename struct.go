@@ -0,0 +1,438 @@
+package dalkeeth
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Struct tag: `dalkeeth:"name,pk,notnull,unique,len=64,default=x,index,fk=table.field"`.
+// A fk can have the options ondelete=action, onupdate=action (see ParseForeignKeyAction) and deferrable.
+// The name defaults to the lower case Go field name; "-" skips the Go field.
+// Embedded structs are flattened; unexported Go fields are skipped.
+const StructTag = "dalkeeth"
+
+type structField struct {
+	index        []int
+	name         string
+	fieldType    FieldType
+	pk           bool
+	notNull      bool
+	unique       bool
+	indexed      bool
+	length       int
+	defaultValue *string
+	fkTable      string
+	fkField      string
+	fkOptions    []ForeignKeyOption
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	bytesType   = reflect.TypeOf([]byte(nil))
+)
+
+// TableFromStruct adds a table with the name, and the fields, indexes and foreign keys from the tags of the struct v
+// (or pointer to struct). Foreign tables must already be in the model.
+func (m *Model) TableFromStruct(name string, v any) (*Table, error) {
+	sfs, err := structFields(v)
+	if err != nil {
+		return nil, err
+	}
+
+	tbl, err := m.NewTable(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(sfs); i++ {
+		f, err := sfs[i].field()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = tbl.AddField(f); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < len(sfs); i++ {
+		sf := sfs[i]
+		if sf.indexed {
+			if err = tbl.AddIndex(false, sf.name); err != nil {
+				return nil, err
+			}
+		}
+		if sf.fkTable != "" {
+			foreignTbl := m.TableByKey(sf.fkTable)
+			if foreignTbl == nil {
+				return nil, fmt.Errorf("TableFromStruct: field %s: unknown foreign table %s", sf.name, sf.fkTable)
+			}
+			if err = m.AddForeignKey(tbl, sf.name, foreignTbl, sf.fkField, sf.fkOptions...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return tbl, nil
+}
+
+// RecordFromStruct returns a new record of the table with the values of the struct v (or pointer to struct).
+// Nil pointers and invalid sql.Null* values are not set.
+func (t *Table) RecordFromStruct(v any) (*InRecord, error) {
+	sfs, err := structFields(v)
+	if err != nil {
+		return nil, err
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+
+	rec := t.NewRecord()
+	for i := 0; i < len(sfs); i++ {
+		sf := sfs[i]
+		if t.Field(sf.name) == nil {
+			return nil, fmt.Errorf("RecordFromStruct: field %s is not in table %s", sf.name, t.name)
+		}
+		value, err := fromStructValue(rv.FieldByIndex(sf.index))
+		if err != nil {
+			return nil, fmt.Errorf("RecordFromStruct: field %s: %w", sf.name, err)
+		}
+		if value == nil {
+			continue
+		}
+		if err = rec.SetValue(sf.name, value); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+// ToStruct sets the fields of the struct pointed to by v from the record values.
+// NULL values set the Go field to its zero value.
+func (rec *InRecord) ToStruct(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("InRecord.ToStruct: need non-nil pointer to struct; have %T", v)
+	}
+	sfs, err := structFields(v)
+	if err != nil {
+		return err
+	}
+	rv = rv.Elem()
+
+	for i := 0; i < len(sfs); i++ {
+		sf := sfs[i]
+		value, err := rec.Get(sf.name)
+		if err != nil {
+			return err
+		}
+		if err = toStructValue(rv.FieldByIndex(sf.index), value); err != nil {
+			return fmt.Errorf("InRecord.ToStruct: field %s: %w", sf.name, err)
+		}
+	}
+	return nil
+}
+
+func structFields(v any) ([]*structField, error) {
+	if v == nil {
+		return nil, errors.New("Struct is nil")
+	}
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Need struct or pointer to struct; have %T", v)
+	}
+
+	sfs, err := appendStructFields(nil, t, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(sfs) == 0 {
+		return nil, fmt.Errorf("Struct %s has no fields", t.Name())
+	}
+
+	names := make(map[string]struct{}, len(sfs))
+	for i := 0; i < len(sfs); i++ {
+		if _, ok := names[sfs[i].name]; ok {
+			return nil, fmt.Errorf("Struct %s: duplicate field name %s", t.Name(), sfs[i].name)
+		}
+		names[sfs[i].name] = struct{}{}
+	}
+	return sfs, nil
+}
+
+func appendStructFields(sfs []*structField, t reflect.Type, index []int) ([]*structField, error) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup(StructTag)
+		if tag == "-" {
+			continue
+		}
+		fIndex := append(append([]int{}, index...), i)
+
+		// Exported fields of embedded structs are promoted, even if the struct type is unexported
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct && !reflect.PointerTo(f.Type).Implements(scannerType) {
+			var err error
+			sfs, err = appendStructFields(sfs, f.Type, fIndex)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		sf, err := parseStructTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("Struct %s field %s: %w", t.Name(), f.Name, err)
+		}
+		if sf.name == "" {
+			sf.name = strings.ToLower(f.Name)
+		}
+		sf.index = fIndex
+		sf.fieldType, err = goFieldType(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("Struct %s field %s: %w", t.Name(), f.Name, err)
+		}
+		sfs = append(sfs, sf)
+	}
+	return sfs, nil
+}
+
+func parseStructTag(tag string) (*structField, error) {
+	sf := new(structField)
+	parts := strings.Split(tag, ",")
+	sf.name = strings.TrimSpace(parts[0])
+
+	for i := 1; i < len(parts); i++ {
+		option, value, _ := strings.Cut(strings.TrimSpace(parts[i]), "=")
+		switch option {
+		case "pk":
+			sf.pk = true
+		case "notnull":
+			sf.notNull = true
+		case "unique":
+			sf.unique = true
+		case "index":
+			sf.indexed = true
+		case "len":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid len [%s]", value)
+			}
+			sf.length = n
+		case "default":
+			def := value
+			sf.defaultValue = &def
+		case "fk":
+			table, field, ok := strings.Cut(value, ".")
+			if !ok || table == "" || field == "" {
+				return nil, fmt.Errorf("invalid fk [%s]; need table.field", value)
+			}
+			sf.fkTable, sf.fkField = table, field
+		case "ondelete", "onupdate":
+			a, err := ParseForeignKeyAction(value)
+			if err != nil {
+				return nil, err
+			}
+			if option == "ondelete" {
+				sf.fkOptions = append(sf.fkOptions, OnDelete(a))
+			} else {
+				sf.fkOptions = append(sf.fkOptions, OnUpdate(a))
+			}
+		case "deferrable":
+			sf.fkOptions = append(sf.fkOptions, Deferrable())
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown tag option [%s]", option)
+		}
+	}
+	if len(sf.fkOptions) > 0 && sf.fkTable == "" {
+		return nil, errors.New("foreign key options without fk")
+	}
+	return sf, nil
+}
+
+func goFieldType(t reflect.Type) (FieldType, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case reflect.TypeOf(sql.NullInt64{}), reflect.TypeOf(sql.NullInt32{}), reflect.TypeOf(sql.NullInt16{}), reflect.TypeOf(sql.NullByte{}):
+		return IntType, nil
+	case reflect.TypeOf(sql.NullString{}):
+		return StringType, nil
+	case reflect.TypeOf(sql.NullFloat64{}):
+		return FloatType, nil
+	case reflect.TypeOf(sql.NullBool{}):
+		return BoolType, nil
+	case bytesType:
+		return ByteArrayType, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return IntType, nil
+	case reflect.String:
+		return StringType, nil
+	case reflect.Bool:
+		return BoolType, nil
+	case reflect.Float32, reflect.Float64:
+		return FloatType, nil
+	}
+	return FunctionType, fmt.Errorf("unsupported type %s", t)
+}
+
+func (sf *structField) field() (*Field, error) {
+	f := NewField(sf.name, sf.fieldType)
+	if sf.pk {
+		f.PK()
+	}
+	if sf.notNull {
+		f.NotNull()
+	}
+	if sf.unique {
+		f.Unique()
+	}
+	if sf.length != 0 {
+		f.Length(sf.length)
+	}
+	if sf.defaultValue != nil {
+		def, err := parseDefault(sf.fieldType, *sf.defaultValue)
+		if err != nil {
+			return nil, fmt.Errorf("Field %s: %w", sf.name, err)
+		}
+		f.Default(def)
+	}
+	return f, f.Err()
+}
+
+func parseDefault(fieldType FieldType, s string) (any, error) {
+	switch fieldType {
+	case IntType:
+		return strconv.ParseInt(s, 10, 64)
+	case FloatType:
+		return strconv.ParseFloat(s, 64)
+	case BoolType:
+		return strconv.ParseBool(s)
+	}
+	return s, nil
+}
+
+// The value of the Go field as int64, float64, string, bool or []byte; nil for NULL
+func fromStructValue(rv reflect.Value) (any, error) {
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+
+	if valuer, ok := rv.Interface().(driver.Valuer); ok {
+		return valuer.Value()
+	}
+	if rv.Type() == bytesType {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return rv.Bytes(), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return nil, fmt.Errorf("value %d overflows int64", u)
+		}
+		return int64(u), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", rv.Type())
+}
+
+func toStructValue(rv reflect.Value, value any) error {
+	if value == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	if rv.Kind() == reflect.Pointer {
+		p := reflect.New(rv.Type().Elem())
+		if err := toStructValue(p.Elem(), value); err != nil {
+			return err
+		}
+		rv.Set(p)
+		return nil
+	}
+
+	if scanner, ok := rv.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(value)
+	}
+
+	switch v := value.(type) {
+	case int64:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if rv.OverflowInt(v) {
+				return fmt.Errorf("value %d overflows %s", v, rv.Type())
+			}
+			rv.SetInt(v)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v < 0 || rv.OverflowUint(uint64(v)) {
+				return fmt.Errorf("value %d overflows %s", v, rv.Type())
+			}
+			rv.SetUint(uint64(v))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			rv.SetFloat(float64(v))
+			return nil
+		case reflect.Bool:
+			rv.SetBool(v != 0)
+			return nil
+		}
+	case float64:
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			rv.SetFloat(v)
+			return nil
+		}
+	case string:
+		switch {
+		case rv.Kind() == reflect.String:
+			rv.SetString(v)
+			return nil
+		case rv.Type() == bytesType:
+			rv.SetBytes([]byte(v))
+			return nil
+		}
+	case bool:
+		if rv.Kind() == reflect.Bool {
+			rv.SetBool(v)
+			return nil
+		}
+	case []byte:
+		switch {
+		case rv.Type() == bytesType:
+			rv.SetBytes(append([]byte(nil), v...))
+			return nil
+		case rv.Kind() == reflect.String:
+			rv.SetString(string(v))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot set %s from %T", rv.Type(), value)
+}
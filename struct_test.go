@@ -0,0 +1,182 @@
+package dalkeeth
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+)
+
+type structTestAddress struct {
+	ID     int64  `dalkeeth:"id,pk"`
+	Street string `dalkeeth:"street,notnull,len=64"`
+	City   string `dalkeeth:",index"`
+}
+
+type structTestAudit struct {
+	Version int32 `dalkeeth:"version,default=1"`
+}
+
+type structTestPerson struct {
+	ID        int64        `dalkeeth:"id,pk"`
+	Name      string       `dalkeeth:"name,notnull,unique,len=64"`
+	Age       *int         `dalkeeth:"age,default=99"`
+	Weight    float32      `dalkeeth:"weight"`
+	Citizen   sql.NullBool `dalkeeth:"citizen"`
+	Photo     []byte       `dalkeeth:"photo"`
+	AddressID int64        `dalkeeth:"address_id,fk=addresses.id"`
+	Ignored   string       `dalkeeth:"-"`
+	private   int
+	structTestAudit
+}
+
+func structTestModel() (*Model, error) {
+	model := NewModel()
+	if _, err := model.TableFromStruct("addresses", structTestAddress{}); err != nil {
+		return nil, err
+	}
+	if _, err := model.TableFromStruct("people", &structTestPerson{}); err != nil {
+		return nil, err
+	}
+	return model, model.Freeze()
+}
+
+func TestModel_TableFromStruct(t *testing.T) {
+	setupTest()
+	model, err := structTestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := new(DialectSqlite3)
+	for key, expected := range map[string]string{
+		"addresses": `CREATE TABLE IF NOT EXISTS "addresses" ("id" INT PRIMARY KEY, "street" varchar(64) NOT NULL, "city" TEXT)`,
+		"people": `CREATE TABLE IF NOT EXISTS "people" ("id" INT PRIMARY KEY, "name" varchar(64) NOT NULL UNIQUE, "age" INT DEFAULT 99,` +
+			` "weight" REAL, "citizen" BOOLEAN, "photo" BLOB, "address_id" INT, "version" INT DEFAULT 1,` +
+			` FOREIGN KEY("address_id") REFERENCES "addresses"("id"))`,
+	} {
+		s, err := d.CreateTableSql(model.TableByKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected {
+			t.Fatalf("Expected:\n%s\nGot:\n%s", expected, s)
+		}
+	}
+
+	addresses := model.TableByKey("addresses")
+	if len(addresses.indexes) != 1 || addresses.indexes[0].fields[0].name != "city" {
+		t.Fatal("Should have index on city")
+	}
+}
+
+func TestModel_TableFromStruct_Invalid(t *testing.T) {
+	setupTest()
+	type unknownOption struct {
+		ID int64 `dalkeeth:"id,primary"`
+	}
+	type unsupportedType struct {
+		ID  int64 `dalkeeth:"id,pk"`
+		Map map[string]int
+	}
+	type duplicateName struct {
+		ID  int64 `dalkeeth:"id,pk"`
+		ID2 int64 `dalkeeth:"id"`
+	}
+	type badDefault struct {
+		Age int `dalkeeth:"age,default=old"`
+	}
+	type unknownForeignTable struct {
+		OtherID int64 `dalkeeth:"other_id,fk=other.id"`
+	}
+
+	for name, v := range map[string]any{
+		"not struct":            3,
+		"unknown option":        unknownOption{},
+		"unsupported type":      unsupportedType{},
+		"duplicate name":        duplicateName{},
+		"bad default":           badDefault{},
+		"unknown foreign table": unknownForeignTable{},
+	} {
+		if _, err := NewModel().TableFromStruct("t", v); err == nil {
+			t.Fatal(name, ShouldHaveFailed)
+		}
+	}
+}
+
+func TestInRecord_Struct_RoundTrip(t *testing.T) {
+	setupTest()
+	model, err := structTestModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := writeTestModelSchema(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	people := sess.TableByKey("people")
+
+	age := 42
+	in := structTestPerson{
+		ID:        7,
+		Name:      "Fred",
+		Age:       &age,
+		Weight:    70.5,
+		Citizen:   sql.NullBool{Bool: true, Valid: true},
+		Photo:     []byte{1, 2, 3},
+		AddressID: 3,
+		Ignored:   "ignored",
+	}
+	in.Version = 2
+
+	rec, err := people.RecordFromStruct(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = sess.Get(people, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out structTestPerson
+	if err = rec.ToStruct(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.ID != in.ID || out.Name != in.Name || out.Age == nil || *out.Age != age || out.Weight != in.Weight ||
+		out.Citizen != in.Citizen || !bytes.Equal(out.Photo, in.Photo) || out.AddressID != in.AddressID ||
+		out.Ignored != "" || out.Version != 2 {
+		t.Fatalf("Expected %+v; got %+v", in, out)
+	}
+
+	// NULLs and defaults
+	in = structTestPerson{ID: 8, Name: "Wilma"}
+	rec, err = people.RecordFromStruct(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sess.Save(rec); err != nil {
+		t.Fatal(err)
+	}
+	rec, err = sess.Get(people, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = structTestPerson{Age: &age, Citizen: sql.NullBool{Valid: true}}
+	if err = rec.ToStruct(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Age == nil || *out.Age != 99 {
+		t.Fatalf("Age should be default 99: %+v", out)
+	}
+	if out.Citizen.Valid || out.Photo != nil {
+		t.Fatalf("Should be NULL: %+v", out)
+	}
+
+	if err = rec.ToStruct(out); err == nil {
+		t.Fatal("Not a pointer", ShouldHaveFailed)
+	}
+}
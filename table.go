@@ -14,6 +14,7 @@ type InRecord struct {
 	table     *Table
 	values    []*Value
 	valuesMap map[string]*Value
+	related   map[string][]*InRecord // By relation name; see Session.Preload
 }
 
 type ResultSet struct {
@@ -28,23 +29,27 @@ type Value struct {
 	isWanted bool
 }
 
+// NewValue is a value for the field, i.e. for Session.UpdateWhere
+func NewValue(field *Field, value any) *Value {
+	return &Value{
+		field: field,
+		value: value,
+		isSet: true,
+	}
+}
+
 type Table struct {
 	name        string
-	pk          *Field
+	pk          *Field   // Single field primary key; nil if the primary key is composite
+	pks         []*Field // All the primary key fields, in key order
 	fields      []*Field
 	fieldsMap   map[string]*Field
 	indexes     []*Index
 	foreignKeys []*ForeignKey
+	relations   []*Relation
 	frozen      bool
 }
 
-type ForeignKey struct {
-	tbl          *Table
-	field        *Field
-	foreignTable *Table
-	foreignKey   *Field
-}
-
 type Join struct {
 	segments []JoinSegment
 }
@@ -58,6 +63,7 @@ type JoinSegment struct {
 
 // name: idx_table_f0_f1_...
 type Index struct {
+	name   string // Set when extracted from a database
 	fields []*Field
 	unique bool
 	table  *Table
@@ -77,17 +83,21 @@ func (t *Table) Freeze() error {
 	return NotImplemented
 }
 
+var NullValue = errors.New("Value is NULL")
+
 func (rec *InRecord) GetInt(fieldName string, vv *int64) error {
 	v, err := rec.Value(fieldName)
 	if err != nil {
 		return err
 	}
 
-	switch p := v.value.(type) {
-	case *int64:
-		vv = p
+	switch p := actualValue(v.value).(type) {
+	case int64:
+		*vv = p
+	case nil:
+		return NullValue
 	default:
-		return errors.New("Wrong type: expecting *int64")
+		return errors.New("Wrong type: expecting int64")
 	}
 
 	return nil
@@ -99,12 +109,14 @@ func (rec *InRecord) GetString(fieldName string, vv *string) error {
 		return err
 	}
 
-	switch p := v.value.(type) {
-	case *string:
-		vv = p
+	switch p := actualValue(v.value).(type) {
+	case string:
+		*vv = p
 		return nil
+	case nil:
+		return NullValue
 	default:
-		return errors.New("Wrong type: expecting *string")
+		return errors.New("Wrong type: expecting string")
 	}
 
 }
@@ -115,12 +127,14 @@ func (rec *InRecord) GetBool(fieldName string, vv *bool) error {
 		return err
 	}
 
-	switch p := v.value.(type) {
-	case *bool:
-		vv = p
+	switch p := actualValue(v.value).(type) {
+	case bool:
+		*vv = p
 		return nil
+	case nil:
+		return NullValue
 	default:
-		return errors.New("Wrong type: expecting *bool")
+		return errors.New("Wrong type: expecting bool")
 	}
 
 }
@@ -131,12 +145,17 @@ func (rec *InRecord) GetFloat(fieldName string, vv *float64) error {
 		return err
 	}
 
-	switch p := v.value.(type) {
-	case *float64:
-		vv = p
+	switch p := actualValue(v.value).(type) {
+	case float64:
+		*vv = p
+		return nil
+	case int64:
+		*vv = float64(p)
 		return nil
+	case nil:
+		return NullValue
 	default:
-		return errors.New("Wrong type: expecting *float64")
+		return errors.New("Wrong type: expecting float64")
 	}
 
 }
@@ -151,19 +170,32 @@ func (rec *InRecord) Value(fieldName string) (*Value, error) {
 	if v, ok = rec.valuesMap[fieldName]; !ok {
 		return nil, fmt.Errorf("Field: %s is not in table: %s", fieldName, rec.table.name)
 	}
-	if v.field.fieldType != StringType {
-		return nil, fmt.Errorf("Field type is not int: field type is: %s in table: %s", v.field.fieldType, rec.table.name)
-	}
 	return v, nil
 }
 
-func (rec *InRecord) Save(tx *sql.Tx) error {
-	insert := "INSERT INTO " + rec.table.name + "("
+func (rec *InRecord) Table() *Table {
+	return rec.table
+}
+
+// Get returns the value of the field; nil if it is NULL or not set
+func (rec *InRecord) Get(fieldName string) (any, error) {
+	v, err := rec.Value(fieldName)
+	if err != nil {
+		return nil, err
+	}
+	return actualValue(v.value), nil
+}
+
+func (rec *InRecord) Save(tx *sql.Tx, d Dialect) error {
+	if d == nil {
+		return errors.New("InRecord.Save: dialect is nil")
+	}
+	insert := "INSERT INTO " + d.QuoteIdentifier(rec.table.name) + "("
 	for i := 0; i < len(rec.values); i++ {
 		if i != 0 {
 			insert += COMMA_SPACE
 		}
-		insert += rec.table.fields[i].name
+		insert += d.QuoteIdentifier(rec.table.fields[i].name)
 	}
 	insert += ") VALUES ("
 	var valueArray []any
@@ -172,7 +204,7 @@ func (rec *InRecord) Save(tx *sql.Tx) error {
 		if i != 0 {
 			insert += COMMA_SPACE
 		}
-		insert += "$" + strconv.Itoa(i+1)
+		insert += d.Placeholder(i + 1)
 		valueArray = append(valueArray, rec.values[i].value)
 	}
 	insert += ")"
@@ -211,6 +243,10 @@ func (rec *InRecord) SetValue(name string, value any) error {
 	if err != nil {
 		return err
 	}
+	err = v.field.inRange(value)
+	if err != nil {
+		return err
+	}
 
 	v.value = &value
 	v.isSet = true
@@ -260,15 +296,21 @@ func (t *Table) AddField(f *Field) (*Field, error) {
 		return nil, errors.New("Field name is empty")
 	}
 
+	if f.err != nil {
+		return nil, f.err
+	}
+
 	if _, ok := t.fieldsMap[f.name]; ok {
 		return nil, fmt.Errorf("Field already in table: %s", f.name)
 	}
 
-	if f.pk && t.pk != nil {
-		return nil, fmt.Errorf("PK collision: already assigned to field %s", t.pk.name)
-	}
+	// More than one primary key field is a composite primary key
 	if f.pk {
-		t.pk = f
+		t.pks = append(t.pks, f)
+		t.pk = nil
+		if len(t.pks) == 1 {
+			t.pk = f
+		}
 	}
 
 	f.table = t
@@ -278,20 +320,18 @@ func (t *Table) AddField(f *Field) (*Field, error) {
 	return f, nil
 }
 
-func (t *Table) addForeignKey(field *Field, foreignTable *Table, foreignKey *Field) error {
-	t.foreignKeys = append(t.foreignKeys,
-		&ForeignKey{
-			tbl:          t,
-			field:        field,
-			foreignTable: foreignTable,
-			foreignKey:   foreignKey,
-		})
+func (t *Table) addForeignKey(fk *ForeignKey) error {
+	if fk.tbl != t {
+		return fmt.Errorf("Table %s: foreign key of table %s", t.name, fk.tbl.name)
+	}
+	t.foreignKeys = append(t.foreignKeys, fk)
 	return nil
 }
 
 func (t *Table) AddIndex(unique bool, fields ...string) error {
 	index := new(Index)
 	index.table = t
+	index.unique = unique
 	index.fields = make([]*Field, len(fields))
 
 	for i := 0; i < len(fields); i++ {
@@ -305,14 +345,14 @@ func (t *Table) AddIndex(unique bool, fields ...string) error {
 	return nil
 }
 
-func (t *Table) CreateTableIndexesSql() ([]string, error) {
+func (t *Table) CreateTableIndexesSql(d Dialect) ([]string, error) {
 	ixs := []string{}
 	if len(t.indexes) == 0 {
 		return ixs, nil
 	}
 
 	for i := 0; i < len(t.indexes); i++ {
-		s, err := t.indexes[i].CreateSql(i)
+		s, err := t.indexes[i].CreateSql(d, i)
 		if err != nil {
 			return []string{}, err
 		}
@@ -321,18 +361,21 @@ func (t *Table) CreateTableIndexesSql() ([]string, error) {
 	return ixs, nil
 }
 
-func (idx *Index) CreateSql(n int) (string, error) {
+func (idx *Index) CreateSql(d Dialect, n int) (string, error) {
+	if d == nil {
+		return "", errors.New("Index.CreateSql: dialect is nil")
+	}
 	s := "CREATE "
 	if idx.unique {
 		s += "UNIQUE "
 	}
-	s += "INDEX IF NOT EXISTS idx_" + idx.table.name + "_" + strconv.Itoa(n) + " ON " + idx.table.name + "("
+	s += "INDEX IF NOT EXISTS " + d.QuoteIdentifier("idx_"+idx.table.name+"_"+strconv.Itoa(n)) + " ON " + d.QuoteIdentifier(idx.table.name) + "("
 
 	for i := 0; i < len(idx.fields); i++ {
 		if i != 0 {
 			s += COMMA_SPACE
 		}
-		s += idx.fields[i].name
+		s += d.QuoteIdentifier(idx.fields[i].name)
 	}
 	s += ")"
 
@@ -352,23 +395,29 @@ func (idx *Index) CreateSql(n int) (string, error) {
 // 	return nil
 // }
 
-func (t *Table) DropTableSql() (string, error) {
-	return "DROP TABLE IF EXISTS " + t.name, nil
+func (t *Table) DropTableSql(d Dialect) (string, error) {
+	if d == nil {
+		return "", errors.New("Table.DropTableSql: dialect is nil")
+	}
+	return "DROP TABLE IF EXISTS " + d.QuoteIdentifier(t.name), nil
 }
 
-func (t *Table) CreateTableSql() (string, error) {
+func (t *Table) CreateTableSql(d Dialect) (string, error) {
+	if d == nil {
+		return "", errors.New("Table.CreateTableSql: dialect is nil")
+	}
 	err := checkTable(t)
 	if err != nil {
 		return "", err
 	}
 
-	s := "CREATE TABLE IF NOT EXISTS " + t.name + " ("
+	s := "CREATE TABLE IF NOT EXISTS " + d.QuoteIdentifier(t.name) + " ("
 
 	for i := 0; i < len(t.fields); i++ {
 		if i != 0 {
 			s += COMMA_SPACE
 		}
-		fs, err := t.fields[i].CreateFieldSql()
+		fs, err := t.fields[i].CreateFieldSql(d)
 		if err != nil {
 			return "", err
 		}
@@ -405,8 +454,11 @@ func countPrimaryFields(fs []*Field) int {
 	return n
 }
 
-func (t *Table) Count(db *sql.DB) (int64, error) {
-	row := db.QueryRow("SELECT count(*) from " + t.name)
+func (t *Table) Count(db *sql.DB, d Dialect) (int64, error) {
+	if d == nil {
+		return -1, errors.New("Table.Count: dialect is nil")
+	}
+	row := db.QueryRow("SELECT count(*) from " + d.QuoteIdentifier(t.name))
 	var n int64
 	err := row.Scan(&n)
 	if err != nil {
@@ -418,10 +470,12 @@ func (t *Table) Count(db *sql.DB) (int64, error) {
 	return n, nil
 }
 
-func (t *Table) GetMaxId(db *sql.DB) (int64, error) {
-	n, err := t.Count(db)
+func (t *Table) GetMaxId(db *sql.DB, d Dialect) (int64, error) {
+	if t.pk == nil {
+		return -1, fmt.Errorf("Table %s: GetMaxId needs a single field primary key", t.name)
+	}
+	n, err := t.Count(db, d)
 	if err != nil {
-		log.Println(err)
 		return -1, err
 	}
 
@@ -429,7 +483,7 @@ func (t *Table) GetMaxId(db *sql.DB) (int64, error) {
 		return 0, nil
 	}
 
-	row := db.QueryRow("SELECT max(" + t.pk.name + ") from " + t.name)
+	row := db.QueryRow("SELECT max(" + d.QuoteIdentifier(t.pk.name) + ") from " + d.QuoteIdentifier(t.name))
 	var id int64
 	err = row.Scan(&id)
 	if err != nil {
@@ -442,12 +496,14 @@ func (t *Table) GetMaxId(db *sql.DB) (int64, error) {
 }
 
 // FIXXX add bool,
-func FindFieldValueById[V *int64 | *float64 | *string](t *Table, db *sql.DB, idValue int64, field *Field, fieldValue V) (bool, error) {
-	log.Println(field.name)
-	log.Println(t.name)
-	log.Println(t.pk)
-	log.Println(t.pk.name)
-	query := "SELECT " + field.name + " from " + t.name + " where " + t.pk.name + "=?"
+func FindFieldValueById[V *int64 | *float64 | *string](t *Table, db *sql.DB, d Dialect, idValue int64, field *Field, fieldValue V) (bool, error) {
+	if t.pk == nil {
+		return false, fmt.Errorf("Table %s: FindFieldValueById needs a single field primary key", t.name)
+	}
+	if d == nil {
+		return false, errors.New("FindFieldValueById: dialect is nil")
+	}
+	query := "SELECT " + d.QuoteIdentifier(field.name) + " from " + d.QuoteIdentifier(t.name) + " where " + d.QuoteIdentifier(t.pk.name) + "=" + d.Placeholder(1)
 
 	row := db.QueryRow(query, idValue)
 
@@ -461,20 +517,18 @@ func FindFieldValueById[V *int64 | *float64 | *string](t *Table, db *sql.DB, idV
 	return true, nil
 }
 
+// PrimaryKey returns the primary key fields; more than one for a composite primary key
+func (t *Table) PrimaryKey() []*Field {
+	return t.pks
+}
+
 func (t *Table) Field(s string) *Field {
 	field, _ := t.fieldsMap[s]
 	return field
 }
 
-func (t *Table) AllFields() string {
-	fs := ""
-	for i := 0; i < len(t.fields); i++ {
-		if i != 0 {
-			fs += COMMA_SPACE
-		}
-		fs += t.fields[i].name
-	}
-	return fs
+func (t *Table) AllFields(d Dialect) string {
+	return quoteFields(d, t.fields)
 }
 
 func (t *Table) SelectRecordsSimpleWhere(db *sql.DB, left, operator, right string, limit, offset int64) (*[]InRecord, error) {
@@ -505,8 +559,14 @@ func (t *Table) notField(field string) bool {
 // 	return rec, nil
 // }
 
-func (t *Table) FieldValueExists(db *sql.DB, field *Field, value any) (bool, error) {
-	row := db.QueryRow("SELECT "+t.pk.name+" from "+t.name+" where "+field.name+"= ?",
+func (t *Table) FieldValueExists(db *sql.DB, d Dialect, field *Field, value any) (bool, error) {
+	if t.pk == nil {
+		return false, fmt.Errorf("Table %s: FieldValueExists needs a single field primary key", t.name)
+	}
+	if d == nil {
+		return false, errors.New("Table.FieldValueExists: dialect is nil")
+	}
+	row := db.QueryRow("SELECT "+d.QuoteIdentifier(t.pk.name)+" from "+d.QuoteIdentifier(t.name)+" where "+d.QuoteIdentifier(field.name)+"= "+d.Placeholder(1),
 		value)
 	var pk int64
 	err := row.Scan(&pk)
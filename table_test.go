@@ -14,7 +14,7 @@ func TestSqlCreate(t *testing.T) {
 		t.Error(err)
 	}
 
-	_, err = tbl.CreateTableSql()
+	_, err = tbl.CreateTableSql(new(DialectSqlite3))
 	if err != nil {
 		t.Error(err)
 	}
@@ -32,7 +32,7 @@ func TestIndexSql(t *testing.T) {
 			tbl.fields[1],
 		},
 	}
-	_, err = index.CreateSql(0)
+	_, err = index.CreateSql(new(DialectSqlite3), 0)
 	if err != nil {
 		t.Error(err)
 	}
@@ -52,7 +52,7 @@ func TestIndexSqlWithDB(t *testing.T) {
 			tbl.fields[1],
 		},
 	}
-	createIndexSql, err := index.CreateSql(0)
+	createIndexSql, err := index.CreateSql(new(DialectSqlite3), 0)
 
 	if err != nil {
 		t.Error(err)
@@ -70,7 +70,7 @@ func TestSqlWithDB(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	createSql, err := tbl.CreateTableSql()
+	createSql, err := tbl.CreateTableSql(new(DialectSqlite3))
 
 	if err != nil {
 		t.Error(err)
@@ -95,7 +95,7 @@ func TestSql_NoTableName(t *testing.T) {
 		t.Error(err)
 	}
 	tbl.name = ""
-	_, err = tbl.CreateTableSql()
+	_, err = tbl.CreateTableSql(new(DialectSqlite3))
 
 	if err == nil {
 		t.Error(err)
@@ -108,7 +108,7 @@ func TestSql_NoFields(t *testing.T) {
 		t.Error(err)
 	}
 	tbl.fields = nil
-	_, err = tbl.CreateTableSql()
+	_, err = tbl.CreateTableSql(new(DialectSqlite3))
 
 	if err == nil {
 		t.Error(err)
@@ -126,8 +126,14 @@ func TestSql_MultiplePrimaryKeys(t *testing.T) {
 		pk:        true,
 	}
 	_, err = tbl.AddField(&f)
-	if err == nil {
-		t.Error("This should be an error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tbl.PrimaryKey()) != 2 || tbl.pk != nil {
+		t.Fatal("Table should have a composite primary key")
+	}
+	if _, err = tbl.GetMaxId(nil, new(DialectSqlite3)); err == nil {
+		t.Fatal("GetMaxId on composite primary key", ShouldHaveFailed)
 	}
 }
 
@@ -139,7 +145,7 @@ func TestFindFieldValueById(t *testing.T) {
 
 	// end setup
 	var name string
-	ok, err := FindFieldValueById(tbl, db, TestId0, tbl.fields[1], &name)
+	ok, err := FindFieldValueById(tbl, db, new(DialectSqlite3), TestId0, tbl.fields[1], &name)
 	if err != nil {
 		t.Error(err)
 	}
@@ -177,7 +183,7 @@ func simpleTestTable() (*sql.DB, *Table, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	createSql, err := tbl.CreateTableSql()
+	createSql, err := tbl.CreateTableSql(new(DialectSqlite3))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -247,12 +253,12 @@ func populateTable(db *sql.DB, tbl *Table) error {
 		log.Println(err)
 		return err
 	}
-	err = rec.Save(tx)
+	err = rec.Save(tx, new(DialectSqlite3))
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	err = rec2.Save(tx)
+	err = rec2.Save(tx, new(DialectSqlite3))
 	if err != nil {
 		log.Println(err)
 		return err
@@ -2,6 +2,7 @@ package dalkeeth
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"log"
 	"net/url"
@@ -49,31 +50,79 @@ func rawWantedValues(values []*Value) ([]any, error) {
 	return v, nil
 }
 
+// Replaces the scan destination in v with the value it points to; NULLs become nil
 func actual(v *Value) {
-	switch p := v.value.(type) {
+	v.value = actualValue(v.value)
+}
+
+func actualValue(value any) any {
+	switch p := value.(type) {
+	case *any:
+		if p == nil {
+			return nil
+		}
+		return actualValue(*p)
 	case *int64:
-		v.value = *p
-		log.Println("actual int64", p, *p)
+		return *p
+	case *string:
+		return *p
+	case *float64:
+		return *p
+	case *bool:
+		return *p
+	case *[]byte:
+		if *p == nil {
+			return nil
+		}
+		return *p
+	case int:
+		return int64(p)
+	case *sql.NullInt64:
+		if !p.Valid {
+			return nil
+		}
+		return p.Int64
+	case *sql.NullString:
+		if !p.Valid {
+			return nil
+		}
+		return p.String
+	case *sql.NullFloat64:
+		if !p.Valid {
+			return nil
+		}
+		return p.Float64
+	case *sql.NullBool:
+		if !p.Valid {
+			return nil
+		}
+		return p.Bool
+	case sql.NullString:
+		if !p.Valid {
+			return nil
+		}
+		return p.String
 	}
+	return value
 }
 
+// Scan destinations are nullable so that NULL columns can be read
 func makeValue(fieldType FieldType) (any, error) {
 	switch fieldType {
 	case IntType:
-		var v int64
-		return &v, nil
+		return new(sql.NullInt64), nil
 
 	case StringType:
-		var v string
-		return &v, nil
+		return new(sql.NullString), nil
 
 	case FloatType:
-		var v float64
-		return &v, nil
+		return new(sql.NullFloat64), nil
 
 	case BoolType:
-		var v bool
-		return &v, nil
+		return new(sql.NullBool), nil
+
+	case ByteArrayType:
+		return new([]byte), nil
 	}
 
 	return nil, errors.New("Unknown field type")
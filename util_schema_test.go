@@ -8,10 +8,16 @@ import (
 )
 
 func writeTestTableRecords(sess *Session) error {
+	persons := sess.TableByKey(TPerson)
+	if persons == nil {
+		return fmt.Errorf("Table key %s not found", TPerson)
+	}
 
-	return NotImplemented
-	//	return nil
-
+	records, err := twoPersonRecords(persons)
+	if err != nil {
+		return err
+	}
+	return sess.Batch(records)
 }
 
 func writeTestModelSchema(mdl *Model) (*Session, error) {
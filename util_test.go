@@ -12,7 +12,14 @@ func TestMain(m *testing.M) {
 	if true {
 		log.SetOutput(ioutil.Discard)
 	}
-	exitVal := m.Run()
+	// The tests run once with each SQLite driver
+	exitVal := 0
+	for i := 0; i < len(testSqliteDrivers); i++ {
+		testSqliteDriver = testSqliteDrivers[i]
+		if code := m.Run(); code != 0 {
+			exitVal = code
+		}
+	}
 	os.Exit(exitVal)
 }
 